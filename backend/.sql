@@ -4,11 +4,16 @@ CREATE DATABASE finance;
 
 -- 账本（预留多用户/多账本扩展）
 CREATE TABLE fin_ledgers (
-  id          SERIAL PRIMARY KEY,
-  name        TEXT NOT NULL,
-  description TEXT,
-  created_at  TIMESTAMP NOT NULL DEFAULT now(),
-  deleted_at  TIMESTAMP NULL
+  id            SERIAL PRIMARY KEY,
+  name          TEXT NOT NULL,
+  description   TEXT,
+  base_currency TEXT NOT NULL DEFAULT 'CNY',
+  timezone      TEXT NOT NULL DEFAULT 'Asia/Shanghai',
+  is_archived   BOOLEAN NOT NULL DEFAULT FALSE,
+  realized_gain_category_id INT NULL, -- 投资卖出已实现损益默认记入的收入分类（fin_categories.id）
+  long_term_holding_days INT NOT NULL DEFAULT 365, -- 资本利得报表中持有天数超过该值视为长期
+  created_at    TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at    TIMESTAMP NULL
 );
 COMMENT ON TABLE fin_ledgers IS '账本（预留多用户/多账本扩展）';
 CREATE INDEX idx_fin_ledgers_deleted_at ON fin_ledgers(deleted_at);
@@ -23,7 +28,7 @@ CREATE TABLE fin_accounts (
   id            SERIAL PRIMARY KEY,
   ledger_id     INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   name          TEXT NOT NULL,
-  type          TEXT NOT NULL CHECK (type IN ('cash','liability','debt','investment','other_asset')),
+  type          TEXT NOT NULL CHECK (type IN ('cash','liability','debt','investment','other_asset','fx_clearing')), -- fx_clearing 为系统自动创建的外汇清算账户
   currency      TEXT NOT NULL DEFAULT 'CNY',
   is_active     BOOLEAN NOT NULL DEFAULT TRUE,
   created_at    TIMESTAMP NOT NULL DEFAULT now(),
@@ -39,7 +44,7 @@ CREATE TABLE fin_account_snapshots (
   ledger_id  INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   account_id INT NOT NULL REFERENCES fin_accounts(id) ON DELETE CASCADE,
   as_of      DATE NOT NULL,
-  amount     NUMERIC(18,2) NOT NULL,
+  amount     NUMERIC(20,4) NOT NULL, -- 负债账户记录为正数的未偿还金额
   note       TEXT,
   created_at TIMESTAMP NOT NULL DEFAULT now()
 );
@@ -84,7 +89,7 @@ CREATE TABLE fin_transaction_lines (
   transaction_id INT NOT NULL REFERENCES fin_transactions(id) ON DELETE CASCADE,
   account_id     INT NOT NULL REFERENCES fin_accounts(id),
   category_id    INT REFERENCES fin_categories(id),
-  amount         NUMERIC(18,2) NOT NULL CHECK (amount <> 0), -- 收入正，支出负；转账/投资用借贷平衡
+  amount         NUMERIC(20,4) NOT NULL CHECK (amount <> 0), -- 流入账户为正、流出为负（负债账户正数为还款）；转账/投资用借贷平衡；按账户币种最小单位舍入
   tags           TEXT[] DEFAULT '{}',
   note           TEXT,
   deleted_at     TIMESTAMP NULL
@@ -96,36 +101,112 @@ CREATE INDEX idx_fin_transaction_lines_account ON fin_transaction_lines(account_
 CREATE INDEX idx_fin_transaction_lines_category ON fin_transaction_lines(category_id);
 CREATE INDEX idx_fin_transaction_lines_deleted_at ON fin_transaction_lines(deleted_at);
 
+-- 转账
+CREATE TABLE fin_transfers (
+  id              SERIAL PRIMARY KEY,
+  ledger_id       INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
+  transaction_id  INT NOT NULL UNIQUE REFERENCES fin_transactions(id) ON DELETE CASCADE,
+  from_account_id INT NOT NULL REFERENCES fin_accounts(id),
+  to_account_id   INT NOT NULL REFERENCES fin_accounts(id),
+  amount          NUMERIC(20,4) NOT NULL CHECK (amount > 0), -- 转出账户币种
+  to_amount       NUMERIC(20,4) NOT NULL CHECK (to_amount > 0), -- 转入账户币种
+  fx_rate         NUMERIC(24,8) NOT NULL DEFAULT 1, -- to_amount / amount
+  fee             NUMERIC(20,4) NOT NULL DEFAULT 0, -- 转出账户币种
+  fee_category_id INT NULL REFERENCES fin_categories(id),
+  created_at      TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at      TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_transfers IS '转账记录：跨币种时分录经各币种的外汇清算账户过渡，保证每个币种借贷平衡';
+CREATE INDEX idx_fin_transfers_ledger_id ON fin_transfers(ledger_id);
+CREATE INDEX idx_fin_transfers_from_account_id ON fin_transfers(from_account_id);
+CREATE INDEX idx_fin_transfers_to_account_id ON fin_transfers(to_account_id);
+CREATE INDEX idx_fin_transfers_deleted_at ON fin_transfers(deleted_at);
+
 -- 证券主数据
 CREATE TABLE fin_securities (
   id         SERIAL PRIMARY KEY,
   ledger_id  INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
-  ticker     TEXT NOT NULL UNIQUE,
+  ticker     TEXT NOT NULL,
   name       TEXT NOT NULL,
   currency   TEXT NOT NULL DEFAULT 'CNY',
+  asset_class TEXT NOT NULL DEFAULT 'other' CHECK (asset_class IN ('stock','etf','fund','bond','money_market','crypto','commodity','other')),
+  exchange   TEXT NOT NULL DEFAULT '', -- 交易所代码，如 SSE/NASDAQ
   deleted_at TIMESTAMP NULL
 );
 COMMENT ON TABLE fin_securities IS '可投资标的元数据，如股票/基金';
 CREATE INDEX idx_fin_securities_ledger_id ON fin_securities(ledger_id);
+CREATE UNIQUE INDEX idx_security_ledger_ticker ON fin_securities(ledger_id, ticker);
 CREATE INDEX idx_fin_securities_deleted_at ON fin_securities(deleted_at);
 
+-- 公司行动（拆股、合股、代码变更、合并）
+CREATE TABLE fin_corporate_actions (
+  id                  SERIAL PRIMARY KEY,
+  ledger_id           INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
+  security_id         INT NOT NULL REFERENCES fin_securities(id),
+  type                TEXT NOT NULL CHECK (type IN ('split','reverse_split','symbol_change','merger')),
+  effective_on        DATE NOT NULL,
+  ratio_from          NUMERIC(24,8) NOT NULL DEFAULT 1, -- 每 ratio_from 股旧股
+  ratio_to            NUMERIC(24,8) NOT NULL DEFAULT 1, -- 换为 ratio_to 股新股（合并时为目标证券）
+  target_security_id  INT NULL REFERENCES fin_securities(id), -- 合并的目标证券
+  old_ticker          TEXT NOT NULL DEFAULT '',
+  new_ticker          TEXT NOT NULL DEFAULT '',
+  cash_in_lieu        NUMERIC(20,4) NOT NULL DEFAULT 0, -- 碎股现金补偿，记为卖出
+  cash_account_id     INT NULL REFERENCES fin_accounts(id),
+  note                TEXT NOT NULL DEFAULT '',
+  created_at          TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at          TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_corporate_actions IS '证券公司行动，按生效日关闭原批次并生成调整后的批次';
+CREATE INDEX idx_fin_corporate_actions_ledger_id ON fin_corporate_actions(ledger_id);
+CREATE INDEX idx_fin_corporate_actions_security_id ON fin_corporate_actions(security_id);
+CREATE INDEX idx_fin_corporate_actions_deleted_at ON fin_corporate_actions(deleted_at);
+
+-- 投资批次实物转移（在投资账户之间移动批次，保留成本与买入日期）
+CREATE TABLE fin_investment_transfers (
+  id               SERIAL PRIMARY KEY,
+  ledger_id        INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
+  transaction_id   INT NOT NULL REFERENCES fin_transactions(id) ON DELETE CASCADE, -- 在两个账户间转移成本的分录
+  security_id      INT NOT NULL REFERENCES fin_securities(id),
+  from_account_id  INT NOT NULL REFERENCES fin_accounts(id),
+  to_account_id    INT NOT NULL REFERENCES fin_accounts(id),
+  quantity         NUMERIC(24,8) NOT NULL,
+  lot_method       VARCHAR(16) NOT NULL DEFAULT 'specific',
+  created_at       TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at       TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_investment_transfers IS '批次实物转移，按转移日关闭原批次并在目标账户生成新批次';
+CREATE INDEX idx_fin_investment_transfers_ledger_id ON fin_investment_transfers(ledger_id);
+CREATE INDEX idx_fin_investment_transfers_transaction_id ON fin_investment_transfers(transaction_id);
+CREATE INDEX idx_fin_investment_transfers_security_id ON fin_investment_transfers(security_id);
+CREATE INDEX idx_fin_investment_transfers_deleted_at ON fin_investment_transfers(deleted_at);
+
 -- 投资批次（数量与价格）
 CREATE TABLE fin_investment_lots (
   id                   SERIAL PRIMARY KEY,
   ledger_id            INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   transaction_line_id  INT NOT NULL REFERENCES fin_transaction_lines(id) ON DELETE CASCADE,
+  account_id           INT NOT NULL REFERENCES fin_accounts(id), -- 持有该批次的投资账户；历史数据按买入分录账户回填
   security_id          INT NOT NULL REFERENCES fin_securities(id),
-  quantity             NUMERIC(18,4) NOT NULL,
-  price                NUMERIC(18,4) NOT NULL,
-  trade_price          NUMERIC(18,4) NOT NULL DEFAULT 0,
-  fee                  NUMERIC(18,4) NOT NULL DEFAULT 0,
-  tax                  NUMERIC(18,4) NOT NULL DEFAULT 0,
+  quantity             NUMERIC(24,8) NOT NULL,
+  price                NUMERIC(24,8) NOT NULL,
+  trade_price          NUMERIC(24,8) NOT NULL DEFAULT 0,
+  fee                  NUMERIC(20,4) NOT NULL DEFAULT 0,
+  tax                  NUMERIC(20,4) NOT NULL DEFAULT 0,
+  parent_lot_id        INT NULL REFERENCES fin_investment_lots(id), -- 公司行动或转移前的原批次
+  corporate_action_id  INT NULL REFERENCES fin_corporate_actions(id), -- 生成该批次的公司行动
+  transfer_id          INT NULL REFERENCES fin_investment_transfers(id), -- 生成该批次的实物转移
+  opened_on            DATE NULL, -- 公司行动生效日或转移日；买入批次为空，以买入交易日期为准
+  closed_on            DATE NULL, -- 被公司行动或转移替换的日期，此后不再持有
   deleted_at           TIMESTAMP NULL
 );
 COMMENT ON TABLE fin_investment_lots IS '买入批次数量与成交价，支持持仓与成本核算';
 CREATE INDEX idx_fin_investment_lots_ledger_id ON fin_investment_lots(ledger_id);
 CREATE INDEX idx_fin_investment_lots_security_id ON fin_investment_lots(security_id);
 CREATE INDEX idx_fin_investment_lots_deleted_at ON fin_investment_lots(deleted_at);
+CREATE INDEX idx_fin_investment_lots_parent_lot_id ON fin_investment_lots(parent_lot_id);
+CREATE INDEX idx_fin_investment_lots_corporate_action_id ON fin_investment_lots(corporate_action_id);
+CREATE INDEX idx_fin_investment_lots_account_id ON fin_investment_lots(account_id);
+CREATE INDEX idx_fin_investment_lots_transfer_id ON fin_investment_lots(transfer_id);
 
 -- 投资卖出记录（数量与价格）
 CREATE TABLE fin_investment_sales (
@@ -133,8 +214,12 @@ CREATE TABLE fin_investment_sales (
   ledger_id            INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   transaction_line_id  INT NOT NULL REFERENCES fin_transaction_lines(id) ON DELETE CASCADE,
   security_id          INT NOT NULL REFERENCES fin_securities(id),
-  quantity             NUMERIC(18,4) NOT NULL,
-  price                NUMERIC(18,4) NOT NULL,
+  quantity             NUMERIC(24,8) NOT NULL,
+  price                NUMERIC(24,8) NOT NULL,
+  fee                  NUMERIC(20,4) NOT NULL DEFAULT 0,
+  tax                  NUMERIC(20,4) NOT NULL DEFAULT 0,
+  lot_method           VARCHAR(16) NOT NULL DEFAULT 'specific', -- 批次选择方法：fifo/lifo/highest-cost/lowest-cost/average/specific
+  corporate_action_id  INT NULL REFERENCES fin_corporate_actions(id), -- 公司行动的碎股现金补偿
   deleted_at           TIMESTAMP NULL
 );
 COMMENT ON TABLE fin_investment_sales IS '卖出记录数量与成交价，用于已实现盈亏核算';
@@ -149,7 +234,7 @@ CREATE TABLE fin_investment_lot_allocations (
   ledger_id   INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   buy_lot_id  INT NOT NULL REFERENCES fin_investment_lots(id) ON DELETE CASCADE,
   sale_id     INT NOT NULL REFERENCES fin_investment_sales(id) ON DELETE CASCADE,
-  quantity    NUMERIC(18,4) NOT NULL,
+  quantity    NUMERIC(24,8) NOT NULL,
   created_at  TIMESTAMP NOT NULL DEFAULT now(),
   deleted_at  TIMESTAMP NULL
 );
@@ -159,15 +244,155 @@ CREATE INDEX idx_fin_investment_lot_allocations_buy_lot_id ON fin_investment_lot
 CREATE INDEX idx_fin_investment_lot_allocations_sale_id ON fin_investment_lot_allocations(sale_id);
 CREATE INDEX idx_fin_investment_lot_allocations_deleted_at ON fin_investment_lot_allocations(deleted_at);
 
+-- 证券分红/利息/分配收益
+CREATE TABLE fin_investment_dividends (
+  id                   SERIAL PRIMARY KEY,
+  ledger_id            INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
+  transaction_line_id  INT NOT NULL REFERENCES fin_transaction_lines(id) ON DELETE CASCADE, -- 现金账户上带收入分类的收益行，发放日为交易日期
+  security_id          INT NOT NULL REFERENCES fin_securities(id),
+  kind                 TEXT NOT NULL DEFAULT 'dividend' CHECK (kind IN ('dividend','interest','distribution')),
+  ex_date              DATE NULL, -- 除息日
+  gross_amount         NUMERIC(20,4) NOT NULL CHECK (gross_amount > 0), -- 现金账户币种
+  withholding_tax      NUMERIC(20,4) NOT NULL DEFAULT 0, -- 代扣税
+  lot_id               INT NULL REFERENCES fin_investment_lots(id), -- 红利再投资生成的批次
+  deleted_at           TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_investment_dividends IS '证券分红、债券利息与基金分配，关联收益分录与再投资批次';
+CREATE INDEX idx_fin_investment_dividends_ledger_id ON fin_investment_dividends(ledger_id);
+CREATE INDEX idx_fin_investment_dividends_line_id ON fin_investment_dividends(transaction_line_id);
+CREATE INDEX idx_fin_investment_dividends_security_id ON fin_investment_dividends(security_id);
+CREATE INDEX idx_fin_investment_dividends_deleted_at ON fin_investment_dividends(deleted_at);
+
 -- 价格历史
 CREATE TABLE fin_security_prices (
   ledger_id   INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
   security_id  INT NOT NULL REFERENCES fin_securities(id),
   price_at     DATE NOT NULL,
-  close_price  NUMERIC(18,4) NOT NULL,
+  close_price  NUMERIC(24,8) NOT NULL,
   deleted_at   TIMESTAMP NULL,
   PRIMARY KEY (ledger_id, security_id, price_at)
 );
 COMMENT ON TABLE fin_security_prices IS '标的每日收盘价，用于任意时点估值';
 CREATE INDEX idx_fin_security_prices_ledger_id ON fin_security_prices(ledger_id);
 CREATE INDEX idx_fin_security_prices_deleted_at ON fin_security_prices(deleted_at);
+
+-- 汇率
+CREATE TABLE fin_exchange_rates (
+  id            SERIAL PRIMARY KEY,
+  ledger_id     INT NOT NULL DEFAULT 1 REFERENCES fin_ledgers(id) ON DELETE RESTRICT,
+  rate_on       DATE NOT NULL,
+  from_currency CHAR(3) NOT NULL,
+  to_currency   CHAR(3) NOT NULL CHECK (to_currency <> from_currency),
+  rate          NUMERIC(24,8) NOT NULL CHECK (rate > 0), -- 1 单位 from_currency 折合的 to_currency
+  created_at    TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMP NOT NULL DEFAULT now(),
+  UNIQUE (ledger_id, rate_on, from_currency, to_currency)
+);
+COMMENT ON TABLE fin_exchange_rates IS '汇率：报表按日期取不晚于该日的最近汇率，缺正向汇率时用反向汇率取倒数';
+
+-- 用户
+CREATE TABLE fin_users (
+  id            SERIAL PRIMARY KEY,
+  username      TEXT NOT NULL UNIQUE,
+  password_hash TEXT NOT NULL, -- bcrypt
+  is_admin      BOOLEAN NOT NULL DEFAULT FALSE,
+  is_active     BOOLEAN NOT NULL DEFAULT TRUE,
+  totp_secret    TEXT NULL, -- base32，启用前为待确认密钥
+  totp_enabled   BOOLEAN NOT NULL DEFAULT FALSE,
+  totp_last_step BIGINT NOT NULL DEFAULT 0, -- 最近一次通过的时间步，防重放
+  created_at    TIMESTAMP NOT NULL DEFAULT now(),
+  deleted_at    TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_users IS '登录用户，密码以 bcrypt 摘要保存';
+CREATE INDEX idx_fin_users_deleted_at ON fin_users(deleted_at);
+
+-- 2FA 恢复码
+CREATE TABLE fin_user_recovery_codes (
+  id         SERIAL PRIMARY KEY,
+  user_id    INT NOT NULL REFERENCES fin_users(id) ON DELETE CASCADE,
+  code_hash  TEXT NOT NULL, -- SHA-256
+  used_at    TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_fin_user_recovery_codes_user_id ON fin_user_recovery_codes(user_id);
+
+-- 登录失败审计
+CREATE TABLE fin_login_attempts (
+  id         SERIAL PRIMARY KEY,
+  username   TEXT NULL,
+  ip         TEXT NULL,
+  user_agent TEXT NULL,
+  reason     TEXT NOT NULL, -- invalid_credentials | invalid_mfa | throttled
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_fin_login_attempts_username ON fin_login_attempts(username);
+CREATE INDEX idx_fin_login_attempts_ip ON fin_login_attempts(ip);
+CREATE INDEX idx_fin_login_attempts_created_at ON fin_login_attempts(created_at);
+
+-- 账本成员
+CREATE TABLE fin_ledger_members (
+  id         SERIAL PRIMARY KEY,
+  ledger_id  INT NOT NULL REFERENCES fin_ledgers(id) ON DELETE CASCADE,
+  user_id    INT NOT NULL REFERENCES fin_users(id) ON DELETE CASCADE,
+  role       TEXT NOT NULL CHECK (role IN ('owner','editor','viewer')),
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE fin_ledger_members IS '用户在账本上的角色：owner 管理成员，editor 可写，viewer 只读';
+CREATE UNIQUE INDEX idx_ledger_member ON fin_ledger_members(ledger_id, user_id);
+CREATE INDEX idx_fin_ledger_members_user_id ON fin_ledger_members(user_id);
+
+-- 注册邀请
+CREATE TABLE fin_user_invites (
+  id         SERIAL PRIMARY KEY,
+  code_hash  TEXT NOT NULL UNIQUE, -- SHA-256，明文只在创建时返回
+  ledger_id  INT REFERENCES fin_ledgers(id) ON DELETE CASCADE,
+  role       TEXT,
+  created_by INT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at    TIMESTAMP NULL,
+  used_by    INT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE fin_user_invites IS '管理员或账本 owner 签发的注册邀请，可附带账本角色';
+
+-- 登录会话
+CREATE TABLE fin_auth_sessions (
+  id           SERIAL PRIMARY KEY,
+  user_id      INT NOT NULL REFERENCES fin_users(id) ON DELETE CASCADE,
+  user_agent   TEXT,
+  ip           TEXT,
+  created_at   TIMESTAMP NOT NULL DEFAULT now(),
+  last_seen_at TIMESTAMP NOT NULL,
+  expires_at   TIMESTAMP NOT NULL,
+  revoked_at   TIMESTAMP NULL
+);
+COMMENT ON TABLE fin_auth_sessions IS '服务端登录会话，吊销后其 access token 立即失效';
+CREATE INDEX idx_fin_auth_sessions_user_id ON fin_auth_sessions(user_id);
+
+-- 刷新令牌（轮换使用）
+CREATE TABLE fin_refresh_tokens (
+  id         SERIAL PRIMARY KEY,
+  session_id INT NOT NULL REFERENCES fin_auth_sessions(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE, -- SHA-256
+  expires_at TIMESTAMP NOT NULL,
+  used_at    TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE fin_refresh_tokens IS 'refresh token 只能使用一次，重复使用视为泄露并吊销会话';
+CREATE INDEX idx_fin_refresh_tokens_session_id ON fin_refresh_tokens(session_id);
+
+CREATE TABLE fin_api_tokens (
+  id           SERIAL PRIMARY KEY,
+  user_id      INT NOT NULL REFERENCES fin_users(id) ON DELETE CASCADE,
+  name         TEXT NOT NULL,
+  prefix       TEXT NOT NULL, -- 明文前缀，便于识别
+  token_hash   TEXT NOT NULL UNIQUE, -- SHA-256
+  scope        TEXT NOT NULL CHECK (scope IN ('read','write')),
+  ledger_id    INT NULL REFERENCES fin_ledgers(id) ON DELETE CASCADE,
+  last_used_at TIMESTAMP NULL,
+  expires_at   TIMESTAMP NULL,
+  revoked_at   TIMESTAMP NULL,
+  created_at   TIMESTAMP NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE fin_api_tokens IS '个人访问令牌：read 只读，write 可写；ledger_id 非空时仅限该账本';
+CREATE INDEX idx_fin_api_tokens_user_id ON fin_api_tokens(user_id);
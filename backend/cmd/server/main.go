@@ -5,6 +5,7 @@ import (
 
 	"finance-backend/internal/config"
 	"finance-backend/internal/db"
+	"finance-backend/internal/handler/auth"
 	"finance-backend/internal/model"
 	"finance-backend/internal/router"
 )
@@ -18,6 +19,10 @@ func main() {
 		log.Fatalf("auto migrate failed: %v", err)
 	}
 
+	if err := auth.Bootstrap(database, cfg.Auth); err != nil {
+		log.Fatalf("auth bootstrap failed: %v", err)
+	}
+
 	engine := router.New(cfg, database)
 	if err := engine.Run(cfg.ServerAddr()); err != nil {
 		log.Fatalf("server exited: %v", err)
@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/gin-gonic/gin v1.10.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/shopspring/decimal v1.4.0
 	golang.org/x/crypto v0.23.0
 	gorm.io/driver/mysql v1.5.1
 	gorm.io/driver/postgres v1.5.5
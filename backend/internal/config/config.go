@@ -6,14 +6,22 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Config struct {
 	AppEnv   string
 	HTTPPort string
-	DB       DBConfig
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are honoured when resolving the client IP. Empty means
+	// no proxy is trusted and the remote address is used as is.
+	TrustedProxies []string
+	DB             DBConfig
+	Auth           AuthConfig
+	Transfer       TransferConfig
 }
 
 type DBConfig struct {
@@ -27,12 +35,50 @@ type DBConfig struct {
 	Timezone string
 }
 
+type AuthConfig struct {
+	JWTSecret string
+	// BootstrapUsername/BootstrapPasswordHash create the first admin user
+	// when fin_users is empty (legacy single-user setup).
+	BootstrapUsername     string
+	BootstrapPasswordHash string
+	AllowSignup           bool
+	// TOTPIssuer is shown as the account issuer in authenticator apps.
+	TOTPIssuer string
+	LoginLimit LoginLimitConfig
+}
+
+// LoginLimitConfig controls brute-force protection on login: each failure
+// doubles the wait starting at BackoffBase (capped at BackoffMax), and
+// MaxFailures consecutive failures lock the username (MaxFailuresPerIP the
+// client IP) for Lockout.
+type LoginLimitConfig struct {
+	MaxFailures      int
+	MaxFailuresPerIP int
+	Lockout          time.Duration
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+}
+
+// TransferConfig controls which account types transfers may move money
+// between. AllowedPairs is keyed by the source account type and then the
+// destination type.
+type TransferConfig struct {
+	AllowedPairs map[string]map[string]bool
+}
+
+// defaultTransferPairs covers moving cash between wallets, paying off or
+// drawing on credit (liability), lending and collecting debts, and funding or
+// withdrawing from investment accounts.
+const defaultTransferPairs = "cash:cash,cash:liability,liability:cash,liability:liability," +
+	"cash:debt,debt:cash,cash:investment,investment:cash,investment:investment"
+
 func Load() Config {
 	loadDotEnv()
 
 	return Config{
-		AppEnv:   getenv("APP_ENV", "development"),
-		HTTPPort: getenv("HTTP_PORT", "8888"),
+		AppEnv:         getenv("APP_ENV", "development"),
+		HTTPPort:       getenv("HTTP_PORT", "8888"),
+		TrustedProxies: parseList(getenv("TRUSTED_PROXIES", "")),
 		DB: DBConfig{
 			Driver:   getenv("DB_DRIVER", "postgres"), // postgres | mysql
 			Host:     getenv("DB_HOST", "127.0.0.1"),
@@ -43,6 +89,23 @@ func Load() Config {
 			SSLMode:  getenv("DB_SSLMODE", "disable"),
 			Timezone: getenv("DB_TIMEZONE", "Asia/Shanghai"),
 		},
+		Auth: AuthConfig{
+			JWTSecret:             strings.TrimSpace(getenv("AUTH_JWT_SECRET", "")),
+			BootstrapUsername:     strings.TrimSpace(getenv("AUTH_USERNAME", "")),
+			BootstrapPasswordHash: strings.TrimSpace(getenv("AUTH_PASSWORD_HASH", "")),
+			AllowSignup:           getenvBool("AUTH_ALLOW_SIGNUP", false),
+			TOTPIssuer:            strings.TrimSpace(getenv("AUTH_TOTP_ISSUER", "Finance")),
+			LoginLimit: LoginLimitConfig{
+				MaxFailures:      getenvInt("AUTH_LOGIN_MAX_FAILURES", 5),
+				MaxFailuresPerIP: getenvInt("AUTH_LOGIN_MAX_FAILURES_PER_IP", 20),
+				Lockout:          getenvDuration("AUTH_LOGIN_LOCKOUT", 15*time.Minute),
+				BackoffBase:      getenvDuration("AUTH_LOGIN_BACKOFF_BASE", time.Second),
+				BackoffMax:       getenvDuration("AUTH_LOGIN_BACKOFF_MAX", time.Minute),
+			},
+		},
+		Transfer: TransferConfig{
+			AllowedPairs: parseTypePairs(getenv("TRANSFER_ALLOWED_PAIRS", defaultTransferPairs)),
+		},
 	}
 }
 
@@ -123,3 +186,63 @@ func getenv(key, def string) string {
 	}
 	return def
 }
+
+func getenvBool(key string, def bool) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
+		return def
+	}
+}
+
+func getenvInt(key string, def int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// parseList splits a comma-separated value, dropping empty items.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTypePairs parses a comma-separated list of from:to account type pairs.
+// Malformed entries are skipped.
+func parseTypePairs(value string) map[string]map[string]bool {
+	pairs := map[string]map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		from, to, ok := strings.Cut(item, ":")
+		from = strings.ToLower(strings.TrimSpace(from))
+		to = strings.ToLower(strings.TrimSpace(to))
+		if !ok || from == "" || to == "" {
+			if strings.TrimSpace(item) != "" {
+				log.Printf("config: ignoring transfer pair %q", item)
+			}
+			continue
+		}
+		if pairs[from] == nil {
+			pairs[from] = map[string]bool{}
+		}
+		pairs[from][to] = true
+	}
+	return pairs
+}
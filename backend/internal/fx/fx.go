@@ -0,0 +1,167 @@
+// Package fx converts amounts between currencies using a ledger's
+// fin_exchange_rates table.
+//
+// A rate applies from its date until the next rate for the same pair. When only
+// the opposite pair is recorded the converter divides by it instead.
+package fx
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// divisionPrecision is the number of decimal places kept when converting
+// through an inverse rate, before the result is rounded to the target currency.
+const divisionPrecision = 16
+
+// Rate is the effective rate used for a conversion.
+type Rate struct {
+	From   string
+	To     string
+	RateOn time.Time
+	// Value converts one unit of From into To.
+	Value decimal.Decimal
+	// Inverse reports that Value was derived from the To/From rate.
+	Inverse bool
+	inverse decimal.Decimal
+}
+
+// Converter looks up rates for a single ledger and date. Results are cached,
+// so a converter should not outlive the request that created it.
+type Converter struct {
+	db       *gorm.DB
+	ledgerID int
+	asOf     time.Time
+	cache    map[string]*Rate
+}
+
+func NewConverter(db *gorm.DB, ledgerID int, asOf time.Time) *Converter {
+	return &Converter{db: db, ledgerID: ledgerID, asOf: asOf, cache: map[string]*Rate{}}
+}
+
+// Rate returns the latest rate from -> to on or before the converter's date.
+// ok is false when no rate has been recorded in either direction.
+func (c *Converter) Rate(from, to string) (Rate, bool, error) {
+	from = normalize(from)
+	to = normalize(to)
+	if from == to {
+		return Rate{From: from, To: to, RateOn: c.asOf, Value: decimal.NewFromInt(1)}, true, nil
+	}
+
+	key := from + "/" + to
+	if cached, ok := c.cache[key]; ok {
+		if cached == nil {
+			return Rate{}, false, nil
+		}
+		return *cached, true, nil
+	}
+
+	// Prefer the direct pair when both directions were recorded on the same day.
+	var rows []model.ExchangeRate
+	if err := c.db.Where("ledger_id = ? AND rate_on <= ?", c.ledgerID, c.asOf).
+		Where("(from_currency = ? AND to_currency = ?) OR (from_currency = ? AND to_currency = ?)", from, to, to, from).
+		Order("rate_on desc").
+		Limit(2).
+		Find(&rows).Error; err != nil {
+		return Rate{}, false, err
+	}
+	if len(rows) == 0 {
+		c.cache[key] = nil
+		return Rate{}, false, nil
+	}
+	row := rows[0]
+	if len(rows) == 2 && rows[1].RateOn.Equal(row.RateOn) && rows[1].FromCurrency == from {
+		row = rows[1]
+	}
+
+	rate := newRate(from, to, row)
+	c.cache[key] = &rate
+	return rate, true, nil
+}
+
+// Convert converts amount from -> to and rounds it to the minor unit of to.
+// ok is false when no rate is available; the returned amount is then zero.
+func (c *Converter) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, bool, error) {
+	rate, ok, err := c.Rate(from, to)
+	if err != nil || !ok {
+		return decimal.Zero, false, err
+	}
+	return rate.Apply(amount), true, nil
+}
+
+// History looks up rates for a single ledger on many dates. Each currency
+// pair is loaded once, up to the history's end date, and resolved in memory
+// with the same rules as Converter. Like Converter it belongs to one request.
+type History struct {
+	db       *gorm.DB
+	ledgerID int
+	until    time.Time
+	pairs    map[string][]model.ExchangeRate
+}
+
+func NewHistory(db *gorm.DB, ledgerID int, until time.Time) *History {
+	return &History{db: db, ledgerID: ledgerID, until: until, pairs: map[string][]model.ExchangeRate{}}
+}
+
+// Rate returns the latest rate from -> to on or before on, which must not be
+// after the history's end date. ok is false when no such rate exists.
+func (h *History) Rate(from, to string, on time.Time) (Rate, bool, error) {
+	from = normalize(from)
+	to = normalize(to)
+	if from == to {
+		return Rate{From: from, To: to, RateOn: on, Value: decimal.NewFromInt(1)}, true, nil
+	}
+
+	key := from + "/" + to
+	rows, ok := h.pairs[key]
+	if !ok {
+		if err := h.db.Where("ledger_id = ? AND rate_on <= ?", h.ledgerID, h.until).
+			Where("(from_currency = ? AND to_currency = ?) OR (from_currency = ? AND to_currency = ?)", from, to, to, from).
+			Order("rate_on").
+			Find(&rows).Error; err != nil {
+			return Rate{}, false, err
+		}
+		h.pairs[key] = rows
+	}
+
+	day := on.Format("2006-01-02")
+	n := sort.Search(len(rows), func(i int) bool { return rows[i].RateOn.Format("2006-01-02") > day })
+	if n == 0 {
+		return Rate{}, false, nil
+	}
+	row := rows[n-1]
+	// Prefer the direct pair when both directions were recorded on the same day.
+	if n >= 2 && rows[n-2].RateOn.Equal(row.RateOn) && rows[n-2].FromCurrency == from {
+		row = rows[n-2]
+	}
+	return newRate(from, to, row), true, nil
+}
+
+func newRate(from, to string, row model.ExchangeRate) Rate {
+	rate := Rate{From: from, To: to, RateOn: row.RateOn, Value: row.Rate}
+	if row.FromCurrency != from {
+		rate.Inverse = true
+		rate.inverse = row.Rate
+		rate.Value = decimal.NewFromInt(1).DivRound(row.Rate, money.PriceScale)
+	}
+	return rate
+}
+
+// Apply converts amount with r and rounds it to the minor unit of r.To.
+func (r Rate) Apply(amount decimal.Decimal) decimal.Decimal {
+	if r.Inverse {
+		return money.Round(amount.DivRound(r.inverse, divisionPrecision), r.To)
+	}
+	return money.Round(amount.Mul(r.Value), r.To)
+}
+
+func normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
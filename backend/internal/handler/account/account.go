@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
 
 	"github.com/gin-gonic/gin"
@@ -79,13 +80,9 @@ func (h Handler) create(c *gin.Context) {
 		isActive = *req.IsActive
 	}
 
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	currency := strings.TrimSpace(req.Currency)
@@ -109,10 +106,15 @@ func (h Handler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, account)
 }
 
-// list 查询全部账户，按 id 排序返回。
+// list 查询账本下的全部账户，按 id 排序返回。
 func (h Handler) list(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
 	var accounts []model.Account
-	if err := h.db.Order("id").Find(&accounts).Error; err != nil {
+	if err := h.db.Where("ledger_id = ?", ledgerID).Order("id").Find(&accounts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query accounts"})
 		return
 	}
@@ -139,6 +141,10 @@ func (h Handler) get(c *gin.Context) {
 		return
 	}
 
+	if !ledger.Check(c, h.db, account.LedgerID, model.LedgerRoleViewer) {
+		return
+	}
+
 	c.JSON(http.StatusOK, account)
 }
 
@@ -189,6 +195,10 @@ func (h Handler) update(c *gin.Context) {
 		return
 	}
 
+	if !h.checkAccountLedger(c, id, model.LedgerRoleEditor) {
+		return
+	}
+
 	tx := h.db.Model(&model.Account{}).Where("id = ?", id).Updates(updates)
 	if tx.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update account"})
@@ -216,6 +226,10 @@ func (h Handler) delete(c *gin.Context) {
 		return
 	}
 
+	if !h.checkAccountLedger(c, id, model.LedgerRoleEditor) {
+		return
+	}
+
 	tx := h.db.Delete(&model.Account{}, id)
 	if tx.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
@@ -229,6 +243,21 @@ func (h Handler) delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// checkAccountLedger 加载账户并确认当前用户在其账本上拥有 role 角色；失败时已写入响应。
+func (h Handler) checkAccountLedger(c *gin.Context, id uint, role model.LedgerRole) bool {
+	var account model.Account
+	err := h.db.Select("id, ledger_id").First(&account, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
+		return false
+	}
+	return ledger.Check(c, h.db, account.LedgerID, role)
+}
+
 // parseID 将路径参数转换为 uint，失败返回 false。
 func parseID(raw string) (uint, bool) {
 	id, err := strconv.ParseUint(raw, 10, 64)
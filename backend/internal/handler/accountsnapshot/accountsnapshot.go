@@ -8,10 +8,13 @@ import (
 	"strings"
 	"time"
 
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
+	"finance-backend/internal/money"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -30,17 +33,17 @@ func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 }
 
 type createSnapshotRequest struct {
-	LedgerID  *int    `json:"ledger_id"`
-	AccountID uint    `json:"account_id" binding:"required,gt=0"`
-	AsOf      string  `json:"as_of" binding:"required"`
-	Amount    float64 `json:"amount"`
-	Note      string  `json:"note"`
+	LedgerID  *int            `json:"ledger_id"`
+	AccountID uint            `json:"account_id" binding:"required,gt=0"`
+	AsOf      string          `json:"as_of" binding:"required"`
+	Amount    decimal.Decimal `json:"amount"`
+	Note      string          `json:"note"`
 }
 
 type updateSnapshotRequest struct {
-	AsOf   *string  `json:"as_of"`
-	Amount *float64 `json:"amount"`
-	Note   *string  `json:"note"`
+	AsOf   *string          `json:"as_of"`
+	Amount *decimal.Decimal `json:"amount"`
+	Note   *string          `json:"note"`
 }
 
 func (h Handler) create(c *gin.Context) {
@@ -50,13 +53,9 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	asOfRaw := strings.TrimSpace(req.AsOf)
@@ -66,7 +65,8 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	if err := validateAccount(h.db, ledgerID, req.AccountID); err != nil {
+	account, err := validateAccount(h.db, ledgerID, req.AccountID)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -75,7 +75,7 @@ func (h Handler) create(c *gin.Context) {
 		LedgerID:  ledgerID,
 		AccountID: req.AccountID,
 		AsOf:      asOf,
-		Amount:    req.Amount,
+		Amount:    money.Round(req.Amount, account.Currency),
 		Note:      strings.TrimSpace(req.Note),
 	}
 
@@ -88,14 +88,9 @@ func (h Handler) create(c *gin.Context) {
 }
 
 func (h Handler) list(c *gin.Context) {
-	ledgerID := 1
-	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
-		parsed, err := strconv.Atoi(value)
-		if err != nil || parsed <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
-			return
-		}
-		ledgerID = parsed
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
 	}
 
 	var accountID uint
@@ -140,6 +135,10 @@ func (h Handler) get(c *gin.Context) {
 		return
 	}
 
+	if !ledger.Check(c, h.db, snapshot.LedgerID, model.LedgerRoleViewer) {
+		return
+	}
+
 	c.JSON(http.StatusOK, snapshot)
 }
 
@@ -177,6 +176,10 @@ func (h Handler) update(c *gin.Context) {
 		return
 	}
 
+	if !ledger.Check(c, h.db, snapshot.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
 	if _, ok := raw["as_of"]; ok {
 		if req.AsOf == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of cannot be null"})
@@ -195,7 +198,12 @@ func (h Handler) update(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "amount cannot be null"})
 			return
 		}
-		snapshot.Amount = *req.Amount
+		account, err := validateAccount(h.db, snapshot.LedgerID, snapshot.AccountID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		snapshot.Amount = money.Round(*req.Amount, account.Currency)
 	}
 
 	if _, ok := raw["note"]; ok {
@@ -221,6 +229,21 @@ func (h Handler) delete(c *gin.Context) {
 		return
 	}
 
+	var snapshot model.AccountSnapshot
+	err := h.db.First(&snapshot, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load snapshot"})
+		return
+	}
+
+	if !ledger.Check(c, h.db, snapshot.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
 	tx := h.db.Delete(&model.AccountSnapshot{}, id)
 	if tx.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete snapshot"})
@@ -242,13 +265,13 @@ func parseID(raw string) (uint, bool) {
 	return uint(value), true
 }
 
-func validateAccount(db *gorm.DB, ledgerID int, accountID uint) error {
+func validateAccount(db *gorm.DB, ledgerID int, accountID uint) (model.Account, error) {
 	var account model.Account
 	if err := db.Where("id = ? AND ledger_id = ?", accountID, ledgerID).First(&account).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("account not found")
+			return model.Account{}, errors.New("account not found")
 		}
-		return err
+		return model.Account{}, err
 	}
-	return nil
+	return account, nil
 }
@@ -2,24 +2,47 @@ package auth
 
 import (
 	"errors"
+	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"finance-backend/internal/config"
+	"finance-backend/internal/model"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 const (
-	defaultTTLHours    = 24
-	rememberTTLHours   = 24 * 30
-	authHeaderPrefix   = "Bearer "
-	claimsIssuer       = "finance-backend"
-	contextUsernameKey = "auth_username"
+	defaultTTLHours     = 24
+	rememberTTLHours    = 24 * 30
+	accessTTL           = 15 * time.Minute
+	authHeaderPrefix    = "Bearer "
+	claimsIssuer        = "finance-backend"
+	contextUsernameKey  = "auth_username"
+	contextUserIDKey    = "auth_user_id"
+	contextIsAdminKey   = "auth_is_admin"
+	contextSessionIDKey = "auth_session_id"
+	contextAPITokenKey  = "auth_api_token"
+	apiTokenPrefix      = "fin_pat_"
 )
 
+type Handler struct {
+	db      *gorm.DB
+	cfg     config.AuthConfig
+	limiter *loginLimiter
+}
+
+type tokenClaims struct {
+	Username  string `json:"username"`
+	SessionID uint   `json:"sid"`
+	jwt.RegisteredClaims
+}
+
 type loginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -27,20 +50,48 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt string `json:"expires_at"`
+	Token            string `json:"token"`
+	ExpiresAt        string `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+}
+
+type meLedger struct {
+	LedgerID int              `json:"ledger_id"`
+	Name     string           `json:"name"`
+	Role     model.LedgerRole `json:"role"`
 }
 
 type meResponse struct {
-	Username string `json:"username"`
+	ID       uint       `json:"id"`
+	Username string     `json:"username"`
+	IsAdmin  bool       `json:"is_admin"`
+	Ledgers  []meLedger `json:"ledgers"`
 }
 
-func RegisterRoutes(rg *gin.RouterGroup) {
-	rg.POST("/login", login)
-	rg.GET("/me", me)
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB, cfg config.AuthConfig) {
+	h := Handler{db: db, cfg: cfg, limiter: newLoginLimiter(cfg.LoginLimit)}
+
+	rg.POST("/login", h.login)
+	rg.POST("/login/mfa", h.loginMFA)
+	rg.POST("/register", h.register)
+	rg.POST("/refresh", h.refresh)
+	rg.POST("/logout", h.logout)
+	rg.POST("/logout-all", h.logoutAll)
+	rg.GET("/sessions", h.listSessions)
+	rg.POST("/tokens", h.createToken)
+	rg.GET("/tokens", h.listTokens)
+	rg.DELETE("/tokens/:id", h.revokeToken)
+	rg.GET("/me", h.me)
+	rg.POST("/2fa/setup", h.setupTOTP)
+	rg.POST("/2fa/enable", h.enableTOTP)
+	rg.POST("/2fa/disable", h.disableTOTP)
+	rg.POST("/invites", h.createInvite)
+	rg.GET("/users", h.listUsers)
+	rg.PATCH("/users/:id", h.updateUser)
 }
 
-func login(c *gin.Context) {
+func (h Handler) login(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -53,68 +104,147 @@ func login(c *gin.Context) {
 		return
 	}
 
-	configUsername := strings.TrimSpace(os.Getenv("AUTH_USERNAME"))
-	configHash := strings.TrimSpace(os.Getenv("AUTH_PASSWORD_HASH"))
-	secret := strings.TrimSpace(os.Getenv("AUTH_JWT_SECRET"))
-
-	if configUsername == "" || configHash == "" || secret == "" {
+	if h.cfg.JWTSecret == "" {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "auth is not configured"})
 		return
 	}
 
-	if username != configUsername {
+	attempt, ok := h.beginLoginAttempt(c, username)
+	if !ok {
+		return
+	}
+	defer attempt.finish()
+
+	var user model.User
+	err := h.db.Where("username = ? AND is_active = ?", username, true).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h.loginFailed(c, attempt, model.LoginFailureInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(configHash), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.loginFailed(c, attempt, model.LoginFailureInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
+	if user.TOTPEnabled {
+		// 第二步成功后才清零失败计数。
+		pending, err := h.issueMFAToken(user, req.Remember)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue mfa token"})
+			return
+		}
+		c.JSON(http.StatusOK, pending)
+		return
+	}
+
 	ttl := time.Duration(defaultTTLHours) * time.Hour
 	if req.Remember {
 		ttl = time.Duration(rememberTTLHours) * time.Hour
 	}
 
-	expiresAt := time.Now().Add(ttl)
-	claims := jwt.RegisteredClaims{
-		Issuer:    claimsIssuer,
-		Subject:   username,
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(secret))
+	resp, err := h.startSession(c, user, ttl)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{
-		Token:     signed,
-		ExpiresAt: expiresAt.Format(time.RFC3339),
-	})
+	h.limiter.reset(username)
+	c.JSON(http.StatusOK, resp)
 }
 
-func me(c *gin.Context) {
-	username, _ := c.Get(contextUsernameKey)
-	name, _ := username.(string)
-	if name == "" {
+func (h Handler) me(c *gin.Context) {
+	userID := UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	c.JSON(http.StatusOK, meResponse{Username: name})
+
+	ledgers := make([]meLedger, 0)
+	if err := h.db.Table("fin_ledger_members m").
+		Joins("JOIN fin_ledgers l ON l.id = m.ledger_id AND l.deleted_at IS NULL").
+		Where("m.user_id = ?", userID).
+		Select("m.ledger_id, l.name, m.role").
+		Order("m.ledger_id").
+		Scan(&ledgers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query ledgers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, meResponse{
+		ID:       userID,
+		Username: c.GetString(contextUsernameKey),
+		IsAdmin:  IsAdmin(c),
+		Ledgers:  ledgers,
+	})
+}
+
+// UserID 返回当前请求的登录用户 ID，未登录时为 0。
+func UserID(c *gin.Context) uint {
+	value, _ := c.Get(contextUserIDKey)
+	id, _ := value.(uint)
+	return id
+}
+
+// IsAdmin 报告当前登录用户是否为系统管理员。
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool(contextIsAdminKey)
+}
+
+// Bootstrap 在 fin_users 为空时，用 AUTH_USERNAME/AUTH_PASSWORD_HASH 创建管理员，
+// 并授予其全部已有账本的 owner 权限，兼容旧的单用户部署。
+func Bootstrap(db *gorm.DB, cfg config.AuthConfig) error {
+	var count int64
+	if err := db.Unscoped().Model(&model.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 || cfg.BootstrapUsername == "" || cfg.BootstrapPasswordHash == "" {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		user := model.User{
+			Username:     cfg.BootstrapUsername,
+			PasswordHash: cfg.BootstrapPasswordHash,
+			IsAdmin:      true,
+			IsActive:     true,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		var ledgerIDs []int
+		if err := tx.Model(&model.Ledger{}).Order("id").Pluck("id", &ledgerIDs).Error; err != nil {
+			return err
+		}
+		for _, ledgerID := range ledgerIDs {
+			member := model.LedgerMember{LedgerID: ledgerID, UserID: user.ID, Role: model.LedgerRoleOwner}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+
+		log.Printf("auth: bootstrapped admin user %s", user.Username)
+		return nil
+	})
 }
 
-func Middleware() gin.HandlerFunc {
+func Middleware(db *gorm.DB, cfg config.AuthConfig) gin.HandlerFunc {
 	skipPaths := map[string]struct{}{
-		"/api/health":     {},
-		"/api/auth/login": {},
+		"/api/health":         {},
+		"/api/auth/login":     {},
+		"/api/auth/login/mfa": {},
+		"/api/auth/register":  {},
+		"/api/auth/refresh":   {},
 	}
 
-	secret := strings.TrimSpace(os.Getenv("AUTH_JWT_SECRET"))
+	secret := cfg.JWTSecret
 	return func(c *gin.Context) {
 		if _, ok := skipPaths[c.FullPath()]; ok {
 			c.Next()
@@ -144,26 +274,85 @@ func Middleware() gin.HandlerFunc {
 			return
 		}
 
-		parsed, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if token.Method != jwt.SigningMethodHS256 {
-				return nil, errors.New("unexpected signing method")
+		var (
+			userID uint
+			err    error
+		)
+		if strings.HasPrefix(raw, apiTokenPrefix) {
+			userID, err = authenticateAPIToken(c, db, raw)
+		} else {
+			userID, err = authenticateSession(c, db, secret, raw)
+		}
+		if err != nil {
+			var authErr authError
+			if errors.As(err, &authErr) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": authErr.Error()})
+				c.Abort()
+				return
 			}
-			return []byte(secret), nil
-		})
-		if err != nil || !parsed.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to authenticate"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := parsed.Claims.(*jwt.RegisteredClaims)
-		if !ok || claims.Subject == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		var user model.User
+		if err := db.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "user is disabled"})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
 			c.Abort()
 			return
 		}
 
-		c.Set(contextUsernameKey, claims.Subject)
+		c.Set(contextUserIDKey, user.ID)
+		c.Set(contextUsernameKey, user.Username)
+		c.Set(contextIsAdminKey, user.IsAdmin)
 		c.Next()
 	}
 }
+
+// authenticateSession 校验 JWT access token 及其服务端会话，返回用户 ID。
+func authenticateSession(c *gin.Context, db *gorm.DB, secret string, raw string) (uint, error) {
+	parsed, err := jwt.ParseWithClaims(raw, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil || !parsed.Valid {
+		return 0, authError{message: "invalid token"}
+	}
+
+	claims, ok := parsed.Claims.(*tokenClaims)
+	if !ok || claims.Subject == "" {
+		return 0, authError{message: "invalid token"}
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 || claims.SessionID == 0 {
+		return 0, authError{message: "invalid token"}
+	}
+
+	var session model.AuthSession
+	if err := db.Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", claims.SessionID, userID, time.Now()).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, authError{message: "session revoked"}
+		}
+		return 0, err
+	}
+
+	c.Set(contextSessionIDKey, session.ID)
+	return uint(userID), nil
+}
+
+type authError struct {
+	message string
+}
+
+func (e authError) Error() string {
+	return e.message
+}
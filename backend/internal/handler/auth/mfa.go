@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+	"finance-backend/internal/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mfaAudience       = "mfa"
+	mfaTokenTTL       = 5 * time.Minute
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid verification code")
+
+type mfaClaims struct {
+	Remember bool `json:"remember"`
+	jwt.RegisteredClaims
+}
+
+type mfaPendingResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+type loginMFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type secondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type totpSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type totpEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// issueMFAToken 签发只能用于 /login/mfa 的短期令牌，密码校验通过但尚未完成第二步时使用。
+func (h Handler) issueMFAToken(user model.User, remember bool) (mfaPendingResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(mfaTokenTTL)
+
+	claims := mfaClaims{
+		Remember: remember,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    claimsIssuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.cfg.JWTSecret))
+	if err != nil {
+		return mfaPendingResponse{}, err
+	}
+
+	return mfaPendingResponse{
+		MFARequired: true,
+		MFAToken:    signed,
+		ExpiresAt:   expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// loginMFA 用 mfa_token 加 6 位验证码（或恢复码）换取正式的 access/refresh token。
+func (h Handler) loginMFA(c *gin.Context) {
+	var req loginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.cfg.JWTSecret == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "auth is not configured"})
+		return
+	}
+
+	parsed, err := jwt.ParseWithClaims(strings.TrimSpace(req.MFAToken), &mfaClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(h.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(mfaAudience))
+	if err != nil || !parsed.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid mfa token"})
+		return
+	}
+
+	claims, ok := parsed.Claims.(*mfaClaims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid mfa token"})
+		return
+	}
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid mfa token"})
+		return
+	}
+
+	var user model.User
+	if err := h.db.Where("id = ? AND is_active = ? AND totp_enabled = ?", userID, true, true).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid mfa token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+
+	attempt, ok := h.beginLoginAttempt(c, user.Username)
+	if !ok {
+		return
+	}
+	defer attempt.finish()
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, user.ID).Error; err != nil {
+			return err
+		}
+		return verifySecondFactor(tx, &user, req.Code, req.RecoveryCode, time.Now())
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		h.loginFailed(c, attempt, model.LoginFailureInvalidMFA)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		return
+	}
+
+	ttl := time.Duration(defaultTTLHours) * time.Hour
+	if claims.Remember {
+		ttl = time.Duration(rememberTTLHours) * time.Hour
+	}
+
+	resp, err := h.startSession(c, user, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+
+	h.limiter.reset(user.Username)
+	c.JSON(http.StatusOK, resp)
+}
+
+// setupTOTP 生成新的 TOTP 密钥（尚未启用），返回供认证器扫码的 otpauth URI。
+func (h Handler) setupTOTP(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var user model.User
+	if err := h.db.First(&user, UserID(c)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2fa is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+
+	if err := h.db.Model(&model.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, totpSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: totp.ProvisioningURI(h.cfg.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// enableTOTP 用认证器当前验证码确认密钥后启用 2FA，并一次性返回恢复码。
+func (h Handler) enableTOTP(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var req secondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, UserID(c)).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabled {
+			return newRequestError("2fa is already enabled")
+		}
+		if user.TOTPSecret == "" {
+			return newRequestError("2fa setup has not been started")
+		}
+
+		step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), totpSkew)
+		if !ok {
+			return newRequestError(errInvalidSecondFactor.Error())
+		}
+
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).
+			Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable 2fa"})
+		return
+	}
+
+	c.JSON(http.StatusOK, totpEnableResponse{RecoveryCodes: codes})
+}
+
+// disableTOTP 关闭 2FA，需提供当前验证码或一枚未使用的恢复码。
+func (h Handler) disableTOTP(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var req secondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, UserID(c)).Error; err != nil {
+			return err
+		}
+		if !user.TOTPEnabled {
+			return newRequestError("2fa is not enabled")
+		}
+		if err := verifySecondFactor(tx, &user, req.Code, req.RecoveryCode, time.Now()); err != nil {
+			if errors.Is(err, errInvalidSecondFactor) {
+				return newRequestError(err.Error())
+			}
+			return err
+		}
+
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).
+			Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&model.UserRecoveryCode{}).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable 2fa"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码；user 须已在 tx 中加锁。
+// 验证码按时间步防重放，恢复码使用后即作废。
+func verifySecondFactor(tx *gorm.DB, user *model.User, code, recoveryCode string, now time.Time) error {
+	if code = strings.TrimSpace(code); code != "" {
+		step, ok := totp.Validate(user.TOTPSecret, code, now, totpSkew)
+		if !ok || step <= user.TOTPLastStep {
+			return errInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return tx.Model(&model.User{}).Where("id = ?", user.ID).Update("totp_last_step", step).Error
+	}
+
+	normalized := normalizeRecoveryCode(recoveryCode)
+	if normalized == "" {
+		return errInvalidSecondFactor
+	}
+
+	var stored model.UserRecoveryCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalized)).
+		First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+
+	return tx.Model(&stored).Update("used_at", now).Error
+}
+
+// replaceRecoveryCodes 作废旧恢复码并生成一批新的，明文只返回这一次。
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]model.UserRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		rows = append(rows, model.UserRecoveryCode{UserID: userID, CodeHash: hashToken(raw)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.ReplaceAll(value, "-", "")
+	return strings.ReplaceAll(value, " ", "")
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type sessionResponse struct {
+	ID         uint   `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+// startSession 为登录成功的用户创建服务端会话，签发短期 access token 与首个 refresh token。
+// ttl 决定会话（即 refresh token 链）的最长寿命。
+func (h Handler) startSession(c *gin.Context, user model.User, ttl time.Duration) (loginResponse, error) {
+	now := time.Now()
+	var resp loginResponse
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		session := model.AuthSession{
+			UserID:     user.ID,
+			UserAgent:  truncate(c.Request.UserAgent(), 255),
+			IP:         c.ClientIP(),
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(ttl),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		resp, err = h.issueTokens(tx, user, session)
+		return err
+	})
+
+	return resp, err
+}
+
+// issueTokens 为会话签发新的 access token，并写入一枚新的 refresh token。
+func (h Handler) issueTokens(tx *gorm.DB, user model.User, session model.AuthSession) (loginResponse, error) {
+	now := time.Now()
+
+	accessExpiresAt := now.Add(accessTTL)
+	if accessExpiresAt.After(session.ExpiresAt) {
+		accessExpiresAt = session.ExpiresAt
+	}
+
+	claims := tokenClaims{
+		Username:  user.Username,
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    claimsIssuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.cfg.JWTSecret))
+	if err != nil {
+		return loginResponse{}, err
+	}
+
+	rawRefresh, err := randomToken(32)
+	if err != nil {
+		return loginResponse{}, err
+	}
+
+	refreshToken := model.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(rawRefresh),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return loginResponse{}, err
+	}
+
+	return loginResponse{
+		Token:            signed,
+		ExpiresAt:        accessExpiresAt.Format(time.RFC3339),
+		RefreshToken:     rawRefresh,
+		RefreshExpiresAt: refreshToken.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// refresh 用 refresh token 换取新的 access/refresh token（轮换）。
+// 已使用过的 refresh token 再次出现视为泄露，整条会话立即吊销。
+func (h Handler) refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.cfg.JWTSecret == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "auth is not configured"})
+		return
+	}
+
+	var resp loginResponse
+	reused := false
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var token model.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(strings.TrimSpace(req.RefreshToken))).
+			First(&token).Error; err != nil {
+			return err
+		}
+
+		var session model.AuthSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, token.SessionID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil {
+			reused = true
+			if session.RevokedAt == nil {
+				session.RevokedAt = &now
+				return tx.Save(&session).Error
+			}
+			return nil
+		}
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) || !now.Before(token.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+
+		var user model.User
+		if err := tx.Where("id = ? AND is_active = ?", session.UserID, true).First(&user).Error; err != nil {
+			return err
+		}
+
+		token.UsedAt = &now
+		if err := tx.Save(&token).Error; err != nil {
+			return err
+		}
+		session.LastSeenAt = now
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		resp, err = h.issueTokens(tx, user, session)
+		return err
+	})
+
+	if reused && err == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// logout 吊销当前 access token 所属的会话。
+func (h Handler) logout(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	sessionID := c.GetUint(contextSessionIDKey)
+	if sessionID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.db.Model(&model.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// logoutAll 吊销当前用户的全部会话（含当前会话），用于设备丢失等场景。
+func (h Handler) logoutAll(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	userID := UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	tx := h.db.Model(&model.AuthSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": tx.RowsAffected})
+}
+
+// listSessions 列出当前用户仍有效的会话。
+func (h Handler) listSessions(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	userID := UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var sessions []model.AuthSession
+	if err := h.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query sessions"})
+		return
+	}
+
+	current := c.GetUint(contextSessionIDKey)
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+			Current:    session.ID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"finance-backend/internal/config"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxLimiterEntries = 10000
+
+// loginLimiter 在内存中按 IP 和用户名记录失败次数：每次失败后按指数退避延长等待，
+// 连续失败达到上限后锁定一段时间。多实例部署时各实例独立计数。
+type loginLimiter struct {
+	cfg     config.LoginLimitConfig
+	mu      sync.Mutex
+	entries map[string]*attemptEntry
+}
+
+type attemptEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func newLoginLimiter(cfg config.LoginLimitConfig) *loginLimiter {
+	return &loginLimiter{cfg: cfg, entries: make(map[string]*attemptEntry)}
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// reserve 在同一把锁内检查限流并为本次尝试占位：可以尝试时预先按一次失败计数并返回 0，
+// 使并发请求在结果出来之前也受限；被限制时返回 keys 中最长的剩余等待时间，不计数。
+func (l *loginLimiter) reserve(now time.Time, ip, username string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := []string{ipKey(ip)}
+	if username != "" {
+		keys = append(keys, usernameKey(username))
+	}
+
+	var wait time.Duration
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok {
+			continue
+		}
+		if remaining := entry.blockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	l.fail(ipKey(ip), l.cfg.MaxFailuresPerIP, now)
+	if username != "" {
+		l.fail(usernameKey(username), l.cfg.MaxFailures, now)
+	}
+	return 0
+}
+
+// release 撤销 reserve 预记的一次失败，用于凭据正确或因服务端错误中止的尝试。
+func (l *loginLimiter) release(ip, username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.unfail(ipKey(ip), l.cfg.MaxFailuresPerIP)
+	if username != "" {
+		l.unfail(usernameKey(username), l.cfg.MaxFailures)
+	}
+}
+
+// fail 累加 key 的失败次数并计算下一次允许尝试的时间；调用方须持有锁。
+// 距上次失败超过锁定时长的记录重新计数。
+func (l *loginLimiter) fail(key string, maxFailures int, now time.Time) {
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.lastFailure) > l.cfg.Lockout {
+		if len(l.entries) >= maxLimiterEntries {
+			l.prune(now)
+		}
+		entry = &attemptEntry{}
+		l.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if maxFailures > 0 && entry.failures >= maxFailures {
+		entry.blockedUntil = now.Add(l.cfg.Lockout)
+		return
+	}
+	entry.blockedUntil = now.Add(l.backoff(entry.failures))
+}
+
+// unfail 减去 key 的一次失败并按剩余次数重算等待时间；调用方须持有锁。
+func (l *loginLimiter) unfail(key string, maxFailures int) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	entry.failures--
+	if entry.failures <= 0 {
+		delete(l.entries, key)
+		return
+	}
+	if maxFailures > 0 && entry.failures >= maxFailures {
+		entry.blockedUntil = entry.lastFailure.Add(l.cfg.Lockout)
+		return
+	}
+	entry.blockedUntil = entry.lastFailure.Add(l.backoff(entry.failures))
+}
+
+// reset 在登录成功后清除该用户名的失败记录；IP 维度的记录自然过期。
+func (l *loginLimiter) reset(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, usernameKey(username))
+}
+
+func (l *loginLimiter) backoff(failures int) time.Duration {
+	if l.cfg.BackoffBase <= 0 {
+		return 0
+	}
+	delay := time.Duration(float64(l.cfg.BackoffBase) * math.Pow(2, float64(failures-1)))
+	if delay <= 0 || (l.cfg.BackoffMax > 0 && delay > l.cfg.BackoffMax) {
+		delay = l.cfg.BackoffMax
+	}
+	return delay
+}
+
+func (l *loginLimiter) prune(now time.Time) {
+	for key, entry := range l.entries {
+		if now.After(entry.blockedUntil) && now.Sub(entry.lastFailure) > l.cfg.Lockout {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// loginAttempt 为一次已在限流器中占位的登录尝试。
+type loginAttempt struct {
+	limiter  *loginLimiter
+	ip       string
+	username string
+	failed   bool
+}
+
+// finish 在请求结束时调用：未标记失败的尝试撤销占位的失败计数。
+func (a *loginAttempt) finish() {
+	if !a.failed {
+		a.limiter.release(a.ip, a.username)
+	}
+}
+
+// beginLoginAttempt 在校验密码前检查限流并占位，被限制时返回 429 并写入审计记录。
+// 调用方须 defer 返回值的 finish。
+func (h Handler) beginLoginAttempt(c *gin.Context, username string) (*loginAttempt, bool) {
+	ip := c.ClientIP()
+	wait := h.limiter.reserve(time.Now(), ip, username)
+	if wait <= 0 {
+		return &loginAttempt{limiter: h.limiter, ip: ip, username: username}, true
+	}
+
+	h.auditLoginFailure(c, username, model.LoginFailureThrottled)
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later", "retry_after": seconds})
+	return nil, false
+}
+
+// loginFailed 将尝试标记为失败（保留占位时的计数）并写入审计记录。
+func (h Handler) loginFailed(c *gin.Context, attempt *loginAttempt, reason model.LoginFailureReason) {
+	attempt.failed = true
+	h.auditLoginFailure(c, attempt.username, reason)
+}
+
+func (h Handler) auditLoginFailure(c *gin.Context, username string, reason model.LoginFailureReason) {
+	attempt := model.LoginAttempt{
+		Username:  truncate(username, 255),
+		IP:        c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 255),
+		Reason:    reason,
+	}
+	if err := h.db.Create(&attempt).Error; err != nil {
+		log.Printf("auth: failed to record login attempt: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	apiTokenPrefixLength  = len(apiTokenPrefix) + 6
+	lastUsedWriteInterval = time.Minute
+)
+
+type createTokenRequest struct {
+	Name          string              `json:"name" binding:"required"`
+	Scope         model.APITokenScope `json:"scope" binding:"required"`
+	LedgerID      *int                `json:"ledger_id"`
+	ExpiresInDays int                 `json:"expires_in_days"`
+}
+
+type tokenResponse struct {
+	ID         uint                `json:"id"`
+	Name       string              `json:"name"`
+	Prefix     string              `json:"prefix"`
+	Scope      model.APITokenScope `json:"scope"`
+	LedgerID   *int                `json:"ledger_id"`
+	LastUsedAt *string             `json:"last_used_at"`
+	ExpiresAt  *string             `json:"expires_at"`
+	CreatedAt  string              `json:"created_at"`
+	Token      string              `json:"token,omitempty"`
+}
+
+// TokenScope 描述个人访问令牌对当前请求的限制。
+type TokenScope struct {
+	ReadOnly bool
+	LedgerID *int
+}
+
+// APITokenScope 返回当前请求所用个人访问令牌的限制；使用会话登录时 ok 为 false。
+func APITokenScope(c *gin.Context) (TokenScope, bool) {
+	value, exists := c.Get(contextAPITokenKey)
+	if !exists {
+		return TokenScope{}, false
+	}
+	token, ok := value.(model.APIToken)
+	if !ok {
+		return TokenScope{}, false
+	}
+	return TokenScope{
+		ReadOnly: token.Scope != model.APITokenScopeWrite,
+		LedgerID: token.LedgerID,
+	}, true
+}
+
+// RequireSession 拒绝使用个人访问令牌调用的账号管理类接口（令牌、邀请、成员等）。
+func RequireSession(c *gin.Context) bool {
+	if _, ok := APITokenScope(c); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed with an API token"})
+		return false
+	}
+	return true
+}
+
+// authenticateAPIToken 校验个人访问令牌并记录最近使用时间，返回所属用户 ID。
+func authenticateAPIToken(c *gin.Context, db *gorm.DB, raw string) (uint, error) {
+	var token model.APIToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, authError{message: "invalid token"}
+		}
+		return 0, err
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil {
+		return 0, authError{message: "token revoked"}
+	}
+	if token.ExpiresAt != nil && !now.Before(*token.ExpiresAt) {
+		return 0, authError{message: "token expired"}
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedWriteInterval {
+		if err := db.Model(&model.APIToken{}).Where("id = ?", token.ID).
+			UpdateColumn("last_used_at", now).Error; err != nil {
+			return 0, err
+		}
+		token.LastUsedAt = &now
+	}
+
+	c.Set(contextAPITokenKey, token)
+	return token.UserID, nil
+}
+
+// createToken 创建个人访问令牌，明文只在本次响应中返回。
+func (h Handler) createToken(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var req createTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if req.Scope != model.APITokenScopeRead && req.Scope != model.APITokenScopeWrite {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be read or write"})
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days cannot be negative"})
+		return
+	}
+
+	userID := UserID(c)
+
+	if req.LedgerID != nil {
+		var member model.LedgerMember
+		err := h.db.Where("ledger_id = ? AND user_id = ?", *req.LedgerID, userID).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no access to ledger"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load membership"})
+			return
+		}
+	}
+
+	secret, err := randomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	raw := apiTokenPrefix + secret
+
+	token := model.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:apiTokenPrefixLength],
+		TokenHash: hashToken(raw),
+		Scope:     req.Scope,
+		LedgerID:  req.LedgerID,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		return
+	}
+
+	resp := toTokenResponse(token)
+	resp.Token = raw
+	c.JSON(http.StatusCreated, resp)
+}
+
+// listTokens 列出当前用户未吊销的个人访问令牌（不含明文）。
+func (h Handler) listTokens(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var tokens []model.APIToken
+	if err := h.db.Where("user_id = ? AND revoked_at IS NULL", UserID(c)).
+		Order("id").
+		Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query tokens"})
+		return
+	}
+
+	resp := make([]tokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		resp = append(resp, toTokenResponse(token))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// revokeToken 吊销当前用户的一个个人访问令牌。
+func (h Handler) revokeToken(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	tx := h.db.Model(&model.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, UserID(c)).
+		Update("revoked_at", time.Now())
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func toTokenResponse(token model.APIToken) tokenResponse {
+	resp := tokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Prefix:    token.Prefix,
+		Scope:     token.Scope,
+		LedgerID:  token.LedgerID,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+	}
+	if token.LastUsedAt != nil {
+		value := token.LastUsedAt.Format(time.RFC3339)
+		resp.LastUsedAt = &value
+	}
+	if token.ExpiresAt != nil {
+		value := token.ExpiresAt.Format(time.RFC3339)
+		resp.ExpiresAt = &value
+	}
+	return resp
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	minPasswordLength     = 8
+	defaultInviteTTLHours = 72
+	maxInviteTTLHours     = 24 * 30
+)
+
+type registerRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	InviteCode string `json:"invite_code"`
+}
+
+type createInviteRequest struct {
+	LedgerID       *int              `json:"ledger_id"`
+	Role           *model.LedgerRole `json:"role"`
+	ExpiresInHours int               `json:"expires_in_hours"`
+}
+
+type createInviteResponse struct {
+	Code      string            `json:"code"`
+	LedgerID  *int              `json:"ledger_id"`
+	Role      *model.LedgerRole `json:"role"`
+	ExpiresAt string            `json:"expires_at"`
+}
+
+type updateUserRequest struct {
+	IsAdmin  *bool `json:"is_admin"`
+	IsActive *bool `json:"is_active"`
+}
+
+func (h Handler) register(c *gin.Context) {
+	var req registerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is required"})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 8 characters"})
+		return
+	}
+
+	inviteCode := strings.TrimSpace(req.InviteCode)
+	if inviteCode == "" && !h.cfg.AllowSignup {
+		c.JSON(http.StatusForbidden, gin.H{"error": "registration requires an invite code"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+
+	var user model.User
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Unscoped().Model(&model.User{}).Where("username = ?", username).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return newRequestError("username already taken")
+		}
+
+		var invite model.UserInvite
+		if inviteCode != "" {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("code_hash = ?", hashToken(inviteCode)).
+				First(&invite).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError("invalid invite code")
+				}
+				return err
+			}
+			if invite.UsedAt != nil || time.Now().After(invite.ExpiresAt) {
+				return newRequestError("invite code expired or already used")
+			}
+		}
+
+		user = model.User{
+			Username:     username,
+			PasswordHash: string(hash),
+			IsActive:     true,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		if invite.ID == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		invite.UsedAt = &now
+		invite.UsedBy = &user.ID
+		if err := tx.Save(&invite).Error; err != nil {
+			return err
+		}
+
+		if invite.LedgerID != nil && invite.Role != nil {
+			member := model.LedgerMember{LedgerID: *invite.LedgerID, UserID: user.ID, Role: *invite.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register user"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// createInvite 由管理员签发注册邀请；账本 owner 也可为自己的账本签发带角色的邀请。
+func (h Handler) createInvite(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+
+	var req createInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := UserID(c)
+
+	if req.LedgerID == nil {
+		if req.Role != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role requires ledger_id"})
+			return
+		}
+		if !IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin required"})
+			return
+		}
+	} else {
+		role := model.LedgerRoleViewer
+		if req.Role != nil {
+			role = *req.Role
+		}
+		if !role.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of: owner, editor, viewer"})
+			return
+		}
+		req.Role = &role
+
+		var member model.LedgerMember
+		err := h.db.Where("ledger_id = ? AND user_id = ?", *req.LedgerID, userID).First(&member).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load membership"})
+			return
+		}
+		if !member.Role.Allows(model.LedgerRoleOwner) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "ledger owner required"})
+			return
+		}
+	}
+
+	ttlHours := req.ExpiresInHours
+	if ttlHours <= 0 {
+		ttlHours = defaultInviteTTLHours
+	}
+	if ttlHours > maxInviteTTLHours {
+		ttlHours = maxInviteTTLHours
+	}
+
+	code, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate invite"})
+		return
+	}
+
+	invite := model.UserInvite{
+		CodeHash:  hashToken(code),
+		LedgerID:  req.LedgerID,
+		Role:      req.Role,
+		CreatedBy: userID,
+		ExpiresAt: time.Now().Add(time.Duration(ttlHours) * time.Hour),
+	}
+	if err := h.db.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createInviteResponse{
+		Code:      code,
+		LedgerID:  invite.LedgerID,
+		Role:      invite.Role,
+		ExpiresAt: invite.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+func (h Handler) listUsers(c *gin.Context) {
+	if !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin required"})
+		return
+	}
+
+	var users []model.User
+	if err := h.db.Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h Handler) updateUser(c *gin.Context) {
+	if !RequireSession(c) {
+		return
+	}
+	if !IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin required"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req updateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.IsAdmin != nil {
+		updates["is_admin"] = *req.IsAdmin
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+	if uint(id) == UserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot change your own admin or active flag"})
+		return
+	}
+
+	tx := h.db.Model(&model.User{}).Where("id = ?", id).Updates(updates)
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var user model.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func newRequestError(message string) error {
+	return requestError{message: message}
+}
+
+// randomToken 生成 n 字节随机数并以十六进制编码。
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken 对一次性展示的令牌/邀请码做 SHA-256，数据库只保存摘要。
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
 
 	"github.com/gin-gonic/gin"
@@ -49,15 +50,21 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	var category model.Category
-	if req.LedgerID == nil {
-		category.LedgerID = 1
-	} else {
-		category.LedgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
+
+	var category model.Category
+	category.LedgerID = ledgerID
 	category.Name = req.Name
 	category.Kind = req.Kind
-	category.ParentID = req.ParentID
+	if req.ParentID != nil && *req.ParentID != 0 {
+		if !h.checkParent(c, ledgerID, *req.ParentID, req.Kind) {
+			return
+		}
+		category.ParentID = req.ParentID
+	}
 
 	if err := h.db.Create(&category).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category"})
@@ -65,10 +72,16 @@ func (h Handler) create(c *gin.Context) {
 	}
 }
 func (h Handler) list(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
 	var categories []model.Category
 	if err := h.db.
 		Model(&model.Category{}).
 		Select("id, ledger_id, name, kind, parent_id, deleted_at").
+		Where("ledger_id = ?", ledgerID).
 		Order("id").
 		Find(&categories).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query categories"})
@@ -117,11 +130,29 @@ func (h Handler) update(c *gin.Context) {
 		return
 	}
 
+	if !ledger.Check(c, h.db, category.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
 	if _, ok := raw["ledger_id"]; ok {
 		if req.LedgerID == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id cannot be null"})
 			return
 		}
+		if !ledger.Check(c, h.db, *req.LedgerID, model.LedgerRoleEditor) {
+			return
+		}
+		if *req.LedgerID != category.LedgerID {
+			var children int64
+			if err := h.db.Model(&model.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load category"})
+				return
+			}
+			if children > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "category with children cannot move to another ledger"})
+				return
+			}
+		}
 		category.LedgerID = *req.LedgerID
 	}
 
@@ -161,28 +192,24 @@ func (h Handler) update(c *gin.Context) {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id cannot be self"})
 					return
 				}
-				var parent model.Category
-				parentErr := h.db.First(&parent, *req.ParentID).Error
-				if errors.Is(parentErr, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusBadRequest, gin.H{"error": "parent category not found"})
-					return
-				}
-				if parentErr != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load parent category"})
-					return
-				}
 				category.ParentID = req.ParentID
 			}
 		}
 	}
 
-	if string(category.Kind) != "" && category.ParentID != nil {
-		var parent model.Category
-		if err := h.db.First(&parent, *category.ParentID).Error; err == nil {
-			if parent.Kind != category.Kind {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "parent kind must match category kind"})
-				return
-			}
+	// 父分类须在分类（可能已改动的）账本内且类型一致。
+	if category.ParentID != nil {
+		if !h.checkParent(c, category.LedgerID, *category.ParentID, category.Kind) {
+			return
+		}
+		cycle, err := h.isDescendant(*category.ParentID, category.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load parent category"})
+			return
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id cannot be a descendant of the category"})
+			return
 		}
 	}
 
@@ -212,6 +239,10 @@ func (h Handler) delete(c *gin.Context) {
 		return
 	}
 
+	if !ledger.Check(c, h.db, category.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
 	var childCount int64
 	if err := h.db.Model(&model.Category{}).
 		Where("parent_id = ? AND deleted_at IS NULL", id).
@@ -237,6 +268,45 @@ func (h Handler) delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// checkParent 校验父分类属于同一账本且类型与分类一致，不满足时返回 400。
+func (h Handler) checkParent(c *gin.Context, ledgerID, parentID int, kind model.CategoryKind) bool {
+	var parent model.Category
+	err := h.db.Where("id = ? AND ledger_id = ?", parentID, ledgerID).First(&parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent category not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load parent category"})
+		return false
+	}
+	if parent.Kind != kind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent kind must match category kind"})
+		return false
+	}
+	return true
+}
+
+// isDescendant 沿父分类链向上查找，判断 id 是否为 ancestorID 的后代（含自身）。
+func (h Handler) isDescendant(id, ancestorID int) (bool, error) {
+	visited := map[int]bool{}
+	for !visited[id] {
+		if id == ancestorID {
+			return true, nil
+		}
+		visited[id] = true
+		var parentID *int
+		if err := h.db.Model(&model.Category{}).Where("id = ?", id).Select("parent_id").Scan(&parentID).Error; err != nil {
+			return false, err
+		}
+		if parentID == nil {
+			return false, nil
+		}
+		id = *parentID
+	}
+	return false, nil
+}
+
 func parseID(raw string) (uint, bool) {
 	id, err := strconv.ParseUint(raw, 10, 64)
 	if err != nil {
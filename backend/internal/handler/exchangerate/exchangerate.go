@@ -0,0 +1,441 @@
+package exchangerate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// maxBulkRates 限制单次批量上传的行数。
+const maxBulkRates = 5000
+
+type Handler struct {
+	db *gorm.DB
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	h := Handler{db: db}
+
+	rg.POST("", h.create)
+	rg.POST("/bulk", h.bulkUpsert)
+	rg.GET("", h.list)
+	rg.GET("/:id", h.get)
+	rg.PATCH("/:id", h.update)
+	rg.DELETE("/:id", h.delete)
+}
+
+type rateRequest struct {
+	RateOn       string          `json:"rate_on" binding:"required"`
+	FromCurrency string          `json:"from_currency" binding:"required"`
+	ToCurrency   string          `json:"to_currency" binding:"required"`
+	Rate         decimal.Decimal `json:"rate"`
+}
+
+type createRateRequest struct {
+	LedgerID *int `json:"ledger_id"`
+	rateRequest
+}
+
+type bulkRateRequest struct {
+	LedgerID *int          `json:"ledger_id"`
+	Rates    []rateRequest `json:"rates" binding:"required,min=1,dive"`
+}
+
+type updateRateRequest struct {
+	RateOn *string          `json:"rate_on"`
+	Rate   *decimal.Decimal `json:"rate"`
+}
+
+type bulkResponse struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+func (h Handler) create(c *gin.Context) {
+	var req createRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	rate, err := parseRate(req.rateRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate.LedgerID = ledgerID
+
+	var count int64
+	if err := h.db.Model(&model.ExchangeRate{}).
+		Where("ledger_id = ? AND rate_on = ? AND from_currency = ? AND to_currency = ?", ledgerID, rate.RateOn, rate.FromCurrency, rate.ToCurrency).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "exchange rate already exists for this date and currency pair"})
+		return
+	}
+
+	if err := h.db.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// bulkUpsert 批量写入汇率：同一账本、日期、币种对已存在时覆盖汇率。
+// 支持 JSON（{"ledger_id", "rates": [...]}）或 text/csv 请求体，
+// CSV 列为 rate_on,from_currency,to_currency,rate，首行可为表头，账本通过 ledger_id 查询参数指定。
+func (h Handler) bulkUpsert(c *gin.Context) {
+	var (
+		ledgerID int
+		requests []rateRequest
+		ok       bool
+	)
+
+	if c.ContentType() == "text/csv" {
+		ledgerID, ok = ledger.ResolveQuery(c, h.db, model.LedgerRoleEditor)
+		if !ok {
+			return
+		}
+		parsed, err := readCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		requests = parsed
+	} else {
+		var req bulkRateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ledgerID, ok = ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+		if !ok {
+			return
+		}
+		requests = req.Rates
+	}
+
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no rates to upload"})
+		return
+	}
+	if len(requests) > maxBulkRates {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many rates, limit is " + strconv.Itoa(maxBulkRates)})
+		return
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(requests))
+	for i, item := range requests {
+		rate, err := parseRate(item)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "row " + strconv.Itoa(i+1) + ": " + err.Error()})
+			return
+		}
+		rate.LedgerID = ledgerID
+		rates = append(rates, rate)
+	}
+
+	var resp bulkResponse
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for _, rate := range rates {
+			var existing model.ExchangeRate
+			err := tx.Where("ledger_id = ? AND rate_on = ? AND from_currency = ? AND to_currency = ?", ledgerID, rate.RateOn, rate.FromCurrency, rate.ToCurrency).
+				First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if err := tx.Create(&rate).Error; err != nil {
+					return err
+				}
+				resp.Created++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&existing).Update("rate", rate.Rate).Error; err != nil {
+				return err
+			}
+			resp.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h Handler) list(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	query := h.db.Where("ledger_id = ?", ledgerID)
+
+	if value := strings.TrimSpace(c.Query("from_currency")); value != "" {
+		currency, ok := normalizeCurrency(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from_currency"})
+			return
+		}
+		query = query.Where("from_currency = ?", currency)
+	}
+	if value := strings.TrimSpace(c.Query("to_currency")); value != "" {
+		currency, ok := normalizeCurrency(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to_currency"})
+			return
+		}
+		query = query.Where("to_currency = ?", currency)
+	}
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("rate_on >= ?", parsed)
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("rate_on <= ?", parsed)
+	}
+
+	var rates []model.ExchangeRate
+	if err := query.Order("rate_on desc, from_currency, to_currency").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+func (h Handler) get(c *gin.Context) {
+	rate, ok := h.load(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+func (h Handler) update(c *gin.Context) {
+	var req updateRateRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var raw map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(raw) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	rate, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	if _, ok := raw["rate_on"]; ok {
+		if req.RateOn == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate_on cannot be null"})
+			return
+		}
+		rateOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(*req.RateOn), time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate_on must be YYYY-MM-DD"})
+			return
+		}
+		var count int64
+		if err := h.db.Model(&model.ExchangeRate{}).
+			Where("ledger_id = ? AND rate_on = ? AND from_currency = ? AND to_currency = ? AND id <> ?", rate.LedgerID, rateOn, rate.FromCurrency, rate.ToCurrency, rate.ID).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "exchange rate already exists for this date and currency pair"})
+			return
+		}
+		rate.RateOn = rateOn
+	}
+
+	if _, ok := raw["rate"]; ok {
+		if req.Rate == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate cannot be null"})
+			return
+		}
+		value := money.Price(*req.Rate)
+		if !value.IsPositive() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be greater than 0"})
+			return
+		}
+		rate.Rate = value
+	}
+
+	if err := h.db.Save(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+func (h Handler) delete(c *gin.Context) {
+	rate, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(&model.ExchangeRate{}, rate.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete exchange rate"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// load 按路径 id 读取汇率并校验账本权限，失败时已写入响应。
+func (h Handler) load(c *gin.Context, role model.LedgerRole) (model.ExchangeRate, bool) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return model.ExchangeRate{}, false
+	}
+
+	var rate model.ExchangeRate
+	err := h.db.First(&rate, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exchange rate not found"})
+		return model.ExchangeRate{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load exchange rate"})
+		return model.ExchangeRate{}, false
+	}
+
+	if !ledger.Check(c, h.db, rate.LedgerID, role) {
+		return model.ExchangeRate{}, false
+	}
+	return rate, true
+}
+
+func parseRate(req rateRequest) (model.ExchangeRate, error) {
+	rateOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.RateOn), time.Local)
+	if err != nil {
+		return model.ExchangeRate{}, errors.New("rate_on must be YYYY-MM-DD")
+	}
+	from, ok := normalizeCurrency(req.FromCurrency)
+	if !ok {
+		return model.ExchangeRate{}, errors.New("from_currency must be a 3-letter currency code")
+	}
+	to, ok := normalizeCurrency(req.ToCurrency)
+	if !ok {
+		return model.ExchangeRate{}, errors.New("to_currency must be a 3-letter currency code")
+	}
+	if from == to {
+		return model.ExchangeRate{}, errors.New("from_currency and to_currency must differ")
+	}
+	rate := money.Price(req.Rate)
+	if !rate.IsPositive() {
+		return model.ExchangeRate{}, errors.New("rate must be greater than 0")
+	}
+
+	return model.ExchangeRate{
+		RateOn:       rateOn,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         rate,
+	}, nil
+}
+
+// readCSV 解析 rate_on,from_currency,to_currency,rate 四列，首行为表头（以 rate_on 开头）时跳过。
+func readCSV(body io.Reader) ([]rateRequest, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	var requests []rateRequest
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("invalid csv: " + err.Error())
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "rate_on") {
+			continue
+		}
+		rate, err := decimal.NewFromString(strings.TrimSpace(record[3]))
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": invalid rate")
+		}
+		requests = append(requests, rateRequest{
+			RateOn:       record[0],
+			FromCurrency: record[1],
+			ToCurrency:   record[2],
+			Rate:         rate,
+		})
+		if len(requests) > maxBulkRates {
+			break
+		}
+	}
+	return requests, nil
+}
+
+func parseID(raw string) (uint, bool) {
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
+
+// normalizeCurrency 将币种代码去空格、转大写，并检查是否为 3 位字母。
+func normalizeCurrency(input string) (string, bool) {
+	value := strings.ToUpper(strings.TrimSpace(input))
+	if len(value) != 3 {
+		return "", false
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,691 @@
+package investment
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errTickerTaken 表示代码变更的新代码已被占用，返回 409。
+var errTickerTaken = errors.New("ticker already exists")
+
+// RegisterActionRoutes 在证券路由组下注册公司行动接口（/api/securities/:id/actions）。
+// 公司行动会调整买入批次，因此与批次、卖出逻辑放在同一个包中。
+func RegisterActionRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	h := Handler{db: db}
+
+	rg.GET("/:id/actions", h.listActions)
+	rg.POST("/:id/actions", h.createAction)
+	rg.DELETE("/:id/actions/:action_id", h.deleteAction)
+}
+
+// createActionRequest 中每 ratio_from 股旧股换为 ratio_to 股新股；merger 换为 target_security_id
+// 的股份；symbol_change 只修改代码（及名称）。cash_in_lieu 为碎股现金补偿，需要 cash_account_id。
+type createActionRequest struct {
+	Type             string          `json:"type" binding:"required"`
+	EffectiveOn      string          `json:"effective_on" binding:"required"`
+	RatioFrom        decimal.Decimal `json:"ratio_from"`
+	RatioTo          decimal.Decimal `json:"ratio_to"`
+	TargetSecurityID *uint           `json:"target_security_id"`
+	NewTicker        string          `json:"new_ticker"`
+	NewName          string          `json:"new_name"`
+	CashInLieu       decimal.Decimal `json:"cash_in_lieu"`
+	CashAccountID    *uint           `json:"cash_account_id"`
+	Note             string          `json:"note"`
+}
+
+type actionResponse struct {
+	ID               uint            `json:"id"`
+	LedgerID         int             `json:"ledger_id"`
+	SecurityID       uint            `json:"security_id"`
+	Type             string          `json:"type"`
+	EffectiveOn      string          `json:"effective_on"`
+	RatioFrom        decimal.Decimal `json:"ratio_from"`
+	RatioTo          decimal.Decimal `json:"ratio_to"`
+	TargetSecurityID *uint           `json:"target_security_id"`
+	OldTicker        string          `json:"old_ticker"`
+	NewTicker        string          `json:"new_ticker"`
+	CashInLieu       decimal.Decimal `json:"cash_in_lieu"`
+	CashAccountID    *uint           `json:"cash_account_id"`
+	Note             string          `json:"note"`
+	LotIDs           []uint          `json:"lot_ids"`
+	SaleIDs          []uint          `json:"sale_ids"`
+}
+
+// listActions 返回涉及该证券（含作为合并目标）的公司行动，按生效日升序。
+func (h Handler) listActions(c *gin.Context) {
+	security, ok := h.loadSecurity(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var actions []model.CorporateAction
+	if err := h.db.Where("ledger_id = ? AND (security_id = ? OR target_security_id = ?)", security.LedgerID, security.ID, security.ID).
+		Order("effective_on, id").
+		Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query corporate actions"})
+		return
+	}
+
+	resp, err := newActionResponses(h.db, actions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query corporate actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// createAction 记录公司行动。拆股、合股与合并在生效日关闭该证券此前开立的未平仓批次，
+// 并按比例生成新批次（总成本不变，成本价按新数量重算）；生效日当天及之后已有卖出或批次转移时拒绝，
+// 且生效日不得早于该证券已有的公司行动。碎股现金补偿按各投资账户的碎股数量分摊并记为卖出。
+func (h Handler) createAction(c *gin.Context) {
+	var req createActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	security, ok := h.loadSecurity(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	action, err := parseActionRequest(req, security)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&security, security.ID).Error; err != nil {
+			return err
+		}
+
+		var later int64
+		if err := tx.Model(&model.CorporateAction{}).
+			Where("ledger_id = ? AND security_id = ? AND effective_on > ?", security.LedgerID, security.ID, action.EffectiveOn).
+			Count(&later).Error; err != nil {
+			return err
+		}
+		if later > 0 {
+			return newRequestError("effective_on must not be before an existing corporate action of this security")
+		}
+
+		var sales int64
+		if err := tx.Table("fin_investment_sales sale").
+			Joins("JOIN fin_transaction_lines tl ON tl.id = sale.transaction_line_id AND tl.deleted_at IS NULL").
+			Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+			Where("sale.deleted_at IS NULL AND sale.ledger_id = ? AND sale.security_id = ? AND t.occurred_on >= ?",
+				security.LedgerID, security.ID, action.EffectiveOn).
+			Count(&sales).Error; err != nil {
+			return err
+		}
+		if sales > 0 {
+			return newRequestError("security has sales on or after effective_on")
+		}
+
+		var transfers int64
+		if err := tx.Table("fin_investment_transfers tr").
+			Joins("JOIN fin_transactions t ON t.id = tr.transaction_id AND t.deleted_at IS NULL").
+			Where("tr.deleted_at IS NULL AND tr.ledger_id = ? AND tr.security_id = ? AND t.occurred_on >= ?",
+				security.LedgerID, security.ID, action.EffectiveOn).
+			Count(&transfers).Error; err != nil {
+			return err
+		}
+		if transfers > 0 {
+			return newRequestError("security has lot transfers on or after effective_on")
+		}
+
+		targetID := security.ID
+		if action.Type == model.CorporateActionMerger {
+			var target model.Security
+			if err := tx.Where("id = ? AND ledger_id = ?", *action.TargetSecurityID, security.LedgerID).First(&target).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError("target security not found")
+				}
+				return err
+			}
+			targetID = target.ID
+		}
+
+		var cashAccount model.Account
+		if action.CashInLieu.IsPositive() {
+			if err := tx.Where("id = ? AND ledger_id = ?", *action.CashAccountID, security.LedgerID).First(&cashAccount).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError("cash account not found")
+				}
+				return err
+			}
+			action.CashInLieu = money.Round(action.CashInLieu, cashAccount.Currency)
+		}
+
+		if action.Type == model.CorporateActionSymbolChange {
+			var taken int64
+			if err := tx.Unscoped().Model(&model.Security{}).
+				Where("ledger_id = ? AND ticker = ? AND id <> ?", security.LedgerID, action.NewTicker, security.ID).
+				Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return errTickerTaken
+			}
+			updates := map[string]interface{}{"ticker": action.NewTicker}
+			if name := strings.TrimSpace(req.NewName); name != "" {
+				updates["name"] = name
+			}
+			if err := tx.Model(&model.Security{}).Where("id = ?", security.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&action).Error; err != nil {
+			return err
+		}
+
+		if action.Type == model.CorporateActionSymbolChange {
+			return nil
+		}
+
+		children, err := adjustLots(tx, action, targetID)
+		if err != nil {
+			return err
+		}
+		if action.CashInLieu.IsPositive() {
+			return payCashInLieu(tx, action, targetID, children)
+		}
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, errTickerTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create corporate action"})
+		return
+	}
+
+	resp, err := newActionResponses(h.db, []model.CorporateAction{action})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load corporate action"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp[0])
+}
+
+// deleteAction 撤销该证券最近一次公司行动：删除碎股补偿卖出与生成的批次，重新开启原批次，
+// 代码变更恢复原代码。生成的批次已被卖出或再次调整时不可撤销。
+func (h Handler) deleteAction(c *gin.Context) {
+	actionID, ok := parseUintID(c.Param("action_id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid action id"})
+		return
+	}
+
+	security, ok := h.loadSecurity(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var action model.CorporateAction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ledger_id = ? AND security_id = ?", actionID, security.LedgerID, security.ID).
+			First(&action).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("corporate action not found")
+			}
+			return err
+		}
+
+		var later int64
+		if err := tx.Model(&model.CorporateAction{}).
+			Where("ledger_id = ? AND security_id = ? AND (effective_on > ? OR (effective_on = ? AND id > ?))",
+				action.LedgerID, action.SecurityID, action.EffectiveOn, action.EffectiveOn, action.ID).
+			Count(&later).Error; err != nil {
+			return err
+		}
+		if later > 0 {
+			return newRequestError("only the latest corporate action of a security can be deleted")
+		}
+
+		var sales []model.InvestmentSale
+		if err := tx.Where("corporate_action_id = ?", action.ID).Find(&sales).Error; err != nil {
+			return err
+		}
+		for _, sale := range sales {
+			var line model.TransactionLine
+			if err := tx.First(&line, sale.TransactionLineID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("sale_id = ?", sale.ID).Delete(&model.InvestmentLotAllocation{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.InvestmentSale{}, sale.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("transaction_id = ?", line.TransactionID).Delete(&model.TransactionLine{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.Transaction{}, line.TransactionID).Error; err != nil {
+				return err
+			}
+		}
+
+		var children []model.InvestmentLot
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("corporate_action_id = ?", action.ID).
+			Find(&children).Error; err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			childIDs := make([]uint, 0, len(children))
+			var parentIDs []uint
+			for _, child := range children {
+				if child.ClosedOn != nil {
+					return newRequestError("lots created by this action were adjusted again")
+				}
+				childIDs = append(childIDs, child.ID)
+				if child.ParentLotID != nil {
+					parentIDs = append(parentIDs, *child.ParentLotID)
+				}
+			}
+
+			var allocations int64
+			if err := tx.Model(&model.InvestmentLotAllocation{}).Where("buy_lot_id IN ?", childIDs).Count(&allocations).Error; err != nil {
+				return err
+			}
+			if allocations > 0 {
+				return newRequestError("lots created by this action have been sold")
+			}
+
+			if err := tx.Delete(&model.InvestmentLot{}, childIDs).Error; err != nil {
+				return err
+			}
+			if len(parentIDs) > 0 {
+				if err := tx.Model(&model.InvestmentLot{}).Where("id IN ?", parentIDs).
+					Update("closed_on", nil).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if action.Type == model.CorporateActionSymbolChange {
+			var taken int64
+			if err := tx.Unscoped().Model(&model.Security{}).
+				Where("ledger_id = ? AND ticker = ? AND id <> ?", action.LedgerID, action.OldTicker, action.SecurityID).
+				Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return errTickerTaken
+			}
+			if err := tx.Model(&model.Security{}).Where("id = ?", action.SecurityID).
+				Update("ticker", action.OldTicker).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&model.CorporateAction{}, action.ID).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, errTickerTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete corporate action"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// loadSecurity 按路径参数 id 读取证券并校验账本权限；失败时已写入响应。
+func (h Handler) loadSecurity(c *gin.Context, role model.LedgerRole) (model.Security, bool) {
+	id, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security id"})
+		return model.Security{}, false
+	}
+
+	var security model.Security
+	if err := h.db.First(&security, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "security not found"})
+			return model.Security{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load security"})
+		return model.Security{}, false
+	}
+
+	if !ledger.Check(c, h.db, security.LedgerID, role) {
+		return model.Security{}, false
+	}
+	return security, true
+}
+
+// parseActionRequest 校验类型、日期、比例与碎股补偿，返回待保存的公司行动。
+func parseActionRequest(req createActionRequest, security model.Security) (model.CorporateAction, error) {
+	effectiveOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.EffectiveOn), time.Local)
+	if err != nil {
+		return model.CorporateAction{}, errors.New("effective_on must be YYYY-MM-DD")
+	}
+
+	action := model.CorporateAction{
+		LedgerID:    security.LedgerID,
+		SecurityID:  security.ID,
+		Type:        strings.ToLower(strings.TrimSpace(req.Type)),
+		EffectiveOn: effectiveOn,
+		RatioFrom:   req.RatioFrom,
+		RatioTo:     req.RatioTo,
+		OldTicker:   security.Ticker,
+		CashInLieu:  req.CashInLieu,
+		Note:        strings.TrimSpace(req.Note),
+	}
+
+	switch action.Type {
+	case model.CorporateActionSplit:
+		if !action.RatioFrom.IsPositive() || !action.RatioTo.GreaterThan(action.RatioFrom) {
+			return model.CorporateAction{}, errors.New("split requires ratio_to greater than ratio_from > 0")
+		}
+	case model.CorporateActionReverseSplit:
+		if !action.RatioTo.IsPositive() || !action.RatioFrom.GreaterThan(action.RatioTo) {
+			return model.CorporateAction{}, errors.New("reverse_split requires ratio_from greater than ratio_to > 0")
+		}
+	case model.CorporateActionMerger:
+		if !action.RatioFrom.IsPositive() || !action.RatioTo.IsPositive() {
+			return model.CorporateAction{}, errors.New("ratio_from and ratio_to must be greater than 0")
+		}
+		if req.TargetSecurityID == nil || *req.TargetSecurityID == 0 {
+			return model.CorporateAction{}, errors.New("target_security_id is required for merger")
+		}
+		if *req.TargetSecurityID == security.ID {
+			return model.CorporateAction{}, errors.New("target_security_id must differ from the security")
+		}
+		action.TargetSecurityID = req.TargetSecurityID
+	case model.CorporateActionSymbolChange:
+		action.NewTicker = strings.ToUpper(strings.TrimSpace(req.NewTicker))
+		if action.NewTicker == "" || action.NewTicker == security.Ticker {
+			return model.CorporateAction{}, errors.New("new_ticker is required and must differ from the current ticker")
+		}
+		if req.CashInLieu.IsPositive() {
+			return model.CorporateAction{}, errors.New("cash_in_lieu is not allowed for symbol_change")
+		}
+		action.RatioFrom = decimal.NewFromInt(1)
+		action.RatioTo = decimal.NewFromInt(1)
+	default:
+		return model.CorporateAction{}, errors.New("type must be one of: split, reverse_split, symbol_change, merger")
+	}
+
+	if action.CashInLieu.IsNegative() {
+		return model.CorporateAction{}, errors.New("cash_in_lieu cannot be negative")
+	}
+	if action.CashInLieu.IsPositive() {
+		if req.CashAccountID == nil || *req.CashAccountID == 0 {
+			return model.CorporateAction{}, errors.New("cash_account_id is required for cash_in_lieu")
+		}
+		action.CashAccountID = req.CashAccountID
+	}
+
+	return action, nil
+}
+
+// ensureNoLaterActions 拒绝在该证券（或其合并目标）已有公司行动生效日之前开立或移动批次，
+// 否则新批次不会被已执行的拆股、合并调整。
+func ensureNoLaterActions(tx *gorm.DB, ledgerID int, securityID uint, on time.Time, field string) error {
+	var actions int64
+	if err := tx.Model(&model.CorporateAction{}).
+		Where("ledger_id = ? AND (security_id = ? OR target_security_id = ?) AND effective_on > ?",
+			ledgerID, securityID, securityID, on).
+		Count(&actions).Error; err != nil {
+		return err
+	}
+	if actions > 0 {
+		return newRequestError("security has corporate actions after " + field)
+	}
+	return nil
+}
+
+// adjustedLot 为公司行动生成的批次及其买入日期。
+type adjustedLot struct {
+	lot        model.InvestmentLot
+	acquiredOn time.Time
+}
+
+// adjustLots 关闭生效日前开立的未平仓批次，并为每个批次生成数量为 剩余数量 × ratio_to / ratio_from、
+// 总成本不变的新批次。新批次沿用原买入行，投资账户与买入日期不变。
+func adjustLots(tx *gorm.DB, action model.CorporateAction, targetID uint) ([]adjustedLot, error) {
+	var lotIDs []uint
+	if err := tx.Table("fin_investment_lots l").
+		Joins("JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.closed_on IS NULL AND l.ledger_id = ? AND l.security_id = ? AND COALESCE(l.opened_on, t.occurred_on) < ?",
+			action.LedgerID, action.SecurityID, action.EffectiveOn).
+		Order("l.id").
+		Pluck("l.id", &lotIDs).Error; err != nil {
+		return nil, err
+	}
+
+	lots, err := lockOpenLots(tx, action.LedgerID, lotIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	effectiveOn := action.EffectiveOn
+	var children []adjustedLot
+	for _, item := range lots {
+		if !item.remaining.IsPositive() {
+			continue
+		}
+
+		quantity := money.Quantity(item.remaining.Mul(action.RatioTo).Div(action.RatioFrom))
+		if !quantity.IsPositive() {
+			return nil, newRequestError("lot " + strconv.FormatUint(uint64(item.lot.ID), 10) + " rounds to zero shares")
+		}
+		cost := item.remaining.Mul(item.lot.Price)
+		parentID := item.lot.ID
+		actionID := action.ID
+		child := model.InvestmentLot{
+			LedgerID:          item.lot.LedgerID,
+			TransactionLineID: item.lot.TransactionLineID,
+			AccountID:         item.lot.AccountID,
+			SecurityID:        targetID,
+			Quantity:          quantity,
+			Price:             cost.DivRound(quantity, money.PriceScale),
+			TradePrice:        item.lot.TradePrice.Mul(action.RatioFrom).DivRound(action.RatioTo, money.PriceScale),
+			ParentLotID:       &parentID,
+			CorporateActionID: &actionID,
+			OpenedOn:          &effectiveOn,
+		}
+		if err := tx.Create(&child).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&model.InvestmentLot{}).Where("id = ?", item.lot.ID).
+			Update("closed_on", effectiveOn).Error; err != nil {
+			return nil, err
+		}
+		children = append(children, adjustedLot{lot: child, acquiredOn: item.acquiredOn})
+	}
+	return children, nil
+}
+
+// payCashInLieu 将各投资账户新批次合计数量的小数部分按碎股补偿卖出：补偿金额按碎股数量比例
+// 分摊到各账户（最后一个账户取余数），从最近买入的新批次中扣减。
+func payCashInLieu(tx *gorm.DB, action model.CorporateAction, targetID uint, children []adjustedLot) error {
+	byAccount := make(map[uint][]adjustedLot)
+	var accountIDs []uint
+	for _, child := range children {
+		if _, ok := byAccount[child.lot.AccountID]; !ok {
+			accountIDs = append(accountIDs, child.lot.AccountID)
+		}
+		byAccount[child.lot.AccountID] = append(byAccount[child.lot.AccountID], child)
+	}
+	sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i] < accountIDs[j] })
+
+	fractions := make(map[uint]decimal.Decimal, len(accountIDs))
+	totalFraction := decimal.Zero
+	for _, accountID := range accountIDs {
+		total := decimal.Zero
+		for _, child := range byAccount[accountID] {
+			total = total.Add(child.lot.Quantity)
+		}
+		fraction := total.Sub(total.Floor())
+		if fraction.IsPositive() {
+			fractions[accountID] = fraction
+			totalFraction = totalFraction.Add(fraction)
+		}
+	}
+	if !totalFraction.IsPositive() {
+		return newRequestError("no fractional shares to pay cash_in_lieu for")
+	}
+
+	var cashAccount model.Account
+	if err := tx.First(&cashAccount, *action.CashAccountID).Error; err != nil {
+		return err
+	}
+
+	remainingCash := action.CashInLieu
+	remainingFraction := totalFraction
+	for _, accountID := range accountIDs {
+		fraction, ok := fractions[accountID]
+		if !ok {
+			continue
+		}
+		cash := remainingCash
+		remainingFraction = remainingFraction.Sub(fraction)
+		if remainingFraction.IsPositive() {
+			cash = money.Round(action.CashInLieu.Mul(fraction).Div(totalFraction), cashAccount.Currency)
+		}
+		remainingCash = remainingCash.Sub(cash)
+
+		lots := byAccount[accountID]
+		sort.SliceStable(lots, func(i, j int) bool { return lots[i].acquiredOn.After(lots[j].acquiredOn) })
+
+		req := createSaleRequest{
+			LedgerID:            &action.LedgerID,
+			OccurredOn:          action.EffectiveOn.Format("2006-01-02"),
+			SecurityID:          targetID,
+			CashAccountID:       cashAccount.ID,
+			InvestmentAccountID: accountID,
+			Method:              lotMethodSpecific,
+			Price:               cash.Div(fraction),
+			Description:         "cash in lieu",
+		}
+		left := fraction
+		for _, child := range lots {
+			if !left.IsPositive() {
+				break
+			}
+			take := decimal.Min(left, child.lot.Quantity)
+			req.Allocations = append(req.Allocations, saleAllocation{BuyLotID: child.lot.ID, Quantity: take})
+			left = left.Sub(take)
+		}
+
+		input, err := parseSaleRequest(req)
+		if err != nil {
+			return newRequestError(err.Error())
+		}
+		plan, err := planSale(tx, action.LedgerID, req, input, true)
+		if err != nil {
+			return err
+		}
+
+		txRecord := model.Transaction{
+			LedgerID:    action.LedgerID,
+			OccurredOn:  action.EffectiveOn,
+			Description: req.Description,
+		}
+		if err := tx.Create(&txRecord).Error; err != nil {
+			return err
+		}
+		actionID := action.ID
+		sale := model.InvestmentSale{LedgerID: action.LedgerID, CorporateActionID: &actionID}
+		if err := writeSale(tx, txRecord, &sale, req, input, plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newActionResponses 附上各公司行动生成的批次与碎股补偿卖出。
+func newActionResponses(db *gorm.DB, actions []model.CorporateAction) ([]actionResponse, error) {
+	resp := make([]actionResponse, 0, len(actions))
+	if len(actions) == 0 {
+		return resp, nil
+	}
+
+	actionIDs := make([]uint, 0, len(actions))
+	for _, action := range actions {
+		actionIDs = append(actionIDs, action.ID)
+	}
+
+	var lots []model.InvestmentLot
+	if err := db.Where("corporate_action_id IN ?", actionIDs).Order("id").Find(&lots).Error; err != nil {
+		return nil, err
+	}
+	var sales []model.InvestmentSale
+	if err := db.Where("corporate_action_id IN ?", actionIDs).Order("id").Find(&sales).Error; err != nil {
+		return nil, err
+	}
+	lotIDs := make(map[uint][]uint)
+	for _, lot := range lots {
+		lotIDs[*lot.CorporateActionID] = append(lotIDs[*lot.CorporateActionID], lot.ID)
+	}
+	saleIDs := make(map[uint][]uint)
+	for _, sale := range sales {
+		saleIDs[*sale.CorporateActionID] = append(saleIDs[*sale.CorporateActionID], sale.ID)
+	}
+
+	for _, action := range actions {
+		item := actionResponse{
+			ID:               action.ID,
+			LedgerID:         action.LedgerID,
+			SecurityID:       action.SecurityID,
+			Type:             action.Type,
+			EffectiveOn:      action.EffectiveOn.Format("2006-01-02"),
+			RatioFrom:        action.RatioFrom,
+			RatioTo:          action.RatioTo,
+			TargetSecurityID: action.TargetSecurityID,
+			OldTicker:        action.OldTicker,
+			NewTicker:        action.NewTicker,
+			CashInLieu:       action.CashInLieu,
+			CashAccountID:    action.CashAccountID,
+			Note:             action.Note,
+			LotIDs:           lotIDs[action.ID],
+			SaleIDs:          saleIDs[action.ID],
+		}
+		if item.LotIDs == nil {
+			item.LotIDs = []uint{}
+		}
+		if item.SaleIDs == nil {
+			item.SaleIDs = []uint{}
+		}
+		resp = append(resp, item)
+	}
+	return resp, nil
+}
@@ -0,0 +1,559 @@
+package investment
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// createDividendRequest 记录分红、利息或分配收益；reinvest 为 true 时以税后金额在
+// investment_account_id 下按 reinvest_quantity 买入新批次。
+type createDividendRequest struct {
+	LedgerID            *int            `json:"ledger_id"`
+	SecurityID          uint            `json:"security_id" binding:"required,gt=0"`
+	CashAccountID       uint            `json:"cash_account_id" binding:"required,gt=0"`
+	Kind                string          `json:"kind"`
+	PayDate             string          `json:"pay_date" binding:"required"`
+	ExDate              string          `json:"ex_date"`
+	GrossAmount         decimal.Decimal `json:"gross_amount"`
+	WithholdingTax      decimal.Decimal `json:"withholding_tax"`
+	IncomeCategoryID    int             `json:"income_category_id" binding:"required,gt=0"`
+	TaxCategoryID       *int            `json:"tax_category_id"`
+	Reinvest            bool            `json:"reinvest"`
+	InvestmentAccountID uint            `json:"investment_account_id"`
+	ReinvestQuantity    decimal.Decimal `json:"reinvest_quantity"`
+	Description         string          `json:"description"`
+	Note                string          `json:"note"`
+}
+
+// dividendResponse 金额以现金账户币种计，net_amount = gross_amount - withholding_tax。
+type dividendResponse struct {
+	DividendID          uint             `json:"dividend_id"`
+	LedgerID            int              `json:"ledger_id"`
+	TransactionID       uint             `json:"transaction_id"`
+	SecurityID          uint             `json:"security_id"`
+	SecurityTicker      string           `json:"security_ticker"`
+	SecurityName        string           `json:"security_name"`
+	Kind                string           `json:"kind"`
+	PayDate             string           `json:"pay_date"`
+	ExDate              *string          `json:"ex_date"`
+	CashAccountID       uint             `json:"cash_account_id"`
+	Currency            string           `json:"currency"`
+	GrossAmount         decimal.Decimal  `json:"gross_amount"`
+	WithholdingTax      decimal.Decimal  `json:"withholding_tax"`
+	NetAmount           decimal.Decimal  `json:"net_amount"`
+	IncomeCategoryID    *int             `json:"income_category_id"`
+	TaxCategoryID       *int             `json:"tax_category_id"`
+	Reinvested          bool             `json:"reinvested"`
+	LotID               *uint            `json:"lot_id"`
+	InvestmentAccountID *uint            `json:"investment_account_id"`
+	ReinvestQuantity    *decimal.Decimal `json:"reinvest_quantity"`
+	ReinvestPrice       *decimal.Decimal `json:"reinvest_price"`
+	Description         string           `json:"description"`
+	Note                string           `json:"note"`
+}
+
+// createDividend 写入分录：现金账户 +gross（收入分类），代扣税为现金账户上的负数行；
+// 再投资时再写现金账户 -net 与投资账户 +net，投资账户行作为新批次的买入行。
+func (h Handler) createDividend(c *gin.Context) {
+	var req createDividendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	payDate, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.PayDate), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pay_date must be YYYY-MM-DD"})
+		return
+	}
+
+	var exDate *time.Time
+	if value := strings.TrimSpace(req.ExDate); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ex_date must be YYYY-MM-DD"})
+			return
+		}
+		if parsed.After(payDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ex_date cannot be after pay_date"})
+			return
+		}
+		exDate = &parsed
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(req.Kind))
+	if kind == "" {
+		kind = model.DividendKindDividend
+	}
+	if kind != model.DividendKindDividend && kind != model.DividendKindInterest && kind != model.DividendKindDistribution {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be dividend, interest or distribution"})
+		return
+	}
+
+	if !req.GrossAmount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gross_amount must be greater than 0"})
+		return
+	}
+	if req.WithholdingTax.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "withholding_tax cannot be negative"})
+		return
+	}
+
+	req.ReinvestQuantity = money.Quantity(req.ReinvestQuantity)
+	if req.Reinvest {
+		if req.InvestmentAccountID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "investment_account_id is required when reinvest is true"})
+			return
+		}
+		if !req.ReinvestQuantity.IsPositive() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reinvest_quantity must be greater than 0"})
+			return
+		}
+	}
+
+	var dividendID uint
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var security model.Security
+		if err := tx.Where("id = ? AND ledger_id = ?", req.SecurityID, ledgerID).First(&security).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("security not found")
+			}
+			return err
+		}
+
+		var cashAccount model.Account
+		if err := tx.Where("id = ? AND ledger_id = ?", req.CashAccountID, ledgerID).First(&cashAccount).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("cash account not found")
+			}
+			return err
+		}
+		if !cashAccount.IsActive {
+			return newRequestError("cash account is inactive")
+		}
+
+		if err := validateIncomeCategory(tx, ledgerID, req.IncomeCategoryID); err != nil {
+			return err
+		}
+		if req.TaxCategoryID != nil {
+			if err := validateExpenseCategory(tx, ledgerID, *req.TaxCategoryID); err != nil {
+				return err
+			}
+		}
+
+		currency := cashAccount.Currency
+		gross := money.Round(req.GrossAmount, currency)
+		withholding := money.Round(req.WithholdingTax, currency)
+		net := gross.Sub(withholding)
+		if !gross.IsPositive() {
+			return newRequestError("gross_amount must be greater than 0")
+		}
+		if net.IsNegative() {
+			return newRequestError("withholding_tax cannot exceed gross_amount")
+		}
+		if withholding.IsPositive() && req.TaxCategoryID == nil {
+			return newRequestError("tax_category_id is required when withholding_tax is greater than 0")
+		}
+
+		var investmentAccount model.Account
+		if req.Reinvest {
+			if !net.IsPositive() {
+				return newRequestError("net amount must be greater than 0 to reinvest")
+			}
+			if err := tx.Where("id = ? AND ledger_id = ?", req.InvestmentAccountID, ledgerID).First(&investmentAccount).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return newRequestError("investment account not found")
+				}
+				return err
+			}
+			if !investmentAccount.IsActive {
+				return newRequestError("investment account is inactive")
+			}
+			if strings.ToLower(investmentAccount.Type) != "investment" {
+				return newRequestError("investment_account_id must be an investment account")
+			}
+			if !strings.EqualFold(investmentAccount.Currency, cashAccount.Currency) {
+				return newRequestError("investment account currency must match cash account currency")
+			}
+			if err := ensureNoLaterActions(tx, ledgerID, security.ID, payDate, "pay_date"); err != nil {
+				return err
+			}
+		}
+
+		txRecord := model.Transaction{
+			LedgerID:    ledgerID,
+			OccurredOn:  payDate,
+			Description: strings.TrimSpace(req.Description),
+			Note:        strings.TrimSpace(req.Note),
+		}
+		if err := tx.Create(&txRecord).Error; err != nil {
+			return err
+		}
+
+		incomeCategoryID := req.IncomeCategoryID
+		incomeLine := model.TransactionLine{
+			LedgerID:      ledgerID,
+			TransactionID: txRecord.ID,
+			AccountID:     req.CashAccountID,
+			CategoryID:    &incomeCategoryID,
+			Amount:        gross,
+		}
+		if err := tx.Create(&incomeLine).Error; err != nil {
+			return err
+		}
+
+		if withholding.IsPositive() {
+			taxLine := model.TransactionLine{
+				LedgerID:      ledgerID,
+				TransactionID: txRecord.ID,
+				AccountID:     req.CashAccountID,
+				CategoryID:    req.TaxCategoryID,
+				Amount:        withholding.Neg(),
+			}
+			if err := tx.Create(&taxLine).Error; err != nil {
+				return err
+			}
+		}
+
+		dividend := model.InvestmentDividend{
+			LedgerID:          ledgerID,
+			TransactionLineID: incomeLine.ID,
+			SecurityID:        security.ID,
+			Kind:              kind,
+			ExDate:            exDate,
+			GrossAmount:       gross,
+			WithholdingTax:    withholding,
+		}
+
+		if req.Reinvest {
+			cashLine := model.TransactionLine{
+				LedgerID:      ledgerID,
+				TransactionID: txRecord.ID,
+				AccountID:     req.CashAccountID,
+				Amount:        net.Neg(),
+			}
+			if err := tx.Create(&cashLine).Error; err != nil {
+				return err
+			}
+			investmentLine := model.TransactionLine{
+				LedgerID:      ledgerID,
+				TransactionID: txRecord.ID,
+				AccountID:     req.InvestmentAccountID,
+				Amount:        net,
+			}
+			if err := tx.Create(&investmentLine).Error; err != nil {
+				return err
+			}
+
+			price := net.DivRound(req.ReinvestQuantity, money.PriceScale)
+			lot := model.InvestmentLot{
+				LedgerID:          ledgerID,
+				TransactionLineID: investmentLine.ID,
+				AccountID:         req.InvestmentAccountID,
+				SecurityID:        security.ID,
+				Quantity:          req.ReinvestQuantity,
+				Price:             price,
+				TradePrice:        price,
+			}
+			if err := tx.Create(&lot).Error; err != nil {
+				return err
+			}
+			dividend.LotID = &lot.ID
+		}
+
+		if err := tx.Create(&dividend).Error; err != nil {
+			return err
+		}
+		dividendID = dividend.ID
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create dividend"})
+		return
+	}
+
+	dividends, err := loadDividends(h.db, ledgerID, dividendFilter{dividendID: dividendID})
+	if err != nil || len(dividends) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load dividend"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dividends[0])
+}
+
+type dividendFilter struct {
+	dividendID uint
+	securityID uint
+	dateFrom   *time.Time
+	dateTo     *time.Time
+}
+
+// listDividends 按发放日升序返回分红记录，可按 security_id、date_from、date_to（发放日）过滤。
+func (h Handler) listDividends(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var filter dividendFilter
+	if value := strings.TrimSpace(c.Query("security_id")); value != "" {
+		parsed, ok := parseUintID(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security_id"})
+			return
+		}
+		filter.securityID = parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateFrom = &parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateTo = &parsed
+	}
+
+	dividends, err := loadDividends(h.db, ledgerID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query dividends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dividends)
+}
+
+// deleteDividend 删除分红记录及其交易；再投资批次已被卖出匹配时不可删除。
+func (h Handler) deleteDividend(c *gin.Context) {
+	dividendID, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dividend id"})
+		return
+	}
+
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var dividend model.InvestmentDividend
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ledger_id = ?", dividendID, ledgerID).
+			First(&dividend).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("dividend not found")
+			}
+			return err
+		}
+
+		if dividend.LotID != nil {
+			var lot model.InvestmentLot
+			if err := tx.First(&lot, *dividend.LotID).Error; err != nil {
+				return err
+			}
+			if lot.ClosedOn != nil {
+				return newRequestError("reinvested lot adjusted by a corporate action, cannot delete")
+			}
+
+			var allocated decimal.Decimal
+			if err := tx.Table("fin_investment_lot_allocations").
+				Select("COALESCE(SUM(quantity), 0)").
+				Where("buy_lot_id = ? AND deleted_at IS NULL", *dividend.LotID).
+				Scan(&allocated).Error; err != nil {
+				return err
+			}
+			if allocated.IsPositive() {
+				return newRequestError("reinvested lot already allocated, cannot delete")
+			}
+			if err := tx.Delete(&model.InvestmentLot{}, *dividend.LotID).Error; err != nil {
+				return err
+			}
+		}
+
+		var line model.TransactionLine
+		if err := tx.Where("id = ? AND ledger_id = ?", dividend.TransactionLineID, ledgerID).First(&line).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("transaction line not found")
+			}
+			return err
+		}
+
+		if err := tx.Delete(&model.InvestmentDividend{}, dividend.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ? AND ledger_id = ?", line.TransactionID, ledgerID).
+			Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Transaction{}, line.TransactionID).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete dividend"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// loadDividends 按发放日与 id 排序返回分红详情。代扣税分类为现金账户上收益行以外的分类行，
+// 再投资的投资账户为批次买入行所在账户。
+func loadDividends(db *gorm.DB, ledgerID int, filter dividendFilter) ([]dividendResponse, error) {
+	type dividendRow struct {
+		model.InvestmentDividend
+		TransactionID  uint      `gorm:"column:transaction_id"`
+		PayDate        time.Time `gorm:"column:pay_date"`
+		Description    string    `gorm:"column:description"`
+		Note           string    `gorm:"column:note"`
+		CashAccountID  uint      `gorm:"column:cash_account_id"`
+		Currency       string    `gorm:"column:currency"`
+		CategoryID     *int      `gorm:"column:category_id"`
+		SecurityTicker string    `gorm:"column:security_ticker"`
+		SecurityName   string    `gorm:"column:security_name"`
+	}
+
+	query := db.Table("fin_investment_dividends d").
+		Select(`d.*, t.id AS transaction_id, t.occurred_on AS pay_date, t.description, t.note,
+  tl.account_id AS cash_account_id, a.currency, tl.category_id,
+  s.ticker AS security_ticker, s.name AS security_name`).
+		Joins("JOIN fin_transaction_lines tl ON tl.id = d.transaction_line_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Joins("JOIN fin_accounts a ON a.id = tl.account_id").
+		Joins("JOIN fin_securities s ON s.id = d.security_id").
+		Where("d.deleted_at IS NULL AND d.ledger_id = ?", ledgerID)
+	if filter.dividendID != 0 {
+		query = query.Where("d.id = ?", filter.dividendID)
+	}
+	if filter.securityID != 0 {
+		query = query.Where("d.security_id = ?", filter.securityID)
+	}
+	if filter.dateFrom != nil {
+		query = query.Where("t.occurred_on >= ?", *filter.dateFrom)
+	}
+	if filter.dateTo != nil {
+		query = query.Where("t.occurred_on <= ?", *filter.dateTo)
+	}
+
+	var rows []dividendRow
+	if err := query.Order("t.occurred_on, d.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []dividendResponse{}, nil
+	}
+
+	transactionIDs := make([]uint, 0, len(rows))
+	var lotIDs []uint
+	for _, row := range rows {
+		transactionIDs = append(transactionIDs, row.TransactionID)
+		if row.LotID != nil {
+			lotIDs = append(lotIDs, *row.LotID)
+		}
+	}
+
+	var lines []model.TransactionLine
+	if err := db.Where("transaction_id IN ?", transactionIDs).Order("id").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	linesByTransaction := make(map[uint][]model.TransactionLine)
+	for _, line := range lines {
+		linesByTransaction[line.TransactionID] = append(linesByTransaction[line.TransactionID], line)
+	}
+
+	lots := make(map[uint]model.InvestmentLot)
+	if len(lotIDs) > 0 {
+		var lotRecords []model.InvestmentLot
+		if err := db.Where("id IN ?", lotIDs).Find(&lotRecords).Error; err != nil {
+			return nil, err
+		}
+		for _, lot := range lotRecords {
+			lots[lot.ID] = lot
+		}
+	}
+
+	result := make([]dividendResponse, 0, len(rows))
+	for _, row := range rows {
+		item := dividendResponse{
+			DividendID:       row.ID,
+			LedgerID:         row.LedgerID,
+			TransactionID:    row.TransactionID,
+			SecurityID:       row.SecurityID,
+			SecurityTicker:   row.SecurityTicker,
+			SecurityName:     row.SecurityName,
+			Kind:             row.Kind,
+			PayDate:          row.PayDate.Format("2006-01-02"),
+			CashAccountID:    row.CashAccountID,
+			Currency:         strings.ToUpper(row.Currency),
+			GrossAmount:      row.GrossAmount,
+			WithholdingTax:   row.WithholdingTax,
+			NetAmount:        row.GrossAmount.Sub(row.WithholdingTax),
+			IncomeCategoryID: row.CategoryID,
+			Reinvested:       row.LotID != nil,
+			LotID:            row.LotID,
+			Description:      row.Description,
+			Note:             row.Note,
+		}
+		if row.ExDate != nil {
+			value := row.ExDate.Format("2006-01-02")
+			item.ExDate = &value
+		}
+
+		for _, line := range linesByTransaction[row.TransactionID] {
+			if line.ID != row.TransactionLineID && line.AccountID == row.CashAccountID && line.CategoryID != nil {
+				item.TaxCategoryID = line.CategoryID
+			}
+		}
+
+		if row.LotID != nil {
+			if lot, ok := lots[*row.LotID]; ok {
+				item.ReinvestQuantity = &lot.Quantity
+				item.ReinvestPrice = &lot.Price
+				for _, line := range linesByTransaction[row.TransactionID] {
+					if line.ID == lot.TransactionLineID {
+						accountID := line.AccountID
+						item.InvestmentAccountID = &accountID
+					}
+				}
+			}
+		}
+
+		result = append(result, item)
+	}
+	return result, nil
+}
@@ -3,14 +3,16 @@ package investment
 import (
 	"errors"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
+	"finance-backend/internal/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -26,55 +28,73 @@ func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	rg.POST("/buys", h.createBuy)
 	rg.PATCH("/buys/:id", h.updateBuy)
 	rg.DELETE("/buys/:id", h.deleteBuy)
+	rg.GET("/sales", h.listSales)
 	rg.POST("/sales", h.createSale)
+	rg.POST("/sales/preview", h.previewSale)
+	rg.GET("/sales/:id", h.getSale)
+	rg.PATCH("/sales/:id", h.updateSale)
+	rg.DELETE("/sales/:id", h.deleteSale)
+	rg.GET("/dividends", h.listDividends)
+	rg.POST("/dividends", h.createDividend)
+	rg.DELETE("/dividends/:id", h.deleteDividend)
+	rg.GET("/transfers", h.listTransfers)
+	rg.POST("/transfers", h.createTransfer)
+	rg.DELETE("/transfers/:id", h.deleteTransfer)
 }
 
 type lotRow struct {
-	LotID             uint      `gorm:"column:lot_id"`
-	LedgerID          int       `gorm:"column:ledger_id"`
-	SecurityID        uint      `gorm:"column:security_id"`
-	SecurityTicker    string    `gorm:"column:security_ticker"`
-	SecurityName      string    `gorm:"column:security_name"`
-	Quantity          float64   `gorm:"column:quantity"`
-	Price             float64   `gorm:"column:price"`
-	TradePrice        float64   `gorm:"column:trade_price"`
-	Fee               float64   `gorm:"column:fee"`
-	Tax               float64   `gorm:"column:tax"`
-	TransactionLineID uint      `gorm:"column:transaction_line_id"`
-	TransactionID     uint      `gorm:"column:transaction_id"`
-	OccurredOn        time.Time `gorm:"column:occurred_on"`
-	AllocatedQuantity float64   `gorm:"column:allocated_quantity"`
-	RemainingQuantity float64   `gorm:"column:remaining_quantity"`
+	LotID             uint            `gorm:"column:lot_id"`
+	LedgerID          int             `gorm:"column:ledger_id"`
+	AccountID         uint            `gorm:"column:account_id"`
+	SecurityID        uint            `gorm:"column:security_id"`
+	SecurityTicker    string          `gorm:"column:security_ticker"`
+	SecurityName      string          `gorm:"column:security_name"`
+	Quantity          decimal.Decimal `gorm:"column:quantity"`
+	Price             decimal.Decimal `gorm:"column:price"`
+	TradePrice        decimal.Decimal `gorm:"column:trade_price"`
+	Fee               decimal.Decimal `gorm:"column:fee"`
+	Tax               decimal.Decimal `gorm:"column:tax"`
+	TransactionLineID uint            `gorm:"column:transaction_line_id"`
+	TransactionID     uint            `gorm:"column:transaction_id"`
+	OccurredOn        time.Time       `gorm:"column:occurred_on"`
+	ParentLotID       *uint           `gorm:"column:parent_lot_id"`
+	CorporateActionID *uint           `gorm:"column:corporate_action_id"`
+	TransferID        *uint           `gorm:"column:transfer_id"`
+	OpenedOn          *time.Time      `gorm:"column:opened_on"`
+	ClosedOn          *time.Time      `gorm:"column:closed_on"`
+	AllocatedQuantity decimal.Decimal `gorm:"column:allocated_quantity"`
+	RemainingQuantity decimal.Decimal `gorm:"column:remaining_quantity"`
 }
 
 type lotResponse struct {
-	LotID             uint    `json:"lot_id"`
-	LedgerID          int     `json:"ledger_id"`
-	SecurityID        uint    `json:"security_id"`
-	SecurityTicker    string  `json:"security_ticker"`
-	SecurityName      string  `json:"security_name"`
-	Quantity          float64 `json:"quantity"`
-	Price             float64 `json:"price"`
-	TradePrice        float64 `json:"trade_price"`
-	Fee               float64 `json:"fee"`
-	Tax               float64 `json:"tax"`
-	TransactionLineID uint    `json:"transaction_line_id"`
-	TransactionID     uint    `json:"transaction_id"`
-	OccurredOn        string  `json:"occurred_on"`
-	AllocatedQuantity float64 `json:"allocated_quantity"`
-	RemainingQuantity float64 `json:"remaining_quantity"`
-	Status            string  `json:"status"`
+	LotID             uint            `json:"lot_id"`
+	LedgerID          int             `json:"ledger_id"`
+	AccountID         uint            `json:"account_id"`
+	SecurityID        uint            `json:"security_id"`
+	SecurityTicker    string          `json:"security_ticker"`
+	SecurityName      string          `json:"security_name"`
+	Quantity          decimal.Decimal `json:"quantity"`
+	Price             decimal.Decimal `json:"price"`
+	TradePrice        decimal.Decimal `json:"trade_price"`
+	Fee               decimal.Decimal `json:"fee"`
+	Tax               decimal.Decimal `json:"tax"`
+	TransactionLineID uint            `json:"transaction_line_id"`
+	TransactionID     uint            `json:"transaction_id"`
+	OccurredOn        string          `json:"occurred_on"`
+	ParentLotID       *uint           `json:"parent_lot_id"`
+	CorporateActionID *uint           `json:"corporate_action_id"`
+	TransferID        *uint           `json:"transfer_id"`
+	OpenedOn          string          `json:"opened_on"`
+	ClosedOn          *string         `json:"closed_on"`
+	AllocatedQuantity decimal.Decimal `json:"allocated_quantity"`
+	RemainingQuantity decimal.Decimal `json:"remaining_quantity"`
+	Status            string          `json:"status"`
 }
 
 func (h Handler) listLots(c *gin.Context) {
-	ledgerID := 1
-	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
-		parsed, err := strconv.Atoi(value)
-		if err != nil || parsed <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
-			return
-		}
-		ledgerID = parsed
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
 	}
 
 	var securityID uint
@@ -87,6 +107,16 @@ func (h Handler) listLots(c *gin.Context) {
 		securityID = uint(parsed)
 	}
 
+	var accountID uint
+	if value := strings.TrimSpace(c.Query("account_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		accountID = uint(parsed)
+	}
+
 	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
 	if status != "" && status != "open" && status != "closed" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open or closed"})
@@ -97,6 +127,7 @@ func (h Handler) listLots(c *gin.Context) {
 SELECT
   l.id AS lot_id,
   l.ledger_id,
+  l.account_id,
   l.security_id,
   s.ticker AS security_ticker,
   s.name AS security_name,
@@ -108,6 +139,11 @@ SELECT
   tl.id AS transaction_line_id,
   t.id AS transaction_id,
   t.occurred_on,
+  l.parent_lot_id,
+  l.corporate_action_id,
+  l.transfer_id,
+  l.opened_on,
+  l.closed_on,
   COALESCE(SUM(a.quantity), 0) AS allocated_quantity,
   (l.quantity - COALESCE(SUM(a.quantity), 0)) AS remaining_quantity
 FROM fin_investment_lots l
@@ -122,6 +158,10 @@ WHERE l.deleted_at IS NULL AND l.ledger_id = ?`
 		query += " AND l.security_id = ?"
 		args = append(args, securityID)
 	}
+	if accountID != 0 {
+		query += " AND l.account_id = ?"
+		args = append(args, accountID)
+	}
 
 	query += " GROUP BY l.id, s.id, tl.id, t.id"
 
@@ -134,15 +174,25 @@ WHERE l.deleted_at IS NULL AND l.ledger_id = ?`
 	resp := make([]lotResponse, 0, len(rows))
 	for _, row := range rows {
 		state := "open"
-		if row.RemainingQuantity <= 0 {
+		if !row.RemainingQuantity.IsPositive() || row.ClosedOn != nil {
 			state = "closed"
 		}
+		openedOn := row.OccurredOn
+		if row.OpenedOn != nil {
+			openedOn = *row.OpenedOn
+		}
+		var closedOn *string
+		if row.ClosedOn != nil {
+			value := row.ClosedOn.Format("2006-01-02")
+			closedOn = &value
+		}
 		if status != "" && status != state {
 			continue
 		}
 		resp = append(resp, lotResponse{
 			LotID:             row.LotID,
 			LedgerID:          row.LedgerID,
+			AccountID:         row.AccountID,
 			SecurityID:        row.SecurityID,
 			SecurityTicker:    row.SecurityTicker,
 			SecurityName:      row.SecurityName,
@@ -154,6 +204,11 @@ WHERE l.deleted_at IS NULL AND l.ledger_id = ?`
 			TransactionLineID: row.TransactionLineID,
 			TransactionID:     row.TransactionID,
 			OccurredOn:        row.OccurredOn.Format("2006-01-02"),
+			ParentLotID:       row.ParentLotID,
+			CorporateActionID: row.CorporateActionID,
+			TransferID:        row.TransferID,
+			OpenedOn:          openedOn.Format("2006-01-02"),
+			ClosedOn:          closedOn,
 			AllocatedQuantity: row.AllocatedQuantity,
 			RemainingQuantity: row.RemainingQuantity,
 			Status:            state,
@@ -163,66 +218,34 @@ WHERE l.deleted_at IS NULL AND l.ledger_id = ?`
 	c.JSON(http.StatusOK, resp)
 }
 
-type saleAllocation struct {
-	BuyLotID uint    `json:"buy_lot_id" binding:"required,gt=0"`
-	Quantity float64 `json:"quantity" binding:"required,gt=0"`
-}
-
-type createSaleRequest struct {
-	LedgerID            *int             `json:"ledger_id"`
-	OccurredOn          string           `json:"occurred_on" binding:"required"`
-	SecurityID          uint             `json:"security_id" binding:"required,gt=0"`
-	CashAccountID       uint             `json:"cash_account_id" binding:"required,gt=0"`
-	InvestmentAccountID uint             `json:"investment_account_id" binding:"required,gt=0"`
-	Price               float64          `json:"price" binding:"required,gt=0"`
-	Fee                 float64          `json:"fee"`
-	FeeCategoryID       *int             `json:"fee_category_id"`
-	Tax                 float64          `json:"tax"`
-	TaxCategoryID       *int             `json:"tax_category_id"`
-	Description         string           `json:"description"`
-	Note                string           `json:"note"`
-	Allocations         []saleAllocation `json:"allocations" binding:"required,min=1,dive"`
-}
-
-type createSaleResponse struct {
-	TransactionID uint    `json:"transaction_id"`
-	SaleID        uint    `json:"sale_id"`
-	Quantity      float64 `json:"quantity"`
-	Price         float64 `json:"price"`
-	GrossAmount   float64 `json:"gross_amount"`
-	CostAmount    float64 `json:"cost_amount"`
-	Fee           float64 `json:"fee"`
-	Tax           float64 `json:"tax"`
-}
-
 type createBuyRequest struct {
-	LedgerID            *int    `json:"ledger_id"`
-	OccurredOn          string  `json:"occurred_on" binding:"required"`
-	SecurityID          *uint   `json:"security_id"`
-	SecurityTicker      string  `json:"security_ticker"`
-	SecurityName        string  `json:"security_name"`
-	CashAccountID       uint    `json:"cash_account_id" binding:"required,gt=0"`
-	InvestmentAccountID uint    `json:"investment_account_id" binding:"required,gt=0"`
-	Quantity            float64 `json:"quantity" binding:"required,gt=0"`
-	Price               float64 `json:"price" binding:"required,gt=0"`
-	Fee                 float64 `json:"fee"`
-	FeeCategoryID       *int    `json:"fee_category_id"`
-	Tax                 float64 `json:"tax"`
-	TaxCategoryID       *int    `json:"tax_category_id"`
-	Description         string  `json:"description"`
-	Note                string  `json:"note"`
+	LedgerID            *int            `json:"ledger_id"`
+	OccurredOn          string          `json:"occurred_on" binding:"required"`
+	SecurityID          *uint           `json:"security_id"`
+	SecurityTicker      string          `json:"security_ticker"`
+	SecurityName        string          `json:"security_name"`
+	CashAccountID       uint            `json:"cash_account_id" binding:"required,gt=0"`
+	InvestmentAccountID uint            `json:"investment_account_id" binding:"required,gt=0"`
+	Quantity            decimal.Decimal `json:"quantity"`
+	Price               decimal.Decimal `json:"price"`
+	Fee                 decimal.Decimal `json:"fee"`
+	FeeCategoryID       *int            `json:"fee_category_id"`
+	Tax                 decimal.Decimal `json:"tax"`
+	TaxCategoryID       *int            `json:"tax_category_id"`
+	Description         string          `json:"description"`
+	Note                string          `json:"note"`
 }
 
 type createBuyResponse struct {
-	TransactionID uint    `json:"transaction_id"`
-	LotID         uint    `json:"lot_id"`
-	Quantity      float64 `json:"quantity"`
-	Price         float64 `json:"price"`
-	CostPrice     float64 `json:"cost_price"`
-	GrossAmount   float64 `json:"gross_amount"`
-	CostAmount    float64 `json:"cost_amount"`
-	Fee           float64 `json:"fee"`
-	Tax           float64 `json:"tax"`
+	TransactionID uint            `json:"transaction_id"`
+	LotID         uint            `json:"lot_id"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	Price         decimal.Decimal `json:"price"`
+	CostPrice     decimal.Decimal `json:"cost_price"`
+	GrossAmount   decimal.Decimal `json:"gross_amount"`
+	CostAmount    decimal.Decimal `json:"cost_amount"`
+	Fee           decimal.Decimal `json:"fee"`
+	Tax           decimal.Decimal `json:"tax"`
 }
 
 func (h Handler) createBuy(c *gin.Context) {
@@ -232,13 +255,9 @@ func (h Handler) createBuy(c *gin.Context) {
 		return
 	}
 
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	occurredOnRaw := strings.TrimSpace(req.OccurredOn)
@@ -248,11 +267,13 @@ func (h Handler) createBuy(c *gin.Context) {
 		return
 	}
 
-	if req.Quantity <= 0 || req.Price <= 0 {
+	req.Quantity = money.Quantity(req.Quantity)
+	req.Price = money.Price(req.Price)
+	if !req.Quantity.IsPositive() || !req.Price.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity and price must be greater than 0"})
 		return
 	}
-	if req.Fee < 0 || req.Tax < 0 {
+	if req.Fee.IsNegative() || req.Tax.IsNegative() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "fee and tax cannot be negative"})
 		return
 	}
@@ -264,6 +285,9 @@ func (h Handler) createBuy(c *gin.Context) {
 		if err != nil {
 			return err
 		}
+		if err := ensureNoLaterActions(tx, ledgerID, security.ID, occurredOn, "occurred_on"); err != nil {
+			return err
+		}
 
 		var cashAccount model.Account
 		if err := tx.Where("id = ? AND ledger_id = ?", req.CashAccountID, ledgerID).First(&cashAccount).Error; err != nil {
@@ -301,9 +325,13 @@ func (h Handler) createBuy(c *gin.Context) {
 			}
 		}
 
-		grossAmount := req.Quantity * req.Price
-		costAmount := grossAmount + req.Fee + req.Tax
-		costPrice := costAmount / req.Quantity
+		// 金额按现金账户币种舍入，成本价保留 money.PriceScale 位小数。
+		currency := cashAccount.Currency
+		req.Fee = money.Round(req.Fee, currency)
+		req.Tax = money.Round(req.Tax, currency)
+		grossAmount := money.Round(req.Quantity.Mul(req.Price), currency)
+		costAmount := grossAmount.Add(req.Fee).Add(req.Tax)
+		costPrice := costAmount.DivRound(req.Quantity, money.PriceScale)
 
 		txRecord := model.Transaction{
 			LedgerID:    ledgerID,
@@ -319,32 +347,32 @@ func (h Handler) createBuy(c *gin.Context) {
 			LedgerID:      ledgerID,
 			TransactionID: txRecord.ID,
 			AccountID:     req.CashAccountID,
-			Amount:        -grossAmount,
+			Amount:        grossAmount.Neg(),
 		}
 		if err := tx.Create(&cashLine).Error; err != nil {
 			return err
 		}
 
-		if req.Fee > 0 {
+		if req.Fee.IsPositive() {
 			feeLine := model.TransactionLine{
 				LedgerID:      ledgerID,
 				TransactionID: txRecord.ID,
 				AccountID:     req.CashAccountID,
 				CategoryID:    req.FeeCategoryID,
-				Amount:        -req.Fee,
+				Amount:        req.Fee.Neg(),
 			}
 			if err := tx.Create(&feeLine).Error; err != nil {
 				return err
 			}
 		}
 
-		if req.Tax > 0 {
+		if req.Tax.IsPositive() {
 			taxLine := model.TransactionLine{
 				LedgerID:      ledgerID,
 				TransactionID: txRecord.ID,
 				AccountID:     req.CashAccountID,
 				CategoryID:    req.TaxCategoryID,
-				Amount:        -req.Tax,
+				Amount:        req.Tax.Neg(),
 			}
 			if err := tx.Create(&taxLine).Error; err != nil {
 				return err
@@ -364,6 +392,7 @@ func (h Handler) createBuy(c *gin.Context) {
 		lot := model.InvestmentLot{
 			LedgerID:          ledgerID,
 			TransactionLineID: investmentLine.ID,
+			AccountID:         req.InvestmentAccountID,
 			SecurityID:        security.ID,
 			Quantity:          req.Quantity,
 			Price:             costPrice,
@@ -416,13 +445,9 @@ func (h Handler) updateBuy(c *gin.Context) {
 		return
 	}
 
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	occurredOnRaw := strings.TrimSpace(req.OccurredOn)
@@ -432,11 +457,13 @@ func (h Handler) updateBuy(c *gin.Context) {
 		return
 	}
 
-	if req.Quantity <= 0 || req.Price <= 0 {
+	req.Quantity = money.Quantity(req.Quantity)
+	req.Price = money.Price(req.Price)
+	if !req.Quantity.IsPositive() || !req.Price.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity and price must be greater than 0"})
 		return
 	}
-	if req.Fee < 0 || req.Tax < 0 {
+	if req.Fee.IsNegative() || req.Tax.IsNegative() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "fee and tax cannot be negative"})
 		return
 	}
@@ -453,22 +480,36 @@ func (h Handler) updateBuy(c *gin.Context) {
 			}
 			return err
 		}
+		if lot.ClosedOn != nil || lot.CorporateActionID != nil || lot.TransferID != nil {
+			return newRequestError("buy lot adjusted by a corporate action or transfer, cannot edit")
+		}
 
-		var allocated float64
+		var allocated decimal.Decimal
 		if err := tx.Table("fin_investment_lot_allocations").
 			Select("COALESCE(SUM(quantity), 0)").
 			Where("buy_lot_id = ? AND deleted_at IS NULL", lotID).
 			Scan(&allocated).Error; err != nil {
 			return err
 		}
-		if allocated > 0 {
+		if allocated.IsPositive() {
 			return newRequestError("buy lot already allocated, cannot edit")
 		}
 
+		var dividends int64
+		if err := tx.Model(&model.InvestmentDividend{}).Where("lot_id = ?", lotID).Count(&dividends).Error; err != nil {
+			return err
+		}
+		if dividends > 0 {
+			return newRequestError("reinvested lot must be changed through /api/investments/dividends")
+		}
+
 		security, err := resolveSecurity(tx, ledgerID, req.SecurityID, req.SecurityTicker, req.SecurityName)
 		if err != nil {
 			return err
 		}
+		if err := ensureNoLaterActions(tx, ledgerID, security.ID, occurredOn, "occurred_on"); err != nil {
+			return err
+		}
 
 		var investmentLine model.TransactionLine
 		if err := tx.Where("id = ? AND ledger_id = ?", lot.TransactionLineID, ledgerID).First(&investmentLine).Error; err != nil {
@@ -522,9 +563,13 @@ func (h Handler) updateBuy(c *gin.Context) {
 			}
 		}
 
-		grossAmount := req.Quantity * req.Price
-		costAmount := grossAmount + req.Fee + req.Tax
-		costPrice := costAmount / req.Quantity
+		// 金额按现金账户币种舍入，成本价保留 money.PriceScale 位小数。
+		currency := cashAccount.Currency
+		req.Fee = money.Round(req.Fee, currency)
+		req.Tax = money.Round(req.Tax, currency)
+		grossAmount := money.Round(req.Quantity.Mul(req.Price), currency)
+		costAmount := grossAmount.Add(req.Fee).Add(req.Tax)
+		costPrice := costAmount.DivRound(req.Quantity, money.PriceScale)
 
 		txRecord.OccurredOn = occurredOn
 		txRecord.Description = strings.TrimSpace(req.Description)
@@ -548,38 +593,39 @@ func (h Handler) updateBuy(c *gin.Context) {
 			LedgerID:      ledgerID,
 			TransactionID: txRecord.ID,
 			AccountID:     req.CashAccountID,
-			Amount:        -grossAmount,
+			Amount:        grossAmount.Neg(),
 		}
 		if err := tx.Create(&cashLine).Error; err != nil {
 			return err
 		}
 
-		if req.Fee > 0 {
+		if req.Fee.IsPositive() {
 			feeLine := model.TransactionLine{
 				LedgerID:      ledgerID,
 				TransactionID: txRecord.ID,
 				AccountID:     req.CashAccountID,
 				CategoryID:    req.FeeCategoryID,
-				Amount:        -req.Fee,
+				Amount:        req.Fee.Neg(),
 			}
 			if err := tx.Create(&feeLine).Error; err != nil {
 				return err
 			}
 		}
 
-		if req.Tax > 0 {
+		if req.Tax.IsPositive() {
 			taxLine := model.TransactionLine{
 				LedgerID:      ledgerID,
 				TransactionID: txRecord.ID,
 				AccountID:     req.CashAccountID,
 				CategoryID:    req.TaxCategoryID,
-				Amount:        -req.Tax,
+				Amount:        req.Tax.Neg(),
 			}
 			if err := tx.Create(&taxLine).Error; err != nil {
 				return err
 			}
 		}
 
+		lot.AccountID = req.InvestmentAccountID
 		lot.SecurityID = security.ID
 		lot.Quantity = req.Quantity
 		lot.Price = costPrice
@@ -625,14 +671,9 @@ func (h Handler) deleteBuy(c *gin.Context) {
 		return
 	}
 
-	ledgerID := 1
-	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
-		parsed, err := strconv.Atoi(value)
-		if err != nil || parsed <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
-			return
-		}
-		ledgerID = parsed
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	err := h.db.Transaction(func(tx *gorm.DB) error {
@@ -645,18 +686,29 @@ func (h Handler) deleteBuy(c *gin.Context) {
 			}
 			return err
 		}
+		if lot.ClosedOn != nil || lot.CorporateActionID != nil || lot.TransferID != nil {
+			return newRequestError("buy lot adjusted by a corporate action or transfer, cannot delete")
+		}
 
-		var allocated float64
+		var allocated decimal.Decimal
 		if err := tx.Table("fin_investment_lot_allocations").
 			Select("COALESCE(SUM(quantity), 0)").
 			Where("buy_lot_id = ? AND deleted_at IS NULL", lotID).
 			Scan(&allocated).Error; err != nil {
 			return err
 		}
-		if allocated > 0 {
+		if allocated.IsPositive() {
 			return newRequestError("buy lot already allocated, cannot delete")
 		}
 
+		var dividends int64
+		if err := tx.Model(&model.InvestmentDividend{}).Where("lot_id = ?", lotID).Count(&dividends).Error; err != nil {
+			return err
+		}
+		if dividends > 0 {
+			return newRequestError("reinvested lot must be changed through /api/investments/dividends")
+		}
+
 		var line model.TransactionLine
 		if err := tx.Where("id = ? AND ledger_id = ?", lot.TransactionLineID, ledgerID).First(&line).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -692,267 +744,6 @@ func (h Handler) deleteBuy(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func (h Handler) createSale(c *gin.Context) {
-	var req createSaleRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
-	}
-
-	occurredOnRaw := strings.TrimSpace(req.OccurredOn)
-	occurredOn, err := time.ParseInLocation("2006-01-02", occurredOnRaw, time.Local)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "occurred_on must be YYYY-MM-DD"})
-		return
-	}
-
-	if req.Price <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "price must be greater than 0"})
-		return
-	}
-	if req.Fee < 0 || req.Tax < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "fee and tax cannot be negative"})
-		return
-	}
-
-	allocationMap := make(map[uint]float64)
-	for _, alloc := range req.Allocations {
-		if alloc.Quantity <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "allocation quantity must be greater than 0"})
-			return
-		}
-		allocationMap[alloc.BuyLotID] += alloc.Quantity
-	}
-	if len(allocationMap) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "allocations cannot be empty"})
-		return
-	}
-
-	lotIDs := make([]uint, 0, len(allocationMap))
-	for id := range allocationMap {
-		lotIDs = append(lotIDs, id)
-	}
-	sort.Slice(lotIDs, func(i, j int) bool { return lotIDs[i] < lotIDs[j] })
-
-	var response createSaleResponse
-
-	err = h.db.Transaction(func(tx *gorm.DB) error {
-		var security model.Security
-		if err := tx.Where("id = ? AND ledger_id = ?", req.SecurityID, ledgerID).First(&security).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return newRequestError("security not found")
-			}
-			return err
-		}
-
-		var cashAccount model.Account
-		if err := tx.Where("id = ? AND ledger_id = ?", req.CashAccountID, ledgerID).First(&cashAccount).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return newRequestError("cash account not found")
-			}
-			return err
-		}
-		if !cashAccount.IsActive {
-			return newRequestError("cash account is inactive")
-		}
-
-		var investmentAccount model.Account
-		if err := tx.Where("id = ? AND ledger_id = ?", req.InvestmentAccountID, ledgerID).First(&investmentAccount).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return newRequestError("investment account not found")
-			}
-			return err
-		}
-		if !investmentAccount.IsActive {
-			return newRequestError("investment account is inactive")
-		}
-		if strings.ToLower(investmentAccount.Type) != "investment" {
-			return newRequestError("investment_account_id must be an investment account")
-		}
-
-		if req.FeeCategoryID != nil {
-			if err := validateExpenseCategory(tx, ledgerID, *req.FeeCategoryID); err != nil {
-				return err
-			}
-		}
-		if req.TaxCategoryID != nil {
-			if err := validateExpenseCategory(tx, ledgerID, *req.TaxCategoryID); err != nil {
-				return err
-			}
-		}
-
-		var lots []model.InvestmentLot
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("id IN ? AND ledger_id = ?", lotIDs, ledgerID).
-			Find(&lots).Error; err != nil {
-			return err
-		}
-		if len(lots) != len(lotIDs) {
-			return newRequestError("one or more buy lots not found")
-		}
-
-		lotMap := make(map[uint]model.InvestmentLot, len(lots))
-		for _, lot := range lots {
-			lotMap[lot.ID] = lot
-		}
-
-		type allocSum struct {
-			BuyLotID     uint    `gorm:"column:buy_lot_id"`
-			AllocatedQty float64 `gorm:"column:allocated_qty"`
-		}
-
-		var sums []allocSum
-		if err := tx.Table("fin_investment_lot_allocations").
-			Select("buy_lot_id, COALESCE(SUM(quantity), 0) AS allocated_qty").
-			Where("buy_lot_id IN ? AND deleted_at IS NULL", lotIDs).
-			Group("buy_lot_id").
-			Scan(&sums).Error; err != nil {
-			return err
-		}
-
-		allocatedMap := make(map[uint]float64, len(sums))
-		for _, sum := range sums {
-			allocatedMap[sum.BuyLotID] = sum.AllocatedQty
-		}
-
-		totalQty := 0.0
-		totalCost := 0.0
-		for _, lotID := range lotIDs {
-			lot := lotMap[lotID]
-			if lot.SecurityID != req.SecurityID {
-				return newRequestError("selected lots must share the same security_id")
-			}
-			requestedQty := allocationMap[lotID]
-			remaining := lot.Quantity - allocatedMap[lotID]
-			if requestedQty > remaining+1e-8 {
-				return newRequestError("allocation quantity exceeds remaining lot quantity")
-			}
-			totalQty += requestedQty
-			totalCost += requestedQty * lot.Price
-		}
-
-		if totalQty <= 0 {
-			return newRequestError("total quantity must be greater than 0")
-		}
-
-		grossAmount := totalQty * req.Price
-
-		txRecord := model.Transaction{
-			LedgerID:    ledgerID,
-			OccurredOn:  occurredOn,
-			Description: strings.TrimSpace(req.Description),
-			Note:        strings.TrimSpace(req.Note),
-		}
-		if err := tx.Create(&txRecord).Error; err != nil {
-			return err
-		}
-
-		cashLine := model.TransactionLine{
-			LedgerID:      ledgerID,
-			TransactionID: txRecord.ID,
-			AccountID:     req.CashAccountID,
-			Amount:        grossAmount,
-		}
-		if err := tx.Create(&cashLine).Error; err != nil {
-			return err
-		}
-
-		if req.Fee > 0 {
-			feeLine := model.TransactionLine{
-				LedgerID:      ledgerID,
-				TransactionID: txRecord.ID,
-				AccountID:     req.CashAccountID,
-				CategoryID:    req.FeeCategoryID,
-				Amount:        -req.Fee,
-			}
-			if err := tx.Create(&feeLine).Error; err != nil {
-				return err
-			}
-		}
-
-		if req.Tax > 0 {
-			taxLine := model.TransactionLine{
-				LedgerID:      ledgerID,
-				TransactionID: txRecord.ID,
-				AccountID:     req.CashAccountID,
-				CategoryID:    req.TaxCategoryID,
-				Amount:        -req.Tax,
-			}
-			if err := tx.Create(&taxLine).Error; err != nil {
-				return err
-			}
-		}
-
-		investmentLine := model.TransactionLine{
-			LedgerID:      ledgerID,
-			TransactionID: txRecord.ID,
-			AccountID:     req.InvestmentAccountID,
-			Amount:        -totalCost,
-		}
-		if err := tx.Create(&investmentLine).Error; err != nil {
-			return err
-		}
-
-		sale := model.InvestmentSale{
-			LedgerID:          ledgerID,
-			TransactionLineID: cashLine.ID,
-			SecurityID:        req.SecurityID,
-			Quantity:          totalQty,
-			Price:             req.Price,
-		}
-		if err := tx.Create(&sale).Error; err != nil {
-			return err
-		}
-
-		allocations := make([]model.InvestmentLotAllocation, 0, len(allocationMap))
-		for _, lotID := range lotIDs {
-			allocations = append(allocations, model.InvestmentLotAllocation{
-				LedgerID: ledgerID,
-				BuyLotID: lotID,
-				SaleID:   sale.ID,
-				Quantity: allocationMap[lotID],
-			})
-		}
-		if err := tx.Create(&allocations).Error; err != nil {
-			return err
-		}
-
-		response = createSaleResponse{
-			TransactionID: txRecord.ID,
-			SaleID:        sale.ID,
-			Quantity:      totalQty,
-			Price:         req.Price,
-			GrossAmount:   grossAmount,
-			CostAmount:    totalCost,
-			Fee:           req.Fee,
-			Tax:           req.Tax,
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		var reqErr requestError
-		if errors.As(err, &reqErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create sale"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, response)
-}
-
 type requestError struct {
 	message string
 }
@@ -1020,6 +811,20 @@ func resolveSecurity(tx *gorm.DB, ledgerID int, securityID *uint, tickerRaw stri
 	return security, nil
 }
 
+func validateIncomeCategory(tx *gorm.DB, ledgerID int, categoryID int) error {
+	var category model.Category
+	if err := tx.Where("id = ? AND ledger_id = ?", categoryID, ledgerID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newRequestError("gain category not found")
+		}
+		return err
+	}
+	if category.Kind != model.CategoryKindIncome {
+		return newRequestError("gain category must be income kind")
+	}
+	return nil
+}
+
 func validateExpenseCategory(tx *gorm.DB, ledgerID int, categoryID int) error {
 	var category model.Category
 	if err := tx.Where("id = ? AND ledger_id = ?", categoryID, ledgerID).First(&category).Error; err != nil {
@@ -0,0 +1,1145 @@
+package investment
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 卖出时的批次选择方法。
+const (
+	lotMethodFIFO        = "fifo"
+	lotMethodLIFO        = "lifo"
+	lotMethodHighestCost = "highest-cost"
+	lotMethodLowestCost  = "lowest-cost"
+	lotMethodAverage     = "average"
+	lotMethodSpecific    = "specific"
+)
+
+type saleAllocation struct {
+	BuyLotID uint            `json:"buy_lot_id" binding:"required,gt=0"`
+	Quantity decimal.Decimal `json:"quantity"`
+}
+
+// createSaleRequest 中 method 为 specific 时按 allocations 指定批次卖出；其他方法按 quantity
+// 由服务端从该投资账户的未平仓批次中选取。method 缺省时有 allocations 视为 specific，否则为 fifo。
+type createSaleRequest struct {
+	LedgerID            *int             `json:"ledger_id"`
+	OccurredOn          string           `json:"occurred_on" binding:"required"`
+	SecurityID          uint             `json:"security_id" binding:"required,gt=0"`
+	CashAccountID       uint             `json:"cash_account_id" binding:"required,gt=0"`
+	InvestmentAccountID uint             `json:"investment_account_id" binding:"required,gt=0"`
+	Method              string           `json:"method"`
+	Quantity            decimal.Decimal  `json:"quantity"`
+	Price               decimal.Decimal  `json:"price"`
+	Fee                 decimal.Decimal  `json:"fee"`
+	FeeCategoryID       *int             `json:"fee_category_id"`
+	Tax                 decimal.Decimal  `json:"tax"`
+	TaxCategoryID       *int             `json:"tax_category_id"`
+	GainCategoryID      *int             `json:"gain_category_id"`
+	Description         string           `json:"description"`
+	Note                string           `json:"note"`
+	Allocations         []saleAllocation `json:"allocations" binding:"omitempty,dive"`
+}
+
+type saleAllocationResponse struct {
+	BuyLotID   uint            `json:"buy_lot_id"`
+	AcquiredOn string          `json:"acquired_on"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	CostPrice  decimal.Decimal `json:"cost_price"`
+	CostAmount decimal.Decimal `json:"cost_amount"`
+	Proceeds   decimal.Decimal `json:"proceeds"`
+	Gain       decimal.Decimal `json:"gain"`
+}
+
+// createSaleResponse 也用作预览结果，预览时 transaction_id 与 sale_id 为 0。
+type createSaleResponse struct {
+	TransactionID uint                     `json:"transaction_id"`
+	SaleID        uint                     `json:"sale_id"`
+	Method        string                   `json:"method"`
+	Quantity      decimal.Decimal          `json:"quantity"`
+	Price         decimal.Decimal          `json:"price"`
+	GrossAmount   decimal.Decimal          `json:"gross_amount"`
+	CostAmount    decimal.Decimal          `json:"cost_amount"`
+	RealizedGain  decimal.Decimal          `json:"realized_gain"`
+	Fee           decimal.Decimal          `json:"fee"`
+	Tax           decimal.Decimal          `json:"tax"`
+	NetGain       decimal.Decimal          `json:"net_gain"`
+	Allocations   []saleAllocationResponse `json:"allocations"`
+}
+
+// saleInput 为校验后的卖出请求。
+type saleInput struct {
+	occurredOn  time.Time
+	method      string
+	quantity    decimal.Decimal
+	price       decimal.Decimal
+	allocations map[uint]decimal.Decimal
+}
+
+// plannedAllocation 为选中的批次及卖出数量。
+type plannedAllocation struct {
+	lot        model.InvestmentLot
+	acquiredOn time.Time
+	quantity   decimal.Decimal
+}
+
+// salePlan 为卖出的批次分配与按现金账户币种舍入后的金额。
+type salePlan struct {
+	cashAccount model.Account
+	allocations []plannedAllocation
+	quantity    decimal.Decimal
+	gross       decimal.Decimal
+	cost        decimal.Decimal
+	fee         decimal.Decimal
+	tax         decimal.Decimal
+}
+
+func (h Handler) createSale(c *gin.Context) {
+	var req createSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	input, err := parseSaleRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response createSaleResponse
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		plan, err := planSale(tx, ledgerID, req, input, true)
+		if err != nil {
+			return err
+		}
+
+		txRecord := model.Transaction{
+			LedgerID:    ledgerID,
+			OccurredOn:  input.occurredOn,
+			Description: strings.TrimSpace(req.Description),
+			Note:        strings.TrimSpace(req.Note),
+		}
+		if err := tx.Create(&txRecord).Error; err != nil {
+			return err
+		}
+
+		sale := model.InvestmentSale{LedgerID: ledgerID}
+		if err := writeSale(tx, txRecord, &sale, req, input, plan); err != nil {
+			return err
+		}
+
+		response = plan.response(input)
+		response.TransactionID = txRecord.ID
+		response.SaleID = sale.ID
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create sale"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// previewSale 按与 createSale 相同的规则计算将被卖出的批次与损益，不写入数据。
+func (h Handler) previewSale(c *gin.Context) {
+	var req createSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	input, err := parseSaleRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response createSaleResponse
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		plan, err := planSale(tx, ledgerID, req, input, false)
+		if err != nil {
+			return err
+		}
+		response = plan.response(input)
+		return nil
+	})
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to preview sale"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// parseSaleRequest 校验日期、价格、费税与批次选择方法。
+func parseSaleRequest(req createSaleRequest) (saleInput, error) {
+	occurredOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.OccurredOn), time.Local)
+	if err != nil {
+		return saleInput{}, errors.New("occurred_on must be YYYY-MM-DD")
+	}
+
+	input := saleInput{
+		occurredOn: occurredOn,
+		quantity:   money.Quantity(req.Quantity),
+		price:      money.Price(req.Price),
+	}
+	if !input.price.IsPositive() {
+		return saleInput{}, errors.New("price must be greater than 0")
+	}
+	if req.Fee.IsNegative() || req.Tax.IsNegative() {
+		return saleInput{}, errors.New("fee and tax cannot be negative")
+	}
+
+	input.method, input.allocations, err = parseLotSelection(req.Method, input.quantity, req.Allocations)
+	if err != nil {
+		return saleInput{}, err
+	}
+
+	return input, nil
+}
+
+// parseLotSelection 校验批次选择方法：未填写时有 allocations 视为 specific，否则为 fifo。
+// specific 返回按批次合并的指定数量，quantity 非 0 时须等于其合计；其他方法要求 quantity 大于 0。
+func parseLotSelection(method string, quantity decimal.Decimal, requested []saleAllocation) (string, map[uint]decimal.Decimal, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if method == "" {
+		method = lotMethodFIFO
+		if len(requested) > 0 {
+			method = lotMethodSpecific
+		}
+	}
+
+	switch method {
+	case lotMethodSpecific:
+		allocations := make(map[uint]decimal.Decimal)
+		for _, alloc := range requested {
+			value := money.Quantity(alloc.Quantity)
+			if !value.IsPositive() {
+				return "", nil, errors.New("allocation quantity must be greater than 0")
+			}
+			allocations[alloc.BuyLotID] = allocations[alloc.BuyLotID].Add(value)
+		}
+		if len(allocations) == 0 {
+			return "", nil, errors.New("allocations cannot be empty")
+		}
+		total := decimal.Zero
+		for _, value := range allocations {
+			total = total.Add(value)
+		}
+		if !quantity.IsZero() && !quantity.Equal(total) {
+			return "", nil, errors.New("quantity must equal the sum of allocations")
+		}
+		return method, allocations, nil
+	case lotMethodFIFO, lotMethodLIFO, lotMethodHighestCost, lotMethodLowestCost, lotMethodAverage:
+		if len(requested) > 0 {
+			return "", nil, errors.New("allocations are only allowed with method specific")
+		}
+		if !quantity.IsPositive() {
+			return "", nil, errors.New("quantity must be greater than 0")
+		}
+		return method, nil, nil
+	default:
+		return "", nil, errors.New("method must be one of: fifo, lifo, highest-cost, lowest-cost, average, specific")
+	}
+}
+
+// planSale 校验证券、账户与分类，读取批次剩余数量并确定批次分配与金额。lock 为 true 时
+// 对批次加行锁，供随后写入的卖出使用；只读的预览不加锁，以免阻塞编辑者。
+func planSale(tx *gorm.DB, ledgerID int, req createSaleRequest, input saleInput, lock bool) (salePlan, error) {
+	var security model.Security
+	if err := tx.Where("id = ? AND ledger_id = ?", req.SecurityID, ledgerID).First(&security).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return salePlan{}, newRequestError("security not found")
+		}
+		return salePlan{}, err
+	}
+
+	var cashAccount model.Account
+	if err := tx.Where("id = ? AND ledger_id = ?", req.CashAccountID, ledgerID).First(&cashAccount).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return salePlan{}, newRequestError("cash account not found")
+		}
+		return salePlan{}, err
+	}
+	if !cashAccount.IsActive {
+		return salePlan{}, newRequestError("cash account is inactive")
+	}
+
+	var investmentAccount model.Account
+	if err := tx.Where("id = ? AND ledger_id = ?", req.InvestmentAccountID, ledgerID).First(&investmentAccount).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return salePlan{}, newRequestError("investment account not found")
+		}
+		return salePlan{}, err
+	}
+	if !investmentAccount.IsActive {
+		return salePlan{}, newRequestError("investment account is inactive")
+	}
+	if strings.ToLower(investmentAccount.Type) != "investment" {
+		return salePlan{}, newRequestError("investment_account_id must be an investment account")
+	}
+	if cashAccount.ID == investmentAccount.ID || strings.ToLower(cashAccount.Type) == "investment" {
+		return salePlan{}, newRequestError("cash_account_id must be a non-investment account")
+	}
+	if !strings.EqualFold(investmentAccount.Currency, cashAccount.Currency) {
+		return salePlan{}, newRequestError("investment account currency must match cash account currency")
+	}
+
+	// 手续费、税费行记在现金账户上，须带支出分类才能保持分录平衡。
+	if money.Round(req.Fee, cashAccount.Currency).IsPositive() && req.FeeCategoryID == nil {
+		return salePlan{}, newRequestError("fee_category_id is required when fee is greater than 0")
+	}
+	if money.Round(req.Tax, cashAccount.Currency).IsPositive() && req.TaxCategoryID == nil {
+		return salePlan{}, newRequestError("tax_category_id is required when tax is greater than 0")
+	}
+	if req.FeeCategoryID != nil {
+		if err := validateExpenseCategory(tx, ledgerID, *req.FeeCategoryID); err != nil {
+			return salePlan{}, err
+		}
+	}
+	if req.TaxCategoryID != nil {
+		if err := validateExpenseCategory(tx, ledgerID, *req.TaxCategoryID); err != nil {
+			return salePlan{}, err
+		}
+	}
+	if req.GainCategoryID != nil {
+		if err := validateIncomeCategory(tx, ledgerID, *req.GainCategoryID); err != nil {
+			return salePlan{}, err
+		}
+	}
+
+	var lotIDs []uint
+	if input.method == lotMethodSpecific {
+		for id := range input.allocations {
+			lotIDs = append(lotIDs, id)
+		}
+	} else {
+		ids, err := openLotIDs(tx, ledgerID, req.SecurityID, req.InvestmentAccountID, input.occurredOn)
+		if err != nil {
+			return salePlan{}, err
+		}
+		lotIDs = ids
+	}
+	sort.Slice(lotIDs, func(i, j int) bool { return lotIDs[i] < lotIDs[j] })
+
+	lots, err := loadOpenLots(tx, ledgerID, lotIDs, lock)
+	if err != nil {
+		return salePlan{}, err
+	}
+
+	var allocations []plannedAllocation
+	if input.method == lotMethodSpecific {
+		if len(lots) != len(lotIDs) {
+			return salePlan{}, newRequestError("one or more buy lots not found")
+		}
+		for _, item := range lots {
+			if item.lot.SecurityID != req.SecurityID {
+				return salePlan{}, newRequestError("selected lots must share the same security_id")
+			}
+			if item.lot.AccountID != req.InvestmentAccountID {
+				return salePlan{}, newRequestError("selected lots must be held in investment_account_id")
+			}
+			if item.lot.ClosedOn != nil {
+				return salePlan{}, newRequestError("selected lot was closed by a corporate action")
+			}
+			openedOn := item.acquiredOn
+			if item.lot.OpenedOn != nil {
+				openedOn = *item.lot.OpenedOn
+			}
+			if openedOn.After(input.occurredOn) {
+				return salePlan{}, newRequestError("selected lot was opened after occurred_on")
+			}
+			quantity := input.allocations[item.lot.ID]
+			if quantity.GreaterThan(item.remaining) {
+				return salePlan{}, newRequestError("allocation quantity exceeds remaining lot quantity")
+			}
+			allocations = append(allocations, plannedAllocation{lot: item.lot, acquiredOn: item.acquiredOn, quantity: quantity})
+		}
+	} else {
+		allocations, err = selectLots(lots, input.method, input.quantity)
+		if err != nil {
+			return salePlan{}, err
+		}
+	}
+
+	currency := cashAccount.Currency
+	plan := salePlan{
+		cashAccount: cashAccount,
+		allocations: allocations,
+		fee:         money.Round(req.Fee, currency),
+		tax:         money.Round(req.Tax, currency),
+	}
+	for _, item := range allocations {
+		plan.quantity = plan.quantity.Add(item.quantity)
+		plan.cost = plan.cost.Add(item.quantity.Mul(item.lot.Price))
+	}
+	if !plan.quantity.IsPositive() {
+		return salePlan{}, newRequestError("total quantity must be greater than 0")
+	}
+	plan.cost = money.Round(plan.cost, currency)
+	plan.gross = money.Round(plan.quantity.Mul(input.price), currency)
+	return plan, nil
+}
+
+// openLotIDs 返回该投资账户下、指定日期及之前开立且未被公司行动或转移关闭的批次，供自动选择批次使用。
+func openLotIDs(tx *gorm.DB, ledgerID int, securityID, accountID uint, on time.Time) ([]uint, error) {
+	var lotIDs []uint
+	err := tx.Table("fin_investment_lots l").
+		Joins("JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.closed_on IS NULL AND l.ledger_id = ? AND l.security_id = ? AND l.account_id = ? AND COALESCE(l.opened_on, t.occurred_on) <= ?",
+			ledgerID, securityID, accountID, on).
+		Pluck("l.id", &lotIDs).Error
+	return lotIDs, err
+}
+
+// openLot 为加锁读取的批次及其剩余数量。
+type openLot struct {
+	lot        model.InvestmentLot
+	acquiredOn time.Time
+	remaining  decimal.Decimal
+}
+
+// lockOpenLots 对批次加行锁后读取买入日期与剩余数量，结果按 id 排序。
+func lockOpenLots(tx *gorm.DB, ledgerID int, lotIDs []uint) ([]openLot, error) {
+	return loadOpenLots(tx, ledgerID, lotIDs, true)
+}
+
+// loadOpenLots 读取批次的买入日期与剩余数量，lock 为 true 时加行锁，结果按 id 排序。
+func loadOpenLots(tx *gorm.DB, ledgerID int, lotIDs []uint, lock bool) ([]openLot, error) {
+	if len(lotIDs) == 0 {
+		return nil, nil
+	}
+
+	query := tx
+	if lock {
+		query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var lots []model.InvestmentLot
+	if err := query.
+		Where("id IN ? AND ledger_id = ?", lotIDs, ledgerID).
+		Order("id").
+		Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	type lotInfo struct {
+		LotID        uint            `gorm:"column:lot_id"`
+		OccurredOn   time.Time       `gorm:"column:occurred_on"`
+		AllocatedQty decimal.Decimal `gorm:"column:allocated_qty"`
+	}
+	var infos []lotInfo
+	if err := tx.Raw(`
+SELECT l.id AS lot_id, t.occurred_on,
+  (SELECT COALESCE(SUM(a.quantity), 0) FROM fin_investment_lot_allocations a
+   WHERE a.buy_lot_id = l.id AND a.deleted_at IS NULL) AS allocated_qty
+FROM fin_investment_lots l
+JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id
+JOIN fin_transactions t ON t.id = tl.transaction_id
+WHERE l.id IN ?`, lotIDs).Scan(&infos).Error; err != nil {
+		return nil, err
+	}
+	infoMap := make(map[uint]lotInfo, len(infos))
+	for _, info := range infos {
+		infoMap[info.LotID] = info
+	}
+
+	result := make([]openLot, 0, len(lots))
+	for _, lot := range lots {
+		info := infoMap[lot.ID]
+		result = append(result, openLot{
+			lot:        lot,
+			acquiredOn: info.OccurredOn,
+			remaining:  lot.Quantity.Sub(info.AllocatedQty),
+		})
+	}
+	return result, nil
+}
+
+// selectLots 按方法从未平仓批次中选取 quantity：fifo/lifo 按买入日期，highest-cost/lowest-cost
+// 按成本价（同价按买入日期），average 按各批次剩余数量比例分摊，使成本等于平均成本。
+func selectLots(lots []openLot, method string, quantity decimal.Decimal) ([]plannedAllocation, error) {
+	open := make([]openLot, 0, len(lots))
+	available := decimal.Zero
+	for _, item := range lots {
+		if item.remaining.IsPositive() {
+			open = append(open, item)
+			available = available.Add(item.remaining)
+		}
+	}
+	if quantity.GreaterThan(available) {
+		return nil, newRequestError("quantity exceeds open lot quantity (" + available.String() + ")")
+	}
+
+	byDate := func(a, b openLot) bool {
+		if !a.acquiredOn.Equal(b.acquiredOn) {
+			return a.acquiredOn.Before(b.acquiredOn)
+		}
+		return a.lot.ID < b.lot.ID
+	}
+	switch method {
+	case lotMethodFIFO, lotMethodAverage:
+		sort.SliceStable(open, func(i, j int) bool { return byDate(open[i], open[j]) })
+	case lotMethodLIFO:
+		sort.SliceStable(open, func(i, j int) bool { return byDate(open[j], open[i]) })
+	case lotMethodHighestCost:
+		sort.SliceStable(open, func(i, j int) bool {
+			if !open[i].lot.Price.Equal(open[j].lot.Price) {
+				return open[i].lot.Price.GreaterThan(open[j].lot.Price)
+			}
+			return byDate(open[i], open[j])
+		})
+	case lotMethodLowestCost:
+		sort.SliceStable(open, func(i, j int) bool {
+			if !open[i].lot.Price.Equal(open[j].lot.Price) {
+				return open[i].lot.Price.LessThan(open[j].lot.Price)
+			}
+			return byDate(open[i], open[j])
+		})
+	}
+
+	quantities := make([]decimal.Decimal, len(open))
+	left := quantity
+	if method == lotMethodAverage {
+		// 先按比例向下截断，再把截断差额依次补到仍有余量的批次上。
+		for i, item := range open {
+			quantities[i] = quantity.Mul(item.remaining).Div(available).Truncate(money.QuantityScale)
+			left = left.Sub(quantities[i])
+		}
+	}
+	for i, item := range open {
+		if !left.IsPositive() {
+			break
+		}
+		take := decimal.Min(left, item.remaining.Sub(quantities[i]))
+		quantities[i] = quantities[i].Add(take)
+		left = left.Sub(take)
+	}
+
+	allocations := make([]plannedAllocation, 0, len(open))
+	for i, item := range open {
+		if quantities[i].IsPositive() {
+			allocations = append(allocations, plannedAllocation{lot: item.lot, acquiredOn: item.acquiredOn, quantity: quantities[i]})
+		}
+	}
+	return allocations, nil
+}
+
+// response 生成卖出结果；各批次成本与收入单独舍入，合计以整笔卖出为准。
+func (p salePlan) response(input saleInput) createSaleResponse {
+	currency := p.cashAccount.Currency
+	resp := createSaleResponse{
+		Method:       input.method,
+		Quantity:     p.quantity,
+		Price:        input.price,
+		GrossAmount:  p.gross,
+		CostAmount:   p.cost,
+		RealizedGain: p.gross.Sub(p.cost),
+		Fee:          p.fee,
+		Tax:          p.tax,
+		NetGain:      p.gross.Sub(p.cost).Sub(p.fee).Sub(p.tax),
+		Allocations:  make([]saleAllocationResponse, 0, len(p.allocations)),
+	}
+	for _, item := range p.allocations {
+		cost := money.Round(item.quantity.Mul(item.lot.Price), currency)
+		proceeds := money.Round(item.quantity.Mul(input.price), currency)
+		resp.Allocations = append(resp.Allocations, saleAllocationResponse{
+			BuyLotID:   item.lot.ID,
+			AcquiredOn: item.acquiredOn.Format("2006-01-02"),
+			Quantity:   item.quantity,
+			CostPrice:  item.lot.Price,
+			CostAmount: cost,
+			Proceeds:   proceeds,
+			Gain:       proceeds.Sub(cost),
+		})
+	}
+	return resp
+}
+
+// writeSale 写入卖出分录、保存卖出记录（新建或覆盖）并按 plan 写入批次分配。
+func writeSale(tx *gorm.DB, txRecord model.Transaction, sale *model.InvestmentSale, req createSaleRequest, input saleInput, plan salePlan) error {
+	cashLine, err := writeSaleLines(tx, txRecord, req.CashAccountID, req.InvestmentAccountID, saleAmounts{
+		gross:          plan.gross,
+		cost:           plan.cost,
+		fee:            plan.fee,
+		feeCategoryID:  req.FeeCategoryID,
+		tax:            plan.tax,
+		taxCategoryID:  req.TaxCategoryID,
+		gainCategoryID: req.GainCategoryID,
+	})
+	if err != nil {
+		return err
+	}
+
+	sale.TransactionLineID = cashLine.ID
+	sale.SecurityID = req.SecurityID
+	sale.Quantity = plan.quantity
+	sale.Price = input.price
+	sale.Fee = plan.fee
+	sale.Tax = plan.tax
+	sale.LotMethod = input.method
+	if err := tx.Save(sale).Error; err != nil {
+		return err
+	}
+
+	allocations := make([]model.InvestmentLotAllocation, 0, len(plan.allocations))
+	for _, item := range plan.allocations {
+		allocations = append(allocations, model.InvestmentLotAllocation{
+			LedgerID: sale.LedgerID,
+			BuyLotID: item.lot.ID,
+			SaleID:   sale.ID,
+			Quantity: item.quantity,
+		})
+	}
+	return tx.Create(&allocations).Error
+}
+
+// saleAmounts 为卖出交易按现金账户币种舍入后的金额。
+type saleAmounts struct {
+	gross          decimal.Decimal
+	cost           decimal.Decimal
+	fee            decimal.Decimal
+	feeCategoryID  *int
+	tax            decimal.Decimal
+	taxCategoryID  *int
+	gainCategoryID *int
+}
+
+// writeSaleLines 写入卖出分录并返回现金行：现金账户 +gross，投资账户 -gross，
+// 已实现损益（gross - cost）作为投资账户上带收入分类的行，使投资账户净减少 cost、
+// 无分类行合计为 0；手续费与税费为现金账户上带支出分类的负数行。
+func writeSaleLines(tx *gorm.DB, txRecord model.Transaction, cashAccountID, investmentAccountID uint, amounts saleAmounts) (model.TransactionLine, error) {
+	cashLine := model.TransactionLine{
+		LedgerID:      txRecord.LedgerID,
+		TransactionID: txRecord.ID,
+		AccountID:     cashAccountID,
+		Amount:        amounts.gross,
+	}
+	if err := tx.Create(&cashLine).Error; err != nil {
+		return model.TransactionLine{}, err
+	}
+
+	lines := []model.TransactionLine{
+		{AccountID: investmentAccountID, Amount: amounts.gross.Neg()},
+	}
+
+	gain := amounts.gross.Sub(amounts.cost)
+	if !gain.IsZero() {
+		categoryID, err := gainCategory(tx, txRecord.LedgerID, amounts.gainCategoryID)
+		if err != nil {
+			return model.TransactionLine{}, err
+		}
+		lines = append(lines, model.TransactionLine{
+			AccountID:  investmentAccountID,
+			CategoryID: &categoryID,
+			Amount:     gain,
+			Note:       "realized gain",
+		})
+	}
+	if amounts.fee.IsPositive() {
+		lines = append(lines, model.TransactionLine{
+			AccountID:  cashAccountID,
+			CategoryID: amounts.feeCategoryID,
+			Amount:     amounts.fee.Neg(),
+		})
+	}
+	if amounts.tax.IsPositive() {
+		lines = append(lines, model.TransactionLine{
+			AccountID:  cashAccountID,
+			CategoryID: amounts.taxCategoryID,
+			Amount:     amounts.tax.Neg(),
+		})
+	}
+
+	for i := range lines {
+		lines[i].LedgerID = txRecord.LedgerID
+		lines[i].TransactionID = txRecord.ID
+	}
+	if err := tx.Create(&lines).Error; err != nil {
+		return model.TransactionLine{}, err
+	}
+	return cashLine, nil
+}
+
+// realizedGainCategoryName 为账本未配置已实现损益分类时自动创建的收入分类名称。
+const realizedGainCategoryName = "Realized Gains"
+
+// gainCategory 确定已实现损益记入的收入分类：优先使用请求指定的分类，其次为账本的
+// realized_gain_category_id；都没有时使用（必要时创建）名为 Realized Gains 的收入分类并记到账本上。
+func gainCategory(tx *gorm.DB, ledgerID int, requested *int) (int, error) {
+	if requested != nil {
+		if err := validateIncomeCategory(tx, ledgerID, *requested); err != nil {
+			return 0, err
+		}
+		return *requested, nil
+	}
+
+	var ledgerRecord model.Ledger
+	if err := tx.First(&ledgerRecord, ledgerID).Error; err != nil {
+		return 0, err
+	}
+	if ledgerRecord.RealizedGainCategoryID != nil {
+		return *ledgerRecord.RealizedGainCategoryID, nil
+	}
+
+	var category model.Category
+	err := tx.Where("ledger_id = ? AND kind = ? AND name = ? AND parent_id IS NULL", ledgerID, model.CategoryKindIncome, realizedGainCategoryName).
+		First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		category = model.Category{
+			LedgerID: ledgerID,
+			Name:     realizedGainCategoryName,
+			Kind:     model.CategoryKindIncome,
+		}
+		err = tx.Create(&category).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Model(&model.Ledger{}).Where("id = ?", ledgerID).
+		Update("realized_gain_category_id", category.ID).Error; err != nil {
+		return 0, err
+	}
+	return category.ID, nil
+}
+
+// saleResponse 为卖出记录详情：金额以现金账户币种计，成本与损益按匹配批次的成本价计算。
+type saleResponse struct {
+	createSaleResponse
+	LedgerID            int    `json:"ledger_id"`
+	OccurredOn          string `json:"occurred_on"`
+	SecurityID          uint   `json:"security_id"`
+	SecurityTicker      string `json:"security_ticker"`
+	SecurityName        string `json:"security_name"`
+	CashAccountID       uint   `json:"cash_account_id"`
+	InvestmentAccountID uint   `json:"investment_account_id"`
+	Currency            string `json:"currency"`
+	FeeCategoryID       *int   `json:"fee_category_id"`
+	TaxCategoryID       *int   `json:"tax_category_id"`
+	GainCategoryID      *int   `json:"gain_category_id"`
+	Description         string `json:"description"`
+	Note                string `json:"note"`
+}
+
+type saleFilter struct {
+	saleID     uint
+	securityID uint
+	dateFrom   *time.Time
+	dateTo     *time.Time
+}
+
+func (h Handler) listSales(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var filter saleFilter
+	if value := strings.TrimSpace(c.Query("security_id")); value != "" {
+		parsed, ok := parseUintID(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security_id"})
+			return
+		}
+		filter.securityID = parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateFrom = &parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateTo = &parsed
+	}
+
+	sales, err := loadSales(h.db, ledgerID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query sales"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sales)
+}
+
+func (h Handler) getSale(c *gin.Context) {
+	saleID, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sale id"})
+		return
+	}
+
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	sales, err := loadSales(h.db, ledgerID, saleFilter{saleID: saleID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query sale"})
+		return
+	}
+	if len(sales) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "sale not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sales[0])
+}
+
+// updateSale 按与 createSale 相同的请求整体替换卖出：先释放原批次分配，再重新选取批次，
+// 并重写交易的全部分录（现金、投资、损益、手续费、税费）。
+func (h Handler) updateSale(c *gin.Context) {
+	saleID, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sale id"})
+		return
+	}
+
+	var req createSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	input, err := parseSaleRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response createSaleResponse
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		sale, txRecord, err := lockSale(tx, ledgerID, saleID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("sale_id = ?", sale.ID).Delete(&model.InvestmentLotAllocation{}).Error; err != nil {
+			return err
+		}
+
+		plan, err := planSale(tx, ledgerID, req, input, true)
+		if err != nil {
+			return err
+		}
+
+		txRecord.OccurredOn = input.occurredOn
+		txRecord.Description = strings.TrimSpace(req.Description)
+		txRecord.Note = strings.TrimSpace(req.Note)
+		if err := tx.Save(&txRecord).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("transaction_id = ? AND ledger_id = ?", txRecord.ID, ledgerID).
+			Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+
+		if err := writeSale(tx, txRecord, &sale, req, input, plan); err != nil {
+			return err
+		}
+
+		response = plan.response(input)
+		response.TransactionID = txRecord.ID
+		response.SaleID = sale.ID
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update sale"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// deleteSale 撤销卖出：删除批次分配、卖出记录及其交易，批次剩余数量随之恢复。
+func (h Handler) deleteSale(c *gin.Context) {
+	saleID, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sale id"})
+		return
+	}
+
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		sale, txRecord, err := lockSale(tx, ledgerID, saleID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("sale_id = ?", sale.ID).Delete(&model.InvestmentLotAllocation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.InvestmentSale{}, sale.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ? AND ledger_id = ?", txRecord.ID, ledgerID).
+			Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Transaction{}, txRecord.ID).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete sale"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// lockSale 对卖出记录加行锁，并返回其现金行所属的交易；碎股补偿卖出与匹配了已调整批次的卖出不可修改。
+func lockSale(tx *gorm.DB, ledgerID int, saleID uint) (model.InvestmentSale, model.Transaction, error) {
+	var sale model.InvestmentSale
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND ledger_id = ?", saleID, ledgerID).
+		First(&sale).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.InvestmentSale{}, model.Transaction{}, newRequestError("sale not found")
+		}
+		return model.InvestmentSale{}, model.Transaction{}, err
+	}
+	if sale.CorporateActionID != nil {
+		return model.InvestmentSale{}, model.Transaction{}, newRequestError("cash-in-lieu sales must be changed through the corporate action")
+	}
+
+	// 公司行动按当时的剩余数量调整批次，之后再改动更早的卖出会使调整结果失真。
+	var adjusted int64
+	if err := tx.Table("fin_investment_lot_allocations a").
+		Joins("JOIN fin_investment_lots l ON l.id = a.buy_lot_id").
+		Where("a.sale_id = ? AND a.deleted_at IS NULL AND l.closed_on IS NOT NULL", sale.ID).
+		Count(&adjusted).Error; err != nil {
+		return model.InvestmentSale{}, model.Transaction{}, err
+	}
+	if adjusted > 0 {
+		return model.InvestmentSale{}, model.Transaction{}, newRequestError("sale matches lots adjusted by a later corporate action or transfer")
+	}
+
+	var cashLine model.TransactionLine
+	if err := tx.Where("id = ? AND ledger_id = ?", sale.TransactionLineID, ledgerID).First(&cashLine).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.InvestmentSale{}, model.Transaction{}, newRequestError("transaction line not found")
+		}
+		return model.InvestmentSale{}, model.Transaction{}, err
+	}
+
+	var txRecord model.Transaction
+	if err := tx.Where("id = ? AND ledger_id = ?", cashLine.TransactionID, ledgerID).First(&txRecord).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.InvestmentSale{}, model.Transaction{}, newRequestError("transaction not found")
+		}
+		return model.InvestmentSale{}, model.Transaction{}, err
+	}
+
+	return sale, txRecord, nil
+}
+
+// loadSales 按卖出日期与 id 排序返回卖出详情。投资账户与各分类从交易分录还原：
+// 投资账户为非现金账户上的无分类行，损益为投资账户上的分类行，现金账户上其余行依次为手续费、税费。
+func loadSales(db *gorm.DB, ledgerID int, filter saleFilter) ([]saleResponse, error) {
+	type saleRow struct {
+		model.InvestmentSale
+		TransactionID  uint            `gorm:"column:transaction_id"`
+		OccurredOn     time.Time       `gorm:"column:occurred_on"`
+		Description    string          `gorm:"column:description"`
+		Note           string          `gorm:"column:note"`
+		CashAccountID  uint            `gorm:"column:cash_account_id"`
+		Currency       string          `gorm:"column:currency"`
+		GrossAmount    decimal.Decimal `gorm:"column:gross_amount"`
+		SecurityTicker string          `gorm:"column:security_ticker"`
+		SecurityName   string          `gorm:"column:security_name"`
+	}
+
+	query := db.Table("fin_investment_sales sale").
+		Select(`sale.*, t.id AS transaction_id, t.occurred_on, t.description, t.note,
+  tl.account_id AS cash_account_id, a.currency, tl.amount AS gross_amount,
+  s.ticker AS security_ticker, s.name AS security_name`).
+		Joins("JOIN fin_transaction_lines tl ON tl.id = sale.transaction_line_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Joins("JOIN fin_accounts a ON a.id = tl.account_id").
+		Joins("JOIN fin_securities s ON s.id = sale.security_id").
+		Where("sale.deleted_at IS NULL AND sale.ledger_id = ?", ledgerID)
+	if filter.saleID != 0 {
+		query = query.Where("sale.id = ?", filter.saleID)
+	}
+	if filter.securityID != 0 {
+		query = query.Where("sale.security_id = ?", filter.securityID)
+	}
+	if filter.dateFrom != nil {
+		query = query.Where("t.occurred_on >= ?", *filter.dateFrom)
+	}
+	if filter.dateTo != nil {
+		query = query.Where("t.occurred_on <= ?", *filter.dateTo)
+	}
+
+	var rows []saleRow
+	if err := query.Order("t.occurred_on, sale.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []saleResponse{}, nil
+	}
+
+	saleIDs := make([]uint, 0, len(rows))
+	transactionIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		saleIDs = append(saleIDs, row.ID)
+		transactionIDs = append(transactionIDs, row.TransactionID)
+	}
+
+	var lines []model.TransactionLine
+	if err := db.Where("transaction_id IN ?", transactionIDs).Order("id").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	linesByTransaction := make(map[uint][]model.TransactionLine)
+	for _, line := range lines {
+		linesByTransaction[line.TransactionID] = append(linesByTransaction[line.TransactionID], line)
+	}
+
+	type allocationRow struct {
+		model.InvestmentLot
+		SaleID       uint            `gorm:"column:sale_id"`
+		AllocatedQty decimal.Decimal `gorm:"column:allocated_qty"`
+		AcquiredOn   time.Time       `gorm:"column:acquired_on"`
+	}
+	var allocationRows []allocationRow
+	if err := db.Table("fin_investment_lot_allocations alloc").
+		Select("l.*, alloc.sale_id, alloc.quantity AS allocated_qty, t.occurred_on AS acquired_on").
+		Joins("JOIN fin_investment_lots l ON l.id = alloc.buy_lot_id").
+		Joins("JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id").
+		Where("alloc.deleted_at IS NULL AND alloc.sale_id IN ?", saleIDs).
+		Order("alloc.id").
+		Scan(&allocationRows).Error; err != nil {
+		return nil, err
+	}
+	allocationsBySale := make(map[uint][]plannedAllocation)
+	for _, row := range allocationRows {
+		allocationsBySale[row.SaleID] = append(allocationsBySale[row.SaleID], plannedAllocation{
+			lot:        row.InvestmentLot,
+			acquiredOn: row.AcquiredOn,
+			quantity:   row.AllocatedQty,
+		})
+	}
+
+	result := make([]saleResponse, 0, len(rows))
+	for _, row := range rows {
+		plan := salePlan{
+			cashAccount: model.Account{Currency: row.Currency},
+			allocations: allocationsBySale[row.ID],
+			quantity:    row.Quantity,
+			gross:       row.GrossAmount,
+			fee:         row.Fee,
+			tax:         row.Tax,
+		}
+		for _, item := range plan.allocations {
+			plan.cost = plan.cost.Add(item.quantity.Mul(item.lot.Price))
+		}
+		plan.cost = money.Round(plan.cost, row.Currency)
+
+		item := saleResponse{
+			createSaleResponse:  plan.response(saleInput{method: row.LotMethod, price: row.Price}),
+			LedgerID:            row.LedgerID,
+			OccurredOn:          row.OccurredOn.Format("2006-01-02"),
+			SecurityID:          row.SecurityID,
+			SecurityTicker:      row.SecurityTicker,
+			SecurityName:        row.SecurityName,
+			CashAccountID:       row.CashAccountID,
+			InvestmentAccountID: row.CashAccountID,
+			Currency:            row.Currency,
+			Description:         row.Description,
+			Note:                row.Note,
+		}
+		item.TransactionID = row.TransactionID
+		item.SaleID = row.ID
+
+		var cashCategoryIDs []*int
+		for _, line := range linesByTransaction[row.TransactionID] {
+			switch {
+			case line.ID == row.TransactionLineID:
+			case line.AccountID == row.CashAccountID:
+				cashCategoryIDs = append(cashCategoryIDs, line.CategoryID)
+			case line.CategoryID == nil:
+				item.InvestmentAccountID = line.AccountID
+			default:
+				item.GainCategoryID = line.CategoryID
+			}
+		}
+		if row.Fee.IsPositive() && len(cashCategoryIDs) > 0 {
+			item.FeeCategoryID = cashCategoryIDs[0]
+			cashCategoryIDs = cashCategoryIDs[1:]
+		}
+		if row.Tax.IsPositive() && len(cashCategoryIDs) > 0 {
+			item.TaxCategoryID = cashCategoryIDs[0]
+		}
+
+		result = append(result, item)
+	}
+	return result, nil
+}
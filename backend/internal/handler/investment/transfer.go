@@ -0,0 +1,548 @@
+package investment
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// createTransferRequest 将 from_account_id 中某证券的批次实物转入 to_account_id。
+// 批次选择与卖出相同：method + quantity 自动选择，或 allocations 指定批次。
+type createTransferRequest struct {
+	LedgerID      *int             `json:"ledger_id"`
+	OccurredOn    string           `json:"occurred_on" binding:"required"`
+	SecurityID    uint             `json:"security_id" binding:"required,gt=0"`
+	FromAccountID uint             `json:"from_account_id" binding:"required,gt=0"`
+	ToAccountID   uint             `json:"to_account_id" binding:"required,gt=0"`
+	Method        string           `json:"method"`
+	Quantity      decimal.Decimal  `json:"quantity"`
+	Allocations   []saleAllocation `json:"allocations" binding:"omitempty,dive"`
+	Description   string           `json:"description"`
+	Note          string           `json:"note"`
+}
+
+// transferLotResponse 为转入目标账户的批次：lot_id 为新批次，parent_lot_id 为转出的原批次。
+type transferLotResponse struct {
+	LotID       uint            `json:"lot_id"`
+	ParentLotID uint            `json:"parent_lot_id"`
+	AcquiredOn  string          `json:"acquired_on"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	CostPrice   decimal.Decimal `json:"cost_price"`
+	CostAmount  decimal.Decimal `json:"cost_amount"`
+}
+
+type transferResponse struct {
+	ID             uint                  `json:"id"`
+	LedgerID       int                   `json:"ledger_id"`
+	TransactionID  uint                  `json:"transaction_id"`
+	OccurredOn     string                `json:"occurred_on"`
+	SecurityID     uint                  `json:"security_id"`
+	SecurityTicker string                `json:"security_ticker"`
+	SecurityName   string                `json:"security_name"`
+	FromAccountID  uint                  `json:"from_account_id"`
+	ToAccountID    uint                  `json:"to_account_id"`
+	Method         string                `json:"method"`
+	Quantity       decimal.Decimal       `json:"quantity"`
+	CostAmount     decimal.Decimal       `json:"cost_amount"`
+	Description    string                `json:"description"`
+	Note           string                `json:"note"`
+	Lots           []transferLotResponse `json:"lots"`
+}
+
+// transferFilter 为 loadTransfers 的筛选条件，零值表示不限；accountID 匹配转出或转入账户。
+type transferFilter struct {
+	transferID uint
+	securityID uint
+	accountID  uint
+	dateFrom   *time.Time
+	dateTo     *time.Time
+}
+
+// listTransfers 按转移日期升序返回批次实物转移。
+func (h Handler) listTransfers(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var filter transferFilter
+	for _, param := range []struct {
+		name   string
+		target *uint
+	}{{"security_id", &filter.securityID}, {"account_id", &filter.accountID}} {
+		if value := strings.TrimSpace(c.Query(param.name)); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || parsed == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+				return
+			}
+			*param.target = uint(parsed)
+		}
+	}
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateFrom = &parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		filter.dateTo = &parsed
+	}
+
+	resp, err := loadTransfers(h.db, ledgerID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// createTransfer 在转移日关闭所选批次，在目标账户按转移数量生成沿用原买入行（买入日期）与成本价的新批次；
+// 部分转移时，剩余数量在原账户生成新批次。同时写入一笔在两个投资账户间转移成本的分录。
+// 两个账户须为同币种的投资账户；所选批次在转移日当天或之后有卖出、或证券在转移日之后有公司行动时拒绝。
+func (h Handler) createTransfer(c *gin.Context) {
+	var req createTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	occurredOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.OccurredOn), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "occurred_on must be YYYY-MM-DD"})
+		return
+	}
+	if req.FromAccountID == req.ToAccountID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_account_id and to_account_id must differ"})
+		return
+	}
+	quantity := money.Quantity(req.Quantity)
+	method, selection, err := parseLotSelection(req.Method, quantity, req.Allocations)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transferID uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var security model.Security
+		if err := tx.Where("id = ? AND ledger_id = ?", req.SecurityID, ledgerID).First(&security).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("security not found")
+			}
+			return err
+		}
+
+		var accounts []model.Account
+		if err := tx.Where("id IN ? AND ledger_id = ?", []uint{req.FromAccountID, req.ToAccountID}, ledgerID).
+			Find(&accounts).Error; err != nil {
+			return err
+		}
+		if len(accounts) != 2 {
+			return newRequestError("investment account not found")
+		}
+		for _, account := range accounts {
+			if !account.IsActive {
+				return newRequestError("investment account is inactive")
+			}
+			if strings.ToLower(account.Type) != "investment" {
+				return newRequestError("from_account_id and to_account_id must be investment accounts")
+			}
+		}
+		if !strings.EqualFold(accounts[0].Currency, accounts[1].Currency) {
+			return newRequestError("investment accounts must share the same currency")
+		}
+		currency := accounts[0].Currency
+
+		if err := ensureNoLaterActions(tx, ledgerID, req.SecurityID, occurredOn, "occurred_on"); err != nil {
+			return err
+		}
+
+		var lotIDs []uint
+		if method == lotMethodSpecific {
+			for id := range selection {
+				lotIDs = append(lotIDs, id)
+			}
+			sort.Slice(lotIDs, func(i, j int) bool { return lotIDs[i] < lotIDs[j] })
+		} else {
+			ids, err := openLotIDs(tx, ledgerID, req.SecurityID, req.FromAccountID, occurredOn)
+			if err != nil {
+				return err
+			}
+			lotIDs = ids
+		}
+
+		lots, err := lockOpenLots(tx, ledgerID, lotIDs)
+		if err != nil {
+			return err
+		}
+
+		var planned []plannedAllocation
+		if method == lotMethodSpecific {
+			if len(lots) != len(lotIDs) {
+				return newRequestError("one or more buy lots not found")
+			}
+			for _, item := range lots {
+				if item.lot.SecurityID != req.SecurityID {
+					return newRequestError("selected lots must share the same security_id")
+				}
+				if item.lot.AccountID != req.FromAccountID {
+					return newRequestError("selected lots must be held in from_account_id")
+				}
+				if item.lot.ClosedOn != nil {
+					return newRequestError("selected lot was closed by a corporate action or transfer")
+				}
+				openedOn := item.acquiredOn
+				if item.lot.OpenedOn != nil {
+					openedOn = *item.lot.OpenedOn
+				}
+				if openedOn.After(occurredOn) {
+					return newRequestError("selected lot was opened after occurred_on")
+				}
+				if selection[item.lot.ID].GreaterThan(item.remaining) {
+					return newRequestError("allocation quantity exceeds remaining lot quantity")
+				}
+				planned = append(planned, plannedAllocation{lot: item.lot, acquiredOn: item.acquiredOn, quantity: selection[item.lot.ID]})
+			}
+		} else {
+			planned, err = selectLots(lots, method, quantity)
+			if err != nil {
+				return err
+			}
+		}
+
+		plannedIDs := make([]uint, 0, len(planned))
+		for _, item := range planned {
+			plannedIDs = append(plannedIDs, item.lot.ID)
+		}
+		var laterSales int64
+		if err := tx.Table("fin_investment_lot_allocations a").
+			Joins("JOIN fin_investment_sales sale ON sale.id = a.sale_id AND sale.deleted_at IS NULL").
+			Joins("JOIN fin_transaction_lines sl ON sl.id = sale.transaction_line_id AND sl.deleted_at IS NULL").
+			Joins("JOIN fin_transactions st ON st.id = sl.transaction_id AND st.deleted_at IS NULL").
+			Where("a.deleted_at IS NULL AND a.buy_lot_id IN ? AND st.occurred_on >= ?", plannedIDs, occurredOn).
+			Count(&laterSales).Error; err != nil {
+			return err
+		}
+		if laterSales > 0 {
+			return newRequestError("selected lots have sales on or after occurred_on")
+		}
+
+		remaining := make(map[uint]decimal.Decimal, len(lots))
+		for _, item := range lots {
+			remaining[item.lot.ID] = item.remaining
+		}
+		total, cost := decimal.Zero, decimal.Zero
+		for _, item := range planned {
+			total = total.Add(item.quantity)
+			cost = cost.Add(item.quantity.Mul(item.lot.Price))
+		}
+		if !total.IsPositive() {
+			return newRequestError("total quantity must be greater than 0")
+		}
+		cost = money.Round(cost, currency)
+
+		description := strings.TrimSpace(req.Description)
+		if description == "" {
+			description = "transfer " + security.Ticker
+		}
+		txRecord := model.Transaction{
+			LedgerID:    ledgerID,
+			OccurredOn:  occurredOn,
+			Description: description,
+			Note:        strings.TrimSpace(req.Note),
+		}
+		if err := tx.Create(&txRecord).Error; err != nil {
+			return err
+		}
+		for _, line := range []model.TransactionLine{
+			{LedgerID: ledgerID, TransactionID: txRecord.ID, AccountID: req.FromAccountID, Amount: cost.Neg()},
+			{LedgerID: ledgerID, TransactionID: txRecord.ID, AccountID: req.ToAccountID, Amount: cost},
+		} {
+			if err := tx.Create(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		transfer := model.InvestmentTransfer{
+			LedgerID:      ledgerID,
+			TransactionID: txRecord.ID,
+			SecurityID:    req.SecurityID,
+			FromAccountID: req.FromAccountID,
+			ToAccountID:   req.ToAccountID,
+			Quantity:      total,
+			LotMethod:     method,
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+
+		for _, item := range planned {
+			parentID := item.lot.ID
+			moved := model.InvestmentLot{
+				LedgerID:          item.lot.LedgerID,
+				TransactionLineID: item.lot.TransactionLineID,
+				AccountID:         req.ToAccountID,
+				SecurityID:        item.lot.SecurityID,
+				Quantity:          item.quantity,
+				Price:             item.lot.Price,
+				TradePrice:        item.lot.TradePrice,
+				ParentLotID:       &parentID,
+				TransferID:        &transfer.ID,
+				OpenedOn:          &occurredOn,
+			}
+			if err := tx.Create(&moved).Error; err != nil {
+				return err
+			}
+			if left := remaining[item.lot.ID].Sub(item.quantity); left.IsPositive() {
+				kept := moved
+				kept.ID = 0
+				kept.AccountID = req.FromAccountID
+				kept.Quantity = left
+				if err := tx.Create(&kept).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&model.InvestmentLot{}).Where("id = ?", item.lot.ID).
+				Update("closed_on", occurredOn).Error; err != nil {
+				return err
+			}
+		}
+
+		transferID = transfer.ID
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create transfer"})
+		return
+	}
+
+	resp, err := loadTransfers(h.db, ledgerID, transferFilter{transferID: transferID})
+	if err != nil || len(resp) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp[0])
+}
+
+// deleteTransfer 撤销实物转移：删除生成的批次与成本分录并重新开启原批次。
+// 生成的批次已被卖出或再次调整时不可撤销。
+func (h Handler) deleteTransfer(c *gin.Context) {
+	id, ok := parseUintID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transfer id"})
+		return
+	}
+
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var transfer model.InvestmentTransfer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ledger_id = ?", id, ledgerID).
+			First(&transfer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("transfer not found")
+			}
+			return err
+		}
+
+		var children []model.InvestmentLot
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("transfer_id = ?", transfer.ID).
+			Find(&children).Error; err != nil {
+			return err
+		}
+		childIDs := make([]uint, 0, len(children))
+		var parentIDs []uint
+		for _, child := range children {
+			if child.ClosedOn != nil {
+				return newRequestError("lots created by this transfer were adjusted again")
+			}
+			childIDs = append(childIDs, child.ID)
+			if child.ParentLotID != nil {
+				parentIDs = append(parentIDs, *child.ParentLotID)
+			}
+		}
+
+		if len(childIDs) > 0 {
+			var allocations int64
+			if err := tx.Model(&model.InvestmentLotAllocation{}).Where("buy_lot_id IN ?", childIDs).Count(&allocations).Error; err != nil {
+				return err
+			}
+			if allocations > 0 {
+				return newRequestError("lots created by this transfer have been sold")
+			}
+			if err := tx.Delete(&model.InvestmentLot{}, childIDs).Error; err != nil {
+				return err
+			}
+		}
+		if len(parentIDs) > 0 {
+			if err := tx.Model(&model.InvestmentLot{}).Where("id IN ?", parentIDs).
+				Update("closed_on", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&model.InvestmentTransfer{}, transfer.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ? AND ledger_id = ?", transfer.TransactionID, ledgerID).
+			Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Transaction{}, transfer.TransactionID).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete transfer"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// loadTransfers 按转移日期与 id 排序返回实物转移及转入目标账户的批次。
+func loadTransfers(db *gorm.DB, ledgerID int, filter transferFilter) ([]transferResponse, error) {
+	type transferRow struct {
+		model.InvestmentTransfer
+		OccurredOn     time.Time       `gorm:"column:occurred_on"`
+		Description    string          `gorm:"column:description"`
+		Note           string          `gorm:"column:note"`
+		CostAmount     decimal.Decimal `gorm:"column:cost_amount"`
+		SecurityTicker string          `gorm:"column:security_ticker"`
+		SecurityName   string          `gorm:"column:security_name"`
+	}
+
+	query := db.Table("fin_investment_transfers tr").
+		Select("tr.*, t.occurred_on, t.description, t.note, tl.amount AS cost_amount, s.ticker AS security_ticker, s.name AS security_name").
+		Joins("JOIN fin_transactions t ON t.id = tr.transaction_id AND t.deleted_at IS NULL").
+		Joins("JOIN fin_transaction_lines tl ON tl.transaction_id = tr.transaction_id AND tl.account_id = tr.to_account_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_securities s ON s.id = tr.security_id").
+		Where("tr.deleted_at IS NULL AND tr.ledger_id = ?", ledgerID)
+	if filter.transferID != 0 {
+		query = query.Where("tr.id = ?", filter.transferID)
+	}
+	if filter.securityID != 0 {
+		query = query.Where("tr.security_id = ?", filter.securityID)
+	}
+	if filter.accountID != 0 {
+		query = query.Where("tr.from_account_id = ? OR tr.to_account_id = ?", filter.accountID, filter.accountID)
+	}
+	if filter.dateFrom != nil {
+		query = query.Where("t.occurred_on >= ?", *filter.dateFrom)
+	}
+	if filter.dateTo != nil {
+		query = query.Where("t.occurred_on <= ?", *filter.dateTo)
+	}
+
+	var rows []transferRow
+	if err := query.Order("t.occurred_on, tr.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []transferResponse{}, nil
+	}
+
+	transferIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		transferIDs = append(transferIDs, row.ID)
+	}
+
+	type lotRow struct {
+		model.InvestmentLot
+		AcquiredOn time.Time `gorm:"column:acquired_on"`
+		Currency   string    `gorm:"column:currency"`
+	}
+	var lots []lotRow
+	if err := db.Table("fin_investment_lots l").
+		Select("l.*, t.occurred_on AS acquired_on, a.currency").
+		Joins("JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id").
+		Joins("JOIN fin_accounts a ON a.id = l.account_id").
+		Where("l.deleted_at IS NULL AND l.transfer_id IN ?", transferIDs).
+		Order("l.id").
+		Scan(&lots).Error; err != nil {
+		return nil, err
+	}
+	lotsByTransfer := make(map[uint][]lotRow)
+	for _, lot := range lots {
+		lotsByTransfer[*lot.TransferID] = append(lotsByTransfer[*lot.TransferID], lot)
+	}
+
+	result := make([]transferResponse, 0, len(rows))
+	for _, row := range rows {
+		item := transferResponse{
+			ID:             row.ID,
+			LedgerID:       row.LedgerID,
+			TransactionID:  row.TransactionID,
+			OccurredOn:     row.OccurredOn.Format("2006-01-02"),
+			SecurityID:     row.SecurityID,
+			SecurityTicker: row.SecurityTicker,
+			SecurityName:   row.SecurityName,
+			FromAccountID:  row.FromAccountID,
+			ToAccountID:    row.ToAccountID,
+			Method:         row.LotMethod,
+			Quantity:       row.Quantity,
+			CostAmount:     row.CostAmount,
+			Description:    row.Description,
+			Note:           row.Note,
+			Lots:           []transferLotResponse{},
+		}
+		for _, lot := range lotsByTransfer[row.ID] {
+			if lot.AccountID != row.ToAccountID {
+				continue
+			}
+			item.Lots = append(item.Lots, transferLotResponse{
+				LotID:       lot.ID,
+				ParentLotID: *lot.ParentLotID,
+				AcquiredOn:  lot.AcquiredOn.Format("2006-01-02"),
+				Quantity:    lot.Quantity,
+				CostPrice:   lot.Price,
+				CostAmount:  money.Round(lot.Quantity.Mul(lot.Price), lot.Currency),
+			})
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,475 @@
+package journal
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	db *gorm.DB
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	h := Handler{db: db}
+
+	rg.POST("", h.create)
+	rg.GET("/:id", h.get)
+	rg.PUT("/:id", h.update)
+}
+
+type lineRequest struct {
+	AccountID  uint            `json:"account_id" binding:"required,gt=0"`
+	CategoryID *int            `json:"category_id"`
+	Amount     decimal.Decimal `json:"amount"`
+	Note       string          `json:"note"`
+	Tags       []string        `json:"tags"`
+}
+
+type entryRequest struct {
+	LedgerID    *int          `json:"ledger_id"`
+	OccurredOn  string        `json:"occurred_on" binding:"required"`
+	Description string        `json:"description"`
+	Note        string        `json:"note"`
+	Lines       []lineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type lineResponse struct {
+	ID           uint            `json:"id"`
+	AccountID    uint            `json:"account_id"`
+	AccountName  string          `json:"account_name"`
+	Currency     string          `json:"currency"`
+	CategoryID   *int            `json:"category_id"`
+	CategoryName string          `json:"category_name"`
+	CategoryKind string          `json:"category_kind"`
+	Amount       decimal.Decimal `json:"amount"`
+	Note         string          `json:"note"`
+	Tags         []string        `json:"tags"`
+}
+
+type entryResponse struct {
+	ID          uint           `json:"id"`
+	LedgerID    int            `json:"ledger_id"`
+	OccurredOn  string         `json:"occurred_on"`
+	Description string         `json:"description"`
+	Note        string         `json:"note"`
+	CreatedAt   string         `json:"created_at"`
+	Lines       []lineResponse `json:"lines"`
+}
+
+func (h Handler) create(c *gin.Context) {
+	var req entryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	occurredOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.OccurredOn), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "occurred_on must be YYYY-MM-DD"})
+		return
+	}
+
+	var txID uint
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := buildLines(tx, ledgerID, req.Lines)
+		if err != nil {
+			return err
+		}
+
+		txRecord := model.Transaction{
+			LedgerID:    ledgerID,
+			OccurredOn:  occurredOn,
+			Description: strings.TrimSpace(req.Description),
+			Note:        strings.TrimSpace(req.Note),
+		}
+		if err := tx.Create(&txRecord).Error; err != nil {
+			return err
+		}
+
+		for i := range lines {
+			lines[i].TransactionID = txRecord.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+
+		txID = txRecord.ID
+		return nil
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create journal entry"})
+		return
+	}
+
+	resp, err := loadEntry(h.db, txID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load journal entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h Handler) get(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var txRecord model.Transaction
+	if err := h.db.First(&txRecord, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "journal entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load journal entry"})
+		return
+	}
+
+	if !ledger.Check(c, h.db, txRecord.LedgerID, model.LedgerRoleViewer) {
+		return
+	}
+
+	resp, err := loadEntry(h.db, txRecord.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load journal entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// update 整体替换分录的日期、说明和全部分录行；账本不可变更。
+func (h Handler) update(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req entryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var txRecord model.Transaction
+	if err := h.db.First(&txRecord, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "journal entry not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load journal entry"})
+		return
+	}
+
+	if !ledger.Check(c, h.db, txRecord.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+	if req.LedgerID != nil && *req.LedgerID != txRecord.LedgerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id cannot be changed"})
+		return
+	}
+
+	occurredOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.OccurredOn), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "occurred_on must be YYYY-MM-DD"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureNotInvestment(tx, txRecord.ID); err != nil {
+			return err
+		}
+		if err := ensureNotTransfer(tx, txRecord.ID); err != nil {
+			return err
+		}
+
+		lines, err := buildLines(tx, txRecord.LedgerID, req.Lines)
+		if err != nil {
+			return err
+		}
+
+		txRecord.OccurredOn = occurredOn
+		txRecord.Description = strings.TrimSpace(req.Description)
+		txRecord.Note = strings.TrimSpace(req.Note)
+		if err := tx.Save(&txRecord).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&model.TransactionLine{}, "transaction_id = ?", txRecord.ID).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].TransactionID = txRecord.ID
+		}
+		return tx.Create(&lines).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update journal entry"})
+		return
+	}
+
+	resp, err := loadEntry(h.db, txRecord.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load journal entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// buildLines 校验分录行并检查平衡：金额先按账户币种的最小单位舍入，再按币种精确比较，全部行合计为 0；
+// 或者不带收入/支出分类的行合计为 0（收入/支出分类行由分类本身平衡，
+// 如工资单中的代扣税费、还贷中的利息）。
+func buildLines(tx *gorm.DB, ledgerID int, reqLines []lineRequest) ([]model.TransactionLine, error) {
+	accounts := map[uint]model.Account{}
+	categories := map[int]model.Category{}
+	total := map[string]decimal.Decimal{}
+	unbalanced := map[string]decimal.Decimal{}
+
+	lines := make([]model.TransactionLine, 0, len(reqLines))
+	for i, reqLine := range reqLines {
+		label := "line " + strconv.Itoa(i+1)
+
+		account, ok := accounts[reqLine.AccountID]
+		if !ok {
+			if err := tx.Where("id = ? AND ledger_id = ?", reqLine.AccountID, ledgerID).First(&account).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, newRequestError(label + ": account not found")
+				}
+				return nil, err
+			}
+			accounts[reqLine.AccountID] = account
+		}
+		if !account.IsActive {
+			return nil, newRequestError(label + ": account is inactive")
+		}
+
+		categorized := false
+		if reqLine.CategoryID != nil {
+			category, ok := categories[*reqLine.CategoryID]
+			if !ok {
+				if err := tx.Where("id = ? AND ledger_id = ?", *reqLine.CategoryID, ledgerID).First(&category).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return nil, newRequestError(label + ": category not found")
+					}
+					return nil, err
+				}
+				categories[*reqLine.CategoryID] = category
+			}
+			categorized = category.Kind == model.CategoryKindIncome || category.Kind == model.CategoryKindExpense
+		}
+
+		amount := money.Round(reqLine.Amount, account.Currency)
+		if amount.IsZero() {
+			return nil, newRequestError(label + ": amount cannot be 0")
+		}
+
+		currency := strings.ToUpper(account.Currency)
+		total[currency] = total[currency].Add(amount)
+		if !categorized {
+			unbalanced[currency] = unbalanced[currency].Add(amount)
+		}
+
+		lines = append(lines, model.TransactionLine{
+			LedgerID:   ledgerID,
+			AccountID:  reqLine.AccountID,
+			CategoryID: reqLine.CategoryID,
+			Amount:     amount,
+			Tags:       normalizeTags(reqLine.Tags),
+			Note:       strings.TrimSpace(reqLine.Note),
+		})
+	}
+
+	currencies := make([]string, 0, len(total))
+	for currency := range total {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		if total[currency].IsZero() || unbalanced[currency].IsZero() {
+			continue
+		}
+		return nil, newRequestError("entry does not balance in " + currency + ": lines without an income/expense category sum to " +
+			unbalanced[currency].String())
+	}
+
+	return lines, nil
+}
+
+// ensureNotInvestment 拒绝修改由投资买入/卖出/分红/批次转移生成的交易，避免批次与分录脱节。
+func ensureNotInvestment(tx *gorm.DB, transactionID uint) error {
+	owned, err := model.IsInvestmentTransaction(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	if owned {
+		return newRequestError("investment transactions must be edited through /api/investments")
+	}
+	return nil
+}
+
+// ensureNotTransfer 拒绝修改转账生成的交易，避免转账记录与分录脱节。
+func ensureNotTransfer(tx *gorm.DB, transactionID uint) error {
+	owned, err := model.IsTransferTransaction(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	if owned {
+		return newRequestError("transfer transactions must be edited through /api/transfers")
+	}
+	return nil
+}
+
+func loadEntry(db *gorm.DB, id uint) (entryResponse, error) {
+	var txRecord model.Transaction
+	if err := db.First(&txRecord, id).Error; err != nil {
+		return entryResponse{}, err
+	}
+
+	var lines []model.TransactionLine
+	if err := db.Where("transaction_id = ?", id).Order("id").Find(&lines).Error; err != nil {
+		return entryResponse{}, err
+	}
+
+	accountIDs := make([]uint, 0, len(lines))
+	categoryIDs := make([]int, 0, len(lines))
+	for _, line := range lines {
+		accountIDs = append(accountIDs, line.AccountID)
+		if line.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *line.CategoryID)
+		}
+	}
+
+	accounts := map[uint]model.Account{}
+	if len(accountIDs) > 0 {
+		var rows []model.Account
+		if err := db.Unscoped().Where("id IN ?", accountIDs).Find(&rows).Error; err != nil {
+			return entryResponse{}, err
+		}
+		for _, row := range rows {
+			accounts[row.ID] = row
+		}
+	}
+
+	categories := map[int]model.Category{}
+	if len(categoryIDs) > 0 {
+		var rows []model.Category
+		if err := db.Unscoped().Where("id IN ?", categoryIDs).Find(&rows).Error; err != nil {
+			return entryResponse{}, err
+		}
+		for _, row := range rows {
+			categories[row.ID] = row
+		}
+	}
+
+	resp := entryResponse{
+		ID:          txRecord.ID,
+		LedgerID:    txRecord.LedgerID,
+		OccurredOn:  txRecord.OccurredOn.Format("2006-01-02"),
+		Description: txRecord.Description,
+		Note:        txRecord.Note,
+		CreatedAt:   txRecord.CreatedAt.Format(time.RFC3339),
+		Lines:       make([]lineResponse, 0, len(lines)),
+	}
+	for _, line := range lines {
+		account := accounts[line.AccountID]
+		item := lineResponse{
+			ID:          line.ID,
+			AccountID:   line.AccountID,
+			AccountName: account.Name,
+			Currency:    account.Currency,
+			CategoryID:  line.CategoryID,
+			Amount:      line.Amount,
+			Note:        line.Note,
+			Tags:        line.Tags,
+		}
+		if line.CategoryID != nil {
+			category := categories[*line.CategoryID]
+			item.CategoryName = category.Name
+			item.CategoryKind = string(category.Kind)
+		}
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+		resp.Lines = append(resp.Lines, item)
+	}
+
+	return resp, nil
+}
+
+func normalizeTags(tags []string) model.StringArray {
+	if len(tags) == 0 {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	result := make(model.StringArray, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		result = append(result, tag)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func parseID(raw string) (uint, bool) {
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
+
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func newRequestError(message string) error {
+	return requestError{message: message}
+}
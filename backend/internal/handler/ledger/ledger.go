@@ -0,0 +1,452 @@
+package ledger
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/handler/auth"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Handler 封装账本相关的数据库实例，用于绑定到路由上。
+type Handler struct {
+	db *gorm.DB
+}
+
+// RegisterRoutes 将账本 CRUD 路由注册到 /api/ledgers 下。
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	h := Handler{db: db}
+
+	rg.POST("", h.create)       // 新建账本
+	rg.GET("", h.list)          // 列出账本
+	rg.GET("/:id", h.get)       // 获取单个账本
+	rg.PATCH("/:id", h.update)  // 更新账本（含归档）
+	rg.DELETE("/:id", h.delete) // 删除空账本
+
+	rg.GET("/:id/members", h.listMembers)              // 列出账本成员
+	rg.PUT("/:id/members/:user_id", h.upsertMember)    // 添加成员或修改角色
+	rg.DELETE("/:id/members/:user_id", h.deleteMember) // 移除成员
+}
+
+// createLedgerRequest 新建账本时的请求体。
+type createLedgerRequest struct {
+	Name         string `json:"name" binding:"required"` // 账本名称（必填）
+	Description  string `json:"description"`             // 描述
+	BaseCurrency string `json:"base_currency"`           // 本位币，缺省为 CNY
+	Timezone     string `json:"timezone"`                // 时区，缺省为 Asia/Shanghai
+}
+
+// updateLedgerRequest 更新账本时的请求体（全部字段可选）。
+type updateLedgerRequest struct {
+	Name                   *string `json:"name"`                      // 新名称
+	Description            *string `json:"description"`               // 新描述
+	BaseCurrency           *string `json:"base_currency"`             // 新本位币
+	Timezone               *string `json:"timezone"`                  // 新时区
+	IsArchived             *bool   `json:"is_archived"`               // 是否归档
+	RealizedGainCategoryID *int    `json:"realized_gain_category_id"` // 投资卖出已实现损益默认记入的收入分类
+	LongTermHoldingDays    *int    `json:"long_term_holding_days"`    // 资本利得报表的长期持有天数阈值
+}
+
+// create 处理创建账本：校验名称、币种与时区后写入数据库。
+func (h Handler) create(c *gin.Context) {
+	var req createLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	currency := "CNY"
+	if strings.TrimSpace(req.BaseCurrency) != "" {
+		normalized, ok := normalizeCurrency(req.BaseCurrency)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base_currency must be a 3-letter currency code"})
+			return
+		}
+		currency = normalized
+	}
+
+	timezone := "Asia/Shanghai"
+	if strings.TrimSpace(req.Timezone) != "" {
+		normalized, ok := normalizeTimezone(req.Timezone)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		timezone = normalized
+	}
+
+	if scope, ok := auth.APITokenScope(c); ok && (scope.ReadOnly || scope.LedgerID != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token cannot create ledgers"})
+		return
+	}
+
+	ledger := model.Ledger{
+		Name:                name,
+		Description:         strings.TrimSpace(req.Description),
+		BaseCurrency:        currency,
+		Timezone:            timezone,
+		LongTermHoldingDays: model.DefaultLongTermHoldingDays,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ledger).Error; err != nil {
+			return err
+		}
+		member := model.LedgerMember{LedgerID: ledger.ID, UserID: auth.UserID(c), Role: model.LedgerRoleOwner}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create ledger"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ledger)
+}
+
+// list 查询当前用户可访问的账本，默认不含已归档账本；include_archived=true 时全部返回。
+func (h Handler) list(c *gin.Context) {
+	query := h.db.
+		Where("id IN (?)", h.db.Model(&model.LedgerMember{}).Select("ledger_id").Where("user_id = ?", auth.UserID(c))).
+		Order("id")
+	if strings.TrimSpace(c.Query("include_archived")) != "true" {
+		query = query.Where("is_archived = ?", false)
+	}
+	if scope, ok := auth.APITokenScope(c); ok && scope.LedgerID != nil {
+		query = query.Where("id = ?", *scope.LedgerID)
+	}
+
+	var ledgers []model.Ledger
+	if err := query.Find(&ledgers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query ledgers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ledgers)
+}
+
+// get 按 id 查询单个账本；不存在时返回 404。
+func (h Handler) get(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var ledger model.Ledger
+	err := h.db.First(&ledger, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ledger not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query ledger"})
+		return
+	}
+
+	if !authorize(c, h.db, id, model.LedgerRoleViewer, true) {
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
+}
+
+// update 部分更新账本：只修改传入的字段，返回更新后的记录。
+func (h Handler) update(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req updateLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		updates["name"] = name
+	}
+
+	if req.Description != nil {
+		updates["description"] = strings.TrimSpace(*req.Description)
+	}
+
+	if req.BaseCurrency != nil {
+		currency, ok := normalizeCurrency(*req.BaseCurrency)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base_currency must be a 3-letter currency code"})
+			return
+		}
+		updates["base_currency"] = currency
+	}
+
+	if req.Timezone != nil {
+		timezone, ok := normalizeTimezone(*req.Timezone)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		updates["timezone"] = timezone
+	}
+
+	if req.IsArchived != nil {
+		updates["is_archived"] = *req.IsArchived
+	}
+
+	if req.LongTermHoldingDays != nil {
+		if *req.LongTermHoldingDays <= 0 || *req.LongTermHoldingDays > 3660 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "long_term_holding_days must be between 1 and 3660"})
+			return
+		}
+		updates["long_term_holding_days"] = *req.LongTermHoldingDays
+	}
+
+	if len(updates) == 0 && req.RealizedGainCategoryID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	if !authorize(c, h.db, id, model.LedgerRoleOwner, true) {
+		return
+	}
+
+	if req.RealizedGainCategoryID != nil {
+		var count int64
+		if err := h.db.Model(&model.Category{}).
+			Where("id = ? AND ledger_id = ? AND kind = ?", *req.RealizedGainCategoryID, id, model.CategoryKindIncome).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query categories"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "realized_gain_category_id must be an income category of this ledger"})
+			return
+		}
+		updates["realized_gain_category_id"] = *req.RealizedGainCategoryID
+	}
+
+	tx := h.db.Model(&model.Ledger{}).Where("id = ?", id).Updates(updates)
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update ledger"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ledger not found"})
+		return
+	}
+
+	var ledger model.Ledger
+	if err := h.db.First(&ledger, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
+}
+
+// delete 软删除账本：仅允许删除没有任何业务数据的账本，否则应改为归档。
+func (h Handler) delete(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if id == model.DefaultLedgerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "default ledger cannot be deleted"})
+		return
+	}
+
+	var ledger model.Ledger
+	err := h.db.First(&ledger, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ledger not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+
+	if !authorize(c, h.db, id, model.LedgerRoleOwner, true) {
+		return
+	}
+
+	inUse, err := hasLedgerData(h.db, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check ledger data"})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ledger is not empty, archive it instead"})
+		return
+	}
+
+	if err := h.db.Delete(&model.Ledger{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete ledger"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Resolve 解析请求体中的 ledger_id（缺省为默认账本），并确认当前用户在该账本至少拥有 role 角色。
+// 失败时已写入响应，调用方直接返回即可。
+func Resolve(c *gin.Context, db *gorm.DB, value *int, role model.LedgerRole) (int, bool) {
+	ledgerID := model.DefaultLedgerID
+	if value != nil {
+		if *value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
+			return 0, false
+		}
+		ledgerID = *value
+	}
+
+	if !Check(c, db, ledgerID, role) {
+		return 0, false
+	}
+	return ledgerID, true
+}
+
+// ResolveQuery 与 Resolve 相同，但从查询参数 ledger_id 读取。
+func ResolveQuery(c *gin.Context, db *gorm.DB, role model.LedgerRole) (int, bool) {
+	ledgerID := model.DefaultLedgerID
+	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
+			return 0, false
+		}
+		ledgerID = parsed
+	}
+
+	if !Check(c, db, ledgerID, role) {
+		return 0, false
+	}
+	return ledgerID, true
+}
+
+// Check 确认账本存在、当前用户至少拥有 role 角色；需要写权限时同时拒绝已归档账本。
+// 用于按 id 加载的记录：先查出记录，再用其 LedgerID 调用。
+func Check(c *gin.Context, db *gorm.DB, ledgerID int, role model.LedgerRole) bool {
+	return authorize(c, db, ledgerID, role, role == model.LedgerRoleViewer)
+}
+
+func authorize(c *gin.Context, db *gorm.DB, ledgerID int, role model.LedgerRole, allowArchived bool) bool {
+	var ledger model.Ledger
+	err := db.Select("id, is_archived").First(&ledger, ledgerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ledger not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return false
+	}
+
+	var member model.LedgerMember
+	err = db.Where("ledger_id = ? AND user_id = ?", ledgerID, auth.UserID(c)).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no access to ledger"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger membership"})
+		return false
+	}
+	if !member.Role.Allows(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "ledger role " + string(role) + " required"})
+		return false
+	}
+
+	if scope, ok := auth.APITokenScope(c); ok {
+		if scope.LedgerID != nil && *scope.LedgerID != ledgerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token is not valid for this ledger"})
+			return false
+		}
+		if scope.ReadOnly && role != model.LedgerRoleViewer {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token is read-only"})
+			return false
+		}
+	}
+
+	if !allowArchived && ledger.IsArchived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ledger is archived"})
+		return false
+	}
+	return true
+}
+
+// hasLedgerData 检查账本下是否仍有账户、分类、交易或证券。
+func hasLedgerData(db *gorm.DB, ledgerID int) (bool, error) {
+	for _, value := range []interface{}{
+		&model.Account{},
+		&model.Category{},
+		&model.Transaction{},
+		&model.Security{},
+	} {
+		var count int64
+		if err := db.Model(value).Where("ledger_id = ?", ledgerID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseID 将路径参数转换为 int，失败返回 false。
+func parseID(raw string) (int, bool) {
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// normalizeCurrency 将币种代码去空格、转大写，并检查是否为 3 位字母。
+func normalizeCurrency(input string) (string, bool) {
+	value := strings.ToUpper(strings.TrimSpace(input))
+	if len(value) != 3 {
+		return "", false
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// normalizeTimezone 校验时区名称可被加载（如 Asia/Shanghai、UTC）。
+func normalizeTimezone(input string) (string, bool) {
+	value := strings.TrimSpace(input)
+	if value == "" {
+		return "", false
+	}
+	if _, err := time.LoadLocation(value); err != nil {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,220 @@
+package ledger
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"finance-backend/internal/handler/auth"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// upsertMemberRequest 添加成员或修改角色时的请求体。
+type upsertMemberRequest struct {
+	Role model.LedgerRole `json:"role" binding:"required"` // owner | editor | viewer
+}
+
+// memberRow 成员列表返回的一行。
+type memberRow struct {
+	UserID    uint             `json:"user_id"`
+	Username  string           `json:"username"`
+	Role      model.LedgerRole `json:"role"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// listMembers 列出账本成员，账本 viewer 即可查看。
+func (h Handler) listMembers(c *gin.Context) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if !authorize(c, h.db, id, model.LedgerRoleViewer, true) {
+		return
+	}
+
+	rows := make([]memberRow, 0)
+	if err := h.db.Table("fin_ledger_members m").
+		Joins("JOIN fin_users u ON u.id = m.user_id AND u.deleted_at IS NULL").
+		Where("m.ledger_id = ?", id).
+		Select("m.user_id, u.username, m.role, m.created_at").
+		Order("m.user_id").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// upsertMember 由 owner 添加成员或修改成员角色；账本至少保留一个 owner。
+func (h Handler) upsertMember(c *gin.Context) {
+	if !auth.RequireSession(c) {
+		return
+	}
+
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	userID, ok := parseUserID(c.Param("user_id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	var req upsertMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of: owner, editor, viewer"})
+		return
+	}
+
+	if !authorize(c, h.db, id, model.LedgerRoleOwner, true) {
+		return
+	}
+
+	var member model.LedgerMember
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return newRequestError("user not found")
+			}
+			return err
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ledger_id = ? AND user_id = ?", id, userID).
+			First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			member = model.LedgerMember{LedgerID: id, UserID: userID, Role: req.Role}
+			return tx.Create(&member).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if member.Role == model.LedgerRoleOwner && req.Role != model.LedgerRoleOwner {
+			if err := ensureAnotherOwner(tx, id, userID); err != nil {
+				return err
+			}
+		}
+		member.Role = req.Role
+		return tx.Save(&member).Error
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// deleteMember 由 owner 移除成员；成员也可以移除自己（退出账本）。
+func (h Handler) deleteMember(c *gin.Context) {
+	if !auth.RequireSession(c) {
+		return
+	}
+
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	userID, ok := parseUserID(c.Param("user_id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	required := model.LedgerRoleOwner
+	if userID == auth.UserID(c) {
+		required = model.LedgerRoleViewer
+	}
+	if !authorize(c, h.db, id, required, true) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var member model.LedgerMember
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ledger_id = ? AND user_id = ?", id, userID).
+			First(&member).Error; err != nil {
+			return err
+		}
+		if member.Role == model.LedgerRoleOwner {
+			if err := ensureAnotherOwner(tx, id, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&member).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return
+	}
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete member"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ensureAnotherOwner 确认除 userID 以外账本仍有其他 owner。
+func ensureAnotherOwner(tx *gorm.DB, ledgerID int, userID uint) error {
+	var owners int64
+	if err := tx.Model(&model.LedgerMember{}).
+		Where("ledger_id = ? AND role = ? AND user_id <> ?", ledgerID, model.LedgerRoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return newRequestError("ledger must keep at least one owner")
+	}
+	return nil
+}
+
+// parseUserID 将路径参数转换为 uint，失败返回 false。
+func parseUserID(raw string) (uint, bool) {
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
+
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func newRequestError(message string) error {
+	return requestError{message: message}
+}
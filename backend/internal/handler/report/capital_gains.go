@@ -0,0 +1,433 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// capitalGainAdjustment 为处置上的一项税务调整，amount 加到损益上（正数表示不允许扣除的亏损）。
+type capitalGainAdjustment struct {
+	Code   string          `json:"code"`
+	Amount decimal.Decimal `json:"amount"`
+	LotID  *uint           `json:"lot_id"`
+	Note   string          `json:"note"`
+}
+
+// capitalGainDisposal 为一条批次处置（卖出匹配），金额以现金账户币种计；
+// gain = proceeds - cost - fees + adjustment，fees 为卖出手续费与税费按数量分摊到该批次的部分。
+type capitalGainDisposal struct {
+	SaleID       uint                    `json:"sale_id"`
+	AllocationID uint                    `json:"allocation_id"`
+	LotID        uint                    `json:"lot_id"`
+	SecurityID   uint                    `json:"security_id"`
+	Ticker       string                  `json:"ticker"`
+	Name         string                  `json:"name"`
+	AcquiredOn   string                  `json:"acquired_on"`
+	DisposedOn   string                  `json:"disposed_on"`
+	HoldingDays  int                     `json:"holding_days"`
+	Term         string                  `json:"term"`
+	Quantity     decimal.Decimal         `json:"quantity"`
+	Currency     string                  `json:"currency"`
+	Proceeds     decimal.Decimal         `json:"proceeds"`
+	Cost         decimal.Decimal         `json:"cost"`
+	Fees         decimal.Decimal         `json:"fees"`
+	Adjustment   decimal.Decimal         `json:"adjustment"`
+	Gain         decimal.Decimal         `json:"gain"`
+	ExchangeRate *decimal.Decimal        `json:"exchange_rate"`
+	RateMissing  bool                    `json:"rate_missing"`
+	Adjustments  []capitalGainAdjustment `json:"adjustments"`
+}
+
+// capitalGainTotals 为一组处置的本位币合计。
+type capitalGainTotals struct {
+	Count       int             `json:"count"`
+	Proceeds    decimal.Decimal `json:"proceeds"`
+	Cost        decimal.Decimal `json:"cost"`
+	Fees        decimal.Decimal `json:"fees"`
+	Adjustments decimal.Decimal `json:"adjustments"`
+	Gain        decimal.Decimal `json:"gain"`
+}
+
+func (t *capitalGainTotals) add(d capitalGainDisposal, rate fx.Rate) {
+	t.Count++
+	t.Proceeds = t.Proceeds.Add(rate.Apply(d.Proceeds))
+	t.Cost = t.Cost.Add(rate.Apply(d.Cost))
+	t.Fees = t.Fees.Add(rate.Apply(d.Fees))
+	t.Adjustments = t.Adjustments.Add(rate.Apply(d.Adjustment))
+	t.Gain = t.Gain.Add(rate.Apply(d.Gain))
+}
+
+type capitalGainsResponse struct {
+	LedgerID            int                   `json:"ledger_id"`
+	Year                int                   `json:"year"`
+	BaseCurrency        string                `json:"base_currency"`
+	LongTermHoldingDays int                   `json:"long_term_holding_days"`
+	Adjustments         []string              `json:"adjustments"`
+	ShortTerm           capitalGainTotals     `json:"short_term"`
+	LongTerm            capitalGainTotals     `json:"long_term"`
+	Totals              capitalGainTotals     `json:"totals"`
+	MissingRates        []string              `json:"missing_rates"`
+	Disposals           []capitalGainDisposal `json:"disposals"`
+}
+
+// gainAdjuster 为处置追加税务调整。传入的处置按卖出日期排序，gain 尚未计入任何调整；
+// 调整项追加到 Adjustments，由调用方汇总到 adjustment 与 gain。
+type gainAdjuster func(db *gorm.DB, ledgerID int, disposals []capitalGainDisposal) error
+
+// gainAdjusters 为 adjustments 查询参数可选用的调整规则。
+var gainAdjusters = map[string]gainAdjuster{
+	"wash_sale": washSaleAdjustments,
+}
+
+// washSaleWindowDays 为洗售规则中亏损卖出前后的回购窗口。
+const washSaleWindowDays = 30
+
+// capitalGains 按年度列出每条批次处置及其长短期分类，供报税使用；format=csv 时返回 CSV。
+// 持有天数超过账本 long_term_holding_days 为长期；adjustments 可启用 wash_sale 等调整规则。
+// 分类合计以本位币计，按卖出日汇率折算，缺少汇率的处置不计入合计。
+func (h Handler) capitalGains(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	year := time.Now().Year()
+	if value := strings.TrimSpace(c.Query("year")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1900 || parsed > 9999 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year must be a 4-digit year"})
+			return
+		}
+		year = parsed
+	}
+
+	var securityID uint
+	if value := strings.TrimSpace(c.Query("security_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security_id"})
+			return
+		}
+		securityID = uint(parsed)
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	adjustments := []string{}
+	if value := strings.TrimSpace(c.Query("adjustments")); value != "" {
+		seen := map[string]bool{}
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			if _, ok := gainAdjusters[name]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown adjustment: " + name})
+				return
+			}
+			seen[name] = true
+			adjustments = append(adjustments, name)
+		}
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+	threshold := ledgerRecord.LongTermHoldingDays
+
+	dateFrom := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	dateTo := time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+	rows, err := saleAllocations(h.db, ledgerID, &dateFrom, &dateTo, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query sales"})
+		return
+	}
+
+	disposals := capitalGainDisposals(rows, threshold)
+	for _, name := range adjustments {
+		if err := gainAdjusters[name](h.db, ledgerID, disposals); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply " + name + " adjustments"})
+			return
+		}
+	}
+
+	resp := capitalGainsResponse{
+		LedgerID:            ledgerID,
+		Year:                year,
+		BaseCurrency:        baseCurrency,
+		LongTermHoldingDays: threshold,
+		Adjustments:         adjustments,
+		Disposals:           disposals,
+	}
+	converters := map[string]*fx.Converter{}
+	missingRates := map[string]struct{}{}
+	for i := range disposals {
+		disposal := &disposals[i]
+		for _, adjustment := range disposal.Adjustments {
+			disposal.Adjustment = disposal.Adjustment.Add(adjustment.Amount)
+		}
+		disposal.Gain = disposal.Gain.Add(disposal.Adjustment)
+
+		converter, ok := converters[disposal.DisposedOn]
+		if !ok {
+			disposedOn, _ := time.ParseInLocation("2006-01-02", disposal.DisposedOn, time.Local)
+			converter = fx.NewConverter(h.db, ledgerID, disposedOn)
+			converters[disposal.DisposedOn] = converter
+		}
+		rate, found, err := converter.Rate(disposal.Currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found {
+			disposal.RateMissing = true
+			missingRates[disposal.Currency] = struct{}{}
+			continue
+		}
+		disposal.ExchangeRate = &rate.Value
+		if disposal.Term == "long" {
+			resp.LongTerm.add(*disposal, rate)
+		} else {
+			resp.ShortTerm.add(*disposal, rate)
+		}
+		resp.Totals.add(*disposal, rate)
+	}
+	resp.MissingRates = sortedKeys(missingRates)
+
+	if format == "csv" {
+		body, err := capitalGainsCSV(disposals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write csv"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="capital-gains-%d.csv"`, year))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", body)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// capitalGainDisposals 将卖出匹配明细转为处置，卖出的手续费与税费按数量分摊，
+// 最后一条处置承担舍入差额，使各处置 fees 之和等于卖出的费税合计。
+func capitalGainDisposals(rows []gainRow, threshold int) []capitalGainDisposal {
+	disposals := []capitalGainDisposal{}
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].SaleID == rows[start].SaleID {
+			end++
+		}
+
+		currency := strings.ToUpper(rows[start].Currency)
+		fees := rows[start].Fee.Add(rows[start].Tax)
+		remainingFees := fees
+		for i := start; i < end; i++ {
+			row := rows[i]
+			share := remainingFees
+			if i < end-1 && row.SaleQuantity.IsPositive() {
+				share = money.Round(fees.Mul(row.Quantity).Div(row.SaleQuantity), currency)
+			}
+			remainingFees = remainingFees.Sub(share)
+
+			days := holdingDays(row.AcquiredOn, row.OccurredOn)
+			term := "short"
+			if days > threshold {
+				term = "long"
+			}
+			proceeds := money.Round(row.Quantity.Mul(row.SalePrice), currency)
+			cost := money.Round(row.Quantity.Mul(row.CostPrice), currency)
+			disposals = append(disposals, capitalGainDisposal{
+				SaleID:       row.SaleID,
+				AllocationID: row.AllocationID,
+				LotID:        row.LotID,
+				SecurityID:   row.SecurityID,
+				Ticker:       row.Ticker,
+				Name:         row.SecurityName,
+				AcquiredOn:   row.AcquiredOn.Format("2006-01-02"),
+				DisposedOn:   row.OccurredOn.Format("2006-01-02"),
+				HoldingDays:  days,
+				Term:         term,
+				Quantity:     row.Quantity,
+				Currency:     currency,
+				Proceeds:     proceeds,
+				Cost:         cost,
+				Fees:         share,
+				Gain:         proceeds.Sub(cost).Sub(share),
+				Adjustments:  []capitalGainAdjustment{},
+			})
+		}
+		start = end
+	}
+	return disposals
+}
+
+type replacementLot struct {
+	ID         uint            `gorm:"column:id"`
+	SecurityID uint            `gorm:"column:security_id"`
+	Quantity   decimal.Decimal `gorm:"column:quantity"`
+	AcquiredOn time.Time       `gorm:"column:acquired_on"`
+}
+
+// washSaleAdjustments 对亏损处置应用洗售规则：卖出日前后 30 天内买入同一证券（买入或红利再投资生成的批次，
+// 不含该笔卖出自身匹配的批次）时，按回购数量占处置数量的比例将亏损记为不允许扣除（代码 W）。
+// 每个回购批次的数量只抵用一次，按卖出日期先后分配。调整只影响本报表，不改变回购批次的成本。
+func washSaleAdjustments(db *gorm.DB, ledgerID int, disposals []capitalGainDisposal) error {
+	var securityIDs []uint
+	seenSecurity := map[uint]bool{}
+	soldLots := map[uint]map[uint]bool{}
+	var first, last time.Time
+	for _, disposal := range disposals {
+		if soldLots[disposal.SaleID] == nil {
+			soldLots[disposal.SaleID] = map[uint]bool{}
+		}
+		soldLots[disposal.SaleID][disposal.LotID] = true
+		if !disposal.Gain.IsNegative() {
+			continue
+		}
+		if !seenSecurity[disposal.SecurityID] {
+			seenSecurity[disposal.SecurityID] = true
+			securityIDs = append(securityIDs, disposal.SecurityID)
+		}
+		disposedOn, _ := time.ParseInLocation("2006-01-02", disposal.DisposedOn, time.Local)
+		if first.IsZero() || disposedOn.Before(first) {
+			first = disposedOn
+		}
+		if disposedOn.After(last) {
+			last = disposedOn
+		}
+	}
+	if len(securityIDs) == 0 {
+		return nil
+	}
+
+	var lots []replacementLot
+	if err := db.Raw(`
+SELECT l.id, l.security_id, l.quantity, t.occurred_on AS acquired_on
+FROM fin_investment_lots l
+JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+WHERE l.deleted_at IS NULL AND l.ledger_id = ? AND l.parent_lot_id IS NULL AND l.security_id IN ?
+  AND t.occurred_on >= ? AND t.occurred_on <= ?
+ORDER BY t.occurred_on, l.id`,
+		ledgerID, securityIDs,
+		first.AddDate(0, 0, -washSaleWindowDays), last.AddDate(0, 0, washSaleWindowDays)).
+		Scan(&lots).Error; err != nil {
+		return err
+	}
+
+	available := map[uint]decimal.Decimal{}
+	for _, lot := range lots {
+		available[lot.ID] = lot.Quantity
+	}
+
+	for i := range disposals {
+		disposal := &disposals[i]
+		if !disposal.Gain.IsNegative() || !disposal.Quantity.IsPositive() {
+			continue
+		}
+		disposedOn, _ := time.ParseInLocation("2006-01-02", disposal.DisposedOn, time.Local)
+		windowStart := disposedOn.AddDate(0, 0, -washSaleWindowDays)
+		windowEnd := disposedOn.AddDate(0, 0, washSaleWindowDays)
+
+		unmatched := disposal.Quantity
+		loss := disposal.Gain.Neg()
+		for _, lot := range lots {
+			if !unmatched.IsPositive() {
+				break
+			}
+			if lot.SecurityID != disposal.SecurityID || soldLots[disposal.SaleID][lot.ID] {
+				continue
+			}
+			if lot.AcquiredOn.Before(windowStart) || lot.AcquiredOn.After(windowEnd) {
+				continue
+			}
+			quantity := decimal.Min(available[lot.ID], unmatched)
+			if !quantity.IsPositive() {
+				continue
+			}
+			available[lot.ID] = available[lot.ID].Sub(quantity)
+			unmatched = unmatched.Sub(quantity)
+
+			lotID := lot.ID
+			disposal.Adjustments = append(disposal.Adjustments, capitalGainAdjustment{
+				Code:   "W",
+				Amount: money.Round(loss.Mul(quantity).Div(disposal.Quantity), disposal.Currency),
+				LotID:  &lotID,
+				Note:   fmt.Sprintf("wash sale: %s replacement shares acquired on %s", quantity.String(), lot.AcquiredOn.Format("2006-01-02")),
+			})
+		}
+	}
+	return nil
+}
+
+// capitalGainsCSV 按处置逐行输出 CSV，金额为现金账户币种，adjustment_codes 为去重后的调整代码。
+func capitalGainsCSV(disposals []capitalGainDisposal) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{
+		"sale_id", "lot_id", "ticker", "name", "quantity", "acquired_on", "disposed_on", "holding_days", "term",
+		"currency", "proceeds", "cost", "fees", "adjustment_codes", "adjustment", "gain", "exchange_rate",
+	}); err != nil {
+		return nil, err
+	}
+	for _, disposal := range disposals {
+		var codes []string
+		seen := map[string]bool{}
+		for _, adjustment := range disposal.Adjustments {
+			if !seen[adjustment.Code] {
+				seen[adjustment.Code] = true
+				codes = append(codes, adjustment.Code)
+			}
+		}
+		sort.Strings(codes)
+		exchangeRate := ""
+		if disposal.ExchangeRate != nil {
+			exchangeRate = disposal.ExchangeRate.String()
+		}
+		if err := writer.Write([]string{
+			strconv.FormatUint(uint64(disposal.SaleID), 10),
+			strconv.FormatUint(uint64(disposal.LotID), 10),
+			disposal.Ticker,
+			disposal.Name,
+			disposal.Quantity.String(),
+			disposal.AcquiredOn,
+			disposal.DisposedOn,
+			strconv.Itoa(disposal.HoldingDays),
+			disposal.Term,
+			disposal.Currency,
+			disposal.Proceeds.String(),
+			disposal.Cost.String(),
+			disposal.Fees.String(),
+			strings.Join(codes, ","),
+			disposal.Adjustment.String(),
+			disposal.Gain.String(),
+			exchangeRate,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
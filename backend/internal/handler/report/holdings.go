@@ -0,0 +1,509 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// holdingPosition 为某投资账户下某证券的持仓，金额以投资账户币种计；
+// 收盘价为证券币种，币种不同时市值按 as_of 汇率折算。缺少价格或汇率时
+// 市值、浮动盈亏与权重为 null。
+type holdingPosition struct {
+	SecurityID            uint             `json:"security_id"`
+	Ticker                string           `json:"ticker"`
+	Name                  string           `json:"name"`
+	Quantity              decimal.Decimal  `json:"quantity"`
+	AverageCost           decimal.Decimal  `json:"average_cost"`
+	TotalCost             decimal.Decimal  `json:"total_cost"`
+	ClosePrice            *decimal.Decimal `json:"close_price"`
+	PriceDate             *string          `json:"price_date"`
+	PriceCurrency         string           `json:"price_currency"`
+	MarketValue           *decimal.Decimal `json:"market_value"`
+	UnrealizedGain        *decimal.Decimal `json:"unrealized_gain"`
+	UnrealizedGainPercent *decimal.Decimal `json:"unrealized_gain_percent"`
+	Weight                *decimal.Decimal `json:"weight"`
+	PriceMissing          bool             `json:"price_missing"`
+	RateMissing           bool             `json:"rate_missing"`
+}
+
+// holdingSummary 汇总一组持仓。投资账户汇总以账户币种计，顶层合计以本位币计；
+// 均只包含已估值的持仓。dividends 只出现在顶层合计中，为截至 as_of 已发放的
+// 税前分红/利息（按发放日汇率折算本位币），含已清仓或未估值的证券。
+type holdingSummary struct {
+	TotalCost             decimal.Decimal  `json:"total_cost"`
+	MarketValue           decimal.Decimal  `json:"market_value"`
+	UnrealizedGain        decimal.Decimal  `json:"unrealized_gain"`
+	UnrealizedGainPercent *decimal.Decimal `json:"unrealized_gain_percent"`
+	Dividends             *decimal.Decimal `json:"dividends,omitempty"`
+	Weight                *decimal.Decimal `json:"weight,omitempty"`
+}
+
+type holdingAccount struct {
+	AccountID   uint              `json:"account_id"`
+	AccountName string            `json:"account_name"`
+	Currency    string            `json:"currency"`
+	Positions   []holdingPosition `json:"positions"`
+	holdingSummary
+}
+
+// holdingSecurity 以本位币汇总某证券在各账户的全部持仓。任一持仓缺少收盘价或汇率时
+// 市值、浮动盈亏与权重为 null；某账户币种缺少本位币汇率时总成本也为 null。
+type holdingSecurity struct {
+	SecurityID            uint             `json:"security_id"`
+	Ticker                string           `json:"ticker"`
+	Name                  string           `json:"name"`
+	Quantity              decimal.Decimal  `json:"quantity"`
+	TotalCost             *decimal.Decimal `json:"total_cost"`
+	MarketValue           *decimal.Decimal `json:"market_value"`
+	UnrealizedGain        *decimal.Decimal `json:"unrealized_gain"`
+	UnrealizedGainPercent *decimal.Decimal `json:"unrealized_gain_percent"`
+	Dividends             decimal.Decimal  `json:"dividends"`
+	Weight                *decimal.Decimal `json:"weight"`
+}
+
+type holdingsResponse struct {
+	LedgerID      int               `json:"ledger_id"`
+	AsOf          string            `json:"as_of"`
+	BaseCurrency  string            `json:"base_currency"`
+	Totals        holdingSummary    `json:"totals"`
+	MissingPrices []string          `json:"missing_prices"`
+	MissingRates  []string          `json:"missing_rates"`
+	Accounts      []holdingAccount  `json:"accounts"`
+	Securities    []holdingSecurity `json:"securities"`
+}
+
+type openPositionRow struct {
+	AccountID        uint            `gorm:"column:account_id"`
+	AccountName      string          `gorm:"column:account_name"`
+	AccountCurrency  string          `gorm:"column:account_currency"`
+	SecurityID       uint            `gorm:"column:security_id"`
+	Ticker           string          `gorm:"column:ticker"`
+	SecurityName     string          `gorm:"column:security_name"`
+	SecurityCurrency string          `gorm:"column:security_currency"`
+	Quantity         decimal.Decimal `gorm:"column:quantity"`
+	Cost             decimal.Decimal `gorm:"column:cost"`
+}
+
+type priceRow struct {
+	SecurityID uint            `gorm:"column:security_id"`
+	PriceAt    time.Time       `gorm:"column:price_at"`
+	ClosePrice decimal.Decimal `gorm:"column:close_price"`
+}
+
+// holdings 按投资账户与证券汇总 as_of 时点未平仓批次，结合最近收盘价给出市值与浮动盈亏。
+func (h Handler) holdings(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	asOf := time.Now()
+	if value := strings.TrimSpace(c.Query("as_of")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be YYYY-MM-DD"})
+			return
+		}
+		asOf = parsed
+	}
+
+	var accountID uint
+	if value := strings.TrimSpace(c.Query("account_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		accountID = uint(parsed)
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+	converter := fx.NewConverter(h.db, ledgerID, asOf)
+
+	rows, err := openPositions(h.db, ledgerID, asOf, accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query holdings"})
+		return
+	}
+	prices, err := latestPrices(h.db, ledgerID, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query prices"})
+		return
+	}
+
+	type valued struct {
+		accountIndex  int
+		positionIndex int
+		baseCost      decimal.Decimal
+		baseValue     decimal.Decimal
+	}
+
+	// securityTotal 累计证券汇总；有持仓未能估值或换算本位币时，对应字段为 null。
+	type securityTotal struct {
+		security    holdingSecurity
+		cost, value decimal.Decimal
+		costMissing bool
+		unvalued    bool
+	}
+
+	var (
+		accounts      []holdingAccount
+		accountIndex  = map[uint]int{}
+		valuedRows    []valued
+		securityRows  []*securityTotal
+		securityIndex = map[uint]*securityTotal{}
+		missingPrices = map[string]struct{}{}
+		missingRates  = map[string]struct{}{}
+	)
+
+	for _, row := range rows {
+		idx, ok := accountIndex[row.AccountID]
+		if !ok {
+			idx = len(accounts)
+			accountIndex[row.AccountID] = idx
+			accounts = append(accounts, holdingAccount{
+				AccountID:   row.AccountID,
+				AccountName: row.AccountName,
+				Currency:    strings.ToUpper(row.AccountCurrency),
+				Positions:   []holdingPosition{},
+			})
+		}
+		account := &accounts[idx]
+
+		totalCost := money.Round(row.Cost, account.Currency)
+		position := holdingPosition{
+			SecurityID:    row.SecurityID,
+			Ticker:        row.Ticker,
+			Name:          row.SecurityName,
+			Quantity:      row.Quantity,
+			AverageCost:   totalCost.DivRound(row.Quantity, money.PriceScale),
+			TotalCost:     totalCost,
+			PriceCurrency: strings.ToUpper(row.SecurityCurrency),
+		}
+
+		toBase, baseFound, err := converter.Rate(account.Currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		total, ok := securityIndex[row.SecurityID]
+		if !ok {
+			total = &securityTotal{security: holdingSecurity{
+				SecurityID: row.SecurityID,
+				Ticker:     row.Ticker,
+				Name:       row.SecurityName,
+			}}
+			securityIndex[row.SecurityID] = total
+			securityRows = append(securityRows, total)
+		}
+		total.security.Quantity = total.security.Quantity.Add(row.Quantity)
+		if baseFound {
+			total.cost = total.cost.Add(toBase.Apply(totalCost))
+		} else {
+			total.costMissing = true
+			missingRates[account.Currency] = struct{}{}
+		}
+
+		price, found := prices[row.SecurityID]
+		if !found {
+			position.PriceMissing = true
+			missingPrices[row.Ticker] = struct{}{}
+			account.Positions = append(account.Positions, position)
+			total.unvalued = true
+			continue
+		}
+		priceDate := price.PriceAt.Format("2006-01-02")
+		position.ClosePrice = &price.ClosePrice
+		position.PriceDate = &priceDate
+
+		rate, found, err := converter.Rate(position.PriceCurrency, account.Currency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found || !baseFound {
+			position.RateMissing = true
+			if !found {
+				missingRates[position.PriceCurrency] = struct{}{}
+			}
+			if !baseFound {
+				missingRates[account.Currency] = struct{}{}
+			}
+			account.Positions = append(account.Positions, position)
+			total.unvalued = true
+			continue
+		}
+
+		marketValue := rate.Apply(row.Quantity.Mul(price.ClosePrice))
+		gain := marketValue.Sub(totalCost)
+		position.MarketValue = &marketValue
+		position.UnrealizedGain = &gain
+		position.UnrealizedGainPercent = percentOf(gain, totalCost)
+
+		account.Positions = append(account.Positions, position)
+		total.value = total.value.Add(toBase.Apply(marketValue))
+		valuedRows = append(valuedRows, valued{
+			accountIndex:  idx,
+			positionIndex: len(account.Positions) - 1,
+			baseCost:      toBase.Apply(totalCost),
+			baseValue:     toBase.Apply(marketValue),
+		})
+	}
+
+	// 权重按本位币市值占已估值组合总市值的比例计算。
+	var totals holdingSummary
+	for _, item := range valuedRows {
+		totals.TotalCost = totals.TotalCost.Add(item.baseCost)
+		totals.MarketValue = totals.MarketValue.Add(item.baseValue)
+	}
+	totals.UnrealizedGain = totals.MarketValue.Sub(totals.TotalCost)
+	totals.UnrealizedGainPercent = percentOf(totals.UnrealizedGain, totals.TotalCost)
+
+	accountBase := map[int]decimal.Decimal{}
+	for _, item := range valuedRows {
+		account := &accounts[item.accountIndex]
+		position := &account.Positions[item.positionIndex]
+		position.Weight = weightOf(item.baseValue, totals.MarketValue)
+
+		account.TotalCost = account.TotalCost.Add(position.TotalCost)
+		account.MarketValue = account.MarketValue.Add(*position.MarketValue)
+		accountBase[item.accountIndex] = accountBase[item.accountIndex].Add(item.baseValue)
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+		account.UnrealizedGain = account.MarketValue.Sub(account.TotalCost)
+		account.UnrealizedGainPercent = percentOf(account.UnrealizedGain, account.TotalCost)
+		account.Weight = weightOf(accountBase[i], totals.MarketValue)
+	}
+	dividends, err := dividendsBySecurity(h.db, ledgerID, asOf, baseCurrency, missingRates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query dividends"})
+		return
+	}
+	securities := make([]holdingSecurity, 0, len(securityRows))
+	for _, total := range securityRows {
+		security := total.security
+		if !total.costMissing {
+			cost := total.cost
+			security.TotalCost = &cost
+		}
+		if !total.costMissing && !total.unvalued {
+			value := total.value
+			gain := value.Sub(total.cost)
+			security.MarketValue = &value
+			security.UnrealizedGain = &gain
+			security.UnrealizedGainPercent = percentOf(gain, total.cost)
+			security.Weight = weightOf(value, totals.MarketValue)
+		}
+		security.Dividends = dividends[security.SecurityID]
+		securities = append(securities, security)
+	}
+	sort.Slice(securities, func(i, j int) bool {
+		left, right := securities[i].MarketValue, securities[j].MarketValue
+		if (left == nil) != (right == nil) {
+			return left != nil
+		}
+		if left != nil && !left.Equal(*right) {
+			return left.GreaterThan(*right)
+		}
+		return securities[i].Ticker < securities[j].Ticker
+	})
+
+	// 分红合计包含已清仓或未估值的证券；按账户筛选时只计该账户持有过的证券。
+	heldSecurities, err := heldSecurityIDs(h.db, ledgerID, asOf, accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query holdings"})
+		return
+	}
+	totalDividends := decimal.Zero
+	for securityID, amount := range dividends {
+		if heldSecurities == nil || heldSecurities[securityID] {
+			totalDividends = totalDividends.Add(amount)
+		}
+	}
+	totals.Dividends = &totalDividends
+
+	if accounts == nil {
+		accounts = []holdingAccount{}
+	}
+
+	c.JSON(http.StatusOK, holdingsResponse{
+		LedgerID:      ledgerID,
+		AsOf:          asOf.Format("2006-01-02"),
+		BaseCurrency:  baseCurrency,
+		Totals:        totals,
+		MissingPrices: sortedKeys(missingPrices),
+		MissingRates:  sortedKeys(missingRates),
+		Accounts:      accounts,
+		Securities:    securities,
+	})
+}
+
+// openPositions 返回 as_of 时点各投资账户、各证券的剩余数量与剩余成本（按批次成本价计）。
+// 只计入发生日不晚于 as_of 的买入与卖出；被公司行动关闭的批次只计入生效日之前。accountID 为 0 时不限账户。
+func openPositions(db *gorm.DB, ledgerID int, asOf time.Time, accountID uint) ([]openPositionRow, error) {
+	query := `
+SELECT
+  l.account_id,
+  acc.name AS account_name,
+  acc.currency AS account_currency,
+  l.security_id,
+  s.ticker,
+  s.name AS security_name,
+  s.currency AS security_currency,
+  SUM(l.quantity - COALESCE(alloc.quantity, 0)) AS quantity,
+  SUM((l.quantity - COALESCE(alloc.quantity, 0)) * l.price) AS cost
+FROM fin_investment_lots l
+JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+JOIN fin_accounts acc ON acc.id = l.account_id
+JOIN fin_securities s ON s.id = l.security_id
+LEFT JOIN (
+  SELECT a.buy_lot_id, SUM(a.quantity) AS quantity
+  FROM fin_investment_lot_allocations a
+  JOIN fin_investment_sales sale ON sale.id = a.sale_id AND sale.deleted_at IS NULL
+  JOIN fin_transaction_lines sl ON sl.id = sale.transaction_line_id AND sl.deleted_at IS NULL
+  JOIN fin_transactions st ON st.id = sl.transaction_id AND st.deleted_at IS NULL
+  WHERE a.deleted_at IS NULL AND a.ledger_id = ? AND st.occurred_on <= ?
+  GROUP BY a.buy_lot_id
+) alloc ON alloc.buy_lot_id = l.id
+WHERE l.deleted_at IS NULL AND l.ledger_id = ?
+  AND COALESCE(l.opened_on, t.occurred_on) <= ? AND (l.closed_on IS NULL OR l.closed_on > ?)`
+
+	args := []interface{}{ledgerID, asOf, ledgerID, asOf, asOf}
+	if accountID != 0 {
+		query += " AND l.account_id = ?"
+		args = append(args, accountID)
+	}
+	query += `
+GROUP BY l.account_id, acc.name, acc.currency, l.security_id, s.ticker, s.name, s.currency
+HAVING SUM(l.quantity - COALESCE(alloc.quantity, 0)) > 0
+ORDER BY l.account_id, s.ticker`
+
+	var rows []openPositionRow
+	err := db.Raw(query, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// dividendsBySecurity 返回各证券截至 as_of 已发放的税前收益（本位币，按发放日汇率折算）；
+// 缺少汇率的记录不计入，币种记入 missingRates。
+func dividendsBySecurity(db *gorm.DB, ledgerID int, asOf time.Time, baseCurrency string, missingRates map[string]struct{}) (map[uint]decimal.Decimal, error) {
+	rows, err := dividendRows(db, ledgerID, nil, &asOf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	converters := map[string]*fx.Converter{}
+	result := make(map[uint]decimal.Decimal)
+	for _, row := range rows {
+		payDate := row.PayDate.Format("2006-01-02")
+		converter, ok := converters[payDate]
+		if !ok {
+			converter = fx.NewConverter(db, ledgerID, row.PayDate)
+			converters[payDate] = converter
+		}
+		currency := strings.ToUpper(row.Currency)
+		rate, found, err := converter.Rate(currency, baseCurrency)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missingRates[currency] = struct{}{}
+			continue
+		}
+		result[row.SecurityID] = result[row.SecurityID].Add(rate.Apply(row.GrossAmount))
+	}
+	return result, nil
+}
+
+// heldSecurityIDs 返回该投资账户在 as_of 及之前开立过批次的证券；accountID 为 0 时返回 nil，表示不限。
+func heldSecurityIDs(db *gorm.DB, ledgerID int, asOf time.Time, accountID uint) (map[uint]bool, error) {
+	if accountID == 0 {
+		return nil, nil
+	}
+	var ids []uint
+	if err := db.Table("fin_investment_lots l").
+		Joins("JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.ledger_id = ? AND l.account_id = ? AND COALESCE(l.opened_on, t.occurred_on) <= ?",
+			ledgerID, accountID, asOf).
+		Distinct().
+		Pluck("l.security_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// latestPrices 返回每个证券在 as_of 当日或之前最近一次收盘价。
+func latestPrices(db *gorm.DB, ledgerID int, asOf time.Time) (map[uint]priceRow, error) {
+	query := `
+SELECT p.security_id, p.price_at, p.close_price
+FROM fin_security_prices p
+JOIN (
+  SELECT security_id, MAX(price_at) AS price_at
+  FROM fin_security_prices
+  WHERE ledger_id = ? AND price_at <= ? AND deleted_at IS NULL
+  GROUP BY security_id
+) latest ON latest.security_id = p.security_id AND latest.price_at = p.price_at
+WHERE p.ledger_id = ? AND p.deleted_at IS NULL`
+
+	var rows []priceRow
+	if err := db.Raw(query, ledgerID, asOf, ledgerID).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	prices := make(map[uint]priceRow, len(rows))
+	for _, row := range rows {
+		prices[row.SecurityID] = row
+	}
+	return prices, nil
+}
+
+// percentOf 返回 part / whole 的百分比（保留 2 位小数），whole 为 0 时返回 nil。
+func percentOf(part, whole decimal.Decimal) *decimal.Decimal {
+	if whole.IsZero() {
+		return nil
+	}
+	value := part.Mul(decimal.NewFromInt(100)).DivRound(whole, 2)
+	return &value
+}
+
+// weightOf 返回 part 占 whole 的比例（保留 4 位小数），whole 为 0 时返回 nil。
+func weightOf(part, whole decimal.Decimal) *decimal.Decimal {
+	if whole.IsZero() {
+		return nil
+	}
+	value := part.DivRound(whole, 4)
+	return &value
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
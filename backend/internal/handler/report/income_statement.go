@@ -0,0 +1,351 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// incomeStatementCategory 为分类树上的一个节点，金额含全部子分类；
+// amounts 与响应的 periods 一一对应，支出以正数表示。
+type incomeStatementCategory struct {
+	ID            int                        `json:"id"`
+	Name          string                     `json:"name"`
+	ParentID      *int                       `json:"parent_id"`
+	Amounts       []decimal.Decimal          `json:"amounts"`
+	Total         decimal.Decimal            `json:"total"`
+	PreviousTotal decimal.Decimal            `json:"previous_total"`
+	Change        decimal.Decimal            `json:"change"`
+	ChangePercent *decimal.Decimal           `json:"change_percent"`
+	Children      []*incomeStatementCategory `json:"children"`
+
+	kind   model.CategoryKind
+	active bool
+	root   bool
+}
+
+type incomeStatementSection struct {
+	Amounts       []decimal.Decimal          `json:"amounts"`
+	Total         decimal.Decimal            `json:"total"`
+	PreviousTotal decimal.Decimal            `json:"previous_total"`
+	Change        decimal.Decimal            `json:"change"`
+	ChangePercent *decimal.Decimal           `json:"change_percent"`
+	Categories    []*incomeStatementCategory `json:"categories"`
+}
+
+// incomeStatementSummary 中 net = income - expense，savings_rate 为 net 占收入的百分比，收入不为正时为 null。
+type incomeStatementSummary struct {
+	Income      decimal.Decimal  `json:"income"`
+	Expense     decimal.Decimal  `json:"expense"`
+	Net         decimal.Decimal  `json:"net"`
+	SavingsRate *decimal.Decimal `json:"savings_rate"`
+}
+
+func newIncomeStatementSummary(income, expense decimal.Decimal) incomeStatementSummary {
+	summary := incomeStatementSummary{Income: income, Expense: expense, Net: income.Sub(expense)}
+	if income.IsPositive() {
+		summary.SavingsRate = percentOf(summary.Net, income)
+	}
+	return summary
+}
+
+type incomeStatementPeriod struct {
+	Period string `json:"period"`
+	incomeStatementSummary
+}
+
+type incomeStatementPrevious struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	incomeStatementSummary
+}
+
+type incomeStatementResponse struct {
+	LedgerID     int                     `json:"ledger_id"`
+	From         string                  `json:"from"`
+	To           string                  `json:"to"`
+	Granularity  string                  `json:"granularity"`
+	BaseCurrency string                  `json:"base_currency"`
+	Periods      []string                `json:"periods"`
+	Income       incomeStatementSection  `json:"income"`
+	Expense      incomeStatementSection  `json:"expense"`
+	Totals       incomeStatementSummary  `json:"totals"`
+	PeriodTotals []incomeStatementPeriod `json:"period_totals"`
+	Previous     incomeStatementPrevious `json:"previous"`
+	MissingRates []string                `json:"missing_rates"`
+}
+
+type categoryAmountRow struct {
+	CategoryID int             `gorm:"column:category_id"`
+	Currency   string          `gorm:"column:currency"`
+	OccurredOn time.Time       `gorm:"column:occurred_on"`
+	Amount     decimal.Decimal `gorm:"column:amount"`
+}
+
+// incomeStatement 按分类汇总区间内的收入与支出分录，子分类金额逐级汇入父分类，
+// 按 granularity 分期列示，并与紧邻的上一同长区间比较。金额以本位币计，按交易日汇率折算，
+// 缺少汇率的分录不计入。
+func (h Handler) incomeStatement(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := strings.TrimSpace(c.Query("to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+	from := time.Date(to.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+	if value := strings.TrimSpace(c.Query("from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	granularity := strings.ToLower(strings.TrimSpace(c.DefaultQuery("granularity", "month")))
+	if granularity != "month" && granularity != "quarter" && granularity != "year" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be month, quarter or year"})
+		return
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+
+	periods := statementPeriods(from, to, granularity)
+	periodIndex := make(map[string]int, len(periods))
+	for i, period := range periods {
+		periodIndex[period] = i
+	}
+	previousFrom, previousTo := previousRange(from, to)
+
+	// 已删除的分类仍可能被分录引用，一并载入以免金额丢失。
+	var categories []model.Category
+	if err := h.db.Unscoped().
+		Where("ledger_id = ? AND kind IN ?", ledgerID, []model.CategoryKind{model.CategoryKindIncome, model.CategoryKindExpense}).
+		Order("id").
+		Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query categories"})
+		return
+	}
+	nodes := make(map[int]*incomeStatementCategory, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &incomeStatementCategory{
+			ID:       category.ID,
+			Name:     category.Name,
+			ParentID: category.ParentID,
+			Amounts:  make([]decimal.Decimal, len(periods)),
+			Children: []*incomeStatementCategory{},
+			kind:     category.Kind,
+		}
+	}
+	// 父分类不存在、收支类型不同或父子关系成环时，该分类作为顶层分类。
+	parentOf := func(node *incomeStatementCategory) *incomeStatementCategory {
+		if node.ParentID == nil || node.root {
+			return nil
+		}
+		parent, ok := nodes[*node.ParentID]
+		if !ok || parent.kind != node.kind {
+			return nil
+		}
+		return parent
+	}
+	for _, category := range categories {
+		visited := map[int]bool{}
+		for node := nodes[category.ID]; node != nil; node = parentOf(node) {
+			visited[node.ID] = true
+			if parent := parentOf(node); parent != nil && visited[parent.ID] {
+				node.root = true
+			}
+		}
+	}
+
+	rows, err := categoryAmounts(h.db, ledgerID, previousFrom, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transactions"})
+		return
+	}
+
+	converters := map[string]*fx.Converter{}
+	missingRates := map[string]struct{}{}
+	for _, row := range rows {
+		node, ok := nodes[row.CategoryID]
+		if !ok {
+			continue
+		}
+		date := row.OccurredOn.Format("2006-01-02")
+		converter, ok := converters[date]
+		if !ok {
+			converter = fx.NewConverter(h.db, ledgerID, row.OccurredOn)
+			converters[date] = converter
+		}
+		currency := strings.ToUpper(row.Currency)
+		rate, found, err := converter.Rate(currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found {
+			missingRates[currency] = struct{}{}
+			continue
+		}
+		amount := rate.Apply(row.Amount)
+		if node.kind == model.CategoryKindExpense {
+			amount = amount.Neg()
+		}
+
+		previous := row.OccurredOn.Before(from)
+		idx := periodIndex[periodKey(date, granularity)]
+		for node != nil {
+			node.active = true
+			if previous {
+				node.PreviousTotal = node.PreviousTotal.Add(amount)
+			} else {
+				node.Amounts[idx] = node.Amounts[idx].Add(amount)
+				node.Total = node.Total.Add(amount)
+			}
+			node = parentOf(node)
+		}
+	}
+
+	income := incomeStatementSection{Amounts: make([]decimal.Decimal, len(periods)), Categories: []*incomeStatementCategory{}}
+	expense := incomeStatementSection{Amounts: make([]decimal.Decimal, len(periods)), Categories: []*incomeStatementCategory{}}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if !node.active {
+			continue
+		}
+		node.Change = node.Total.Sub(node.PreviousTotal)
+		node.ChangePercent = percentOf(node.Change, node.PreviousTotal.Abs())
+		if parent := parentOf(node); parent != nil {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		section := &income
+		if node.kind == model.CategoryKindExpense {
+			section = &expense
+		}
+		section.Categories = append(section.Categories, node)
+		for i, amount := range node.Amounts {
+			section.Amounts[i] = section.Amounts[i].Add(amount)
+		}
+		section.Total = section.Total.Add(node.Total)
+		section.PreviousTotal = section.PreviousTotal.Add(node.PreviousTotal)
+	}
+	for _, section := range []*incomeStatementSection{&income, &expense} {
+		section.Change = section.Total.Sub(section.PreviousTotal)
+		section.ChangePercent = percentOf(section.Change, section.PreviousTotal.Abs())
+		sortStatementCategories(section.Categories)
+	}
+
+	periodTotals := make([]incomeStatementPeriod, len(periods))
+	for i, period := range periods {
+		periodTotals[i] = incomeStatementPeriod{
+			Period:                 period,
+			incomeStatementSummary: newIncomeStatementSummary(income.Amounts[i], expense.Amounts[i]),
+		}
+	}
+
+	c.JSON(http.StatusOK, incomeStatementResponse{
+		LedgerID:     ledgerID,
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Granularity:  granularity,
+		BaseCurrency: baseCurrency,
+		Periods:      periods,
+		Income:       income,
+		Expense:      expense,
+		Totals:       newIncomeStatementSummary(income.Total, expense.Total),
+		PeriodTotals: periodTotals,
+		Previous: incomeStatementPrevious{
+			From:                   previousFrom.Format("2006-01-02"),
+			To:                     previousTo.Format("2006-01-02"),
+			incomeStatementSummary: newIncomeStatementSummary(income.PreviousTotal, expense.PreviousTotal),
+		},
+		MissingRates: sortedKeys(missingRates),
+	})
+}
+
+// categoryAmounts 返回区间内收入与支出分类的分录金额，按分类、账户币种与交易日汇总。
+func categoryAmounts(db *gorm.DB, ledgerID int, from, to time.Time) ([]categoryAmountRow, error) {
+	var rows []categoryAmountRow
+	err := db.Raw(`
+SELECT tl.category_id, acc.currency, t.occurred_on, SUM(tl.amount) AS amount
+FROM fin_transaction_lines tl
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+JOIN fin_accounts acc ON acc.id = tl.account_id
+JOIN fin_categories cat ON cat.id = tl.category_id
+WHERE tl.ledger_id = ? AND tl.deleted_at IS NULL AND cat.kind IN ?
+  AND t.occurred_on >= ? AND t.occurred_on <= ?
+GROUP BY tl.category_id, acc.currency, t.occurred_on
+ORDER BY t.occurred_on`,
+		ledgerID, []model.CategoryKind{model.CategoryKindIncome, model.CategoryKindExpense}, from, to).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// statementPeriods 按月遍历 from 至 to，返回各期间键（格式同 periodKey）。
+func statementPeriods(from, to time.Time, granularity string) []string {
+	var periods []string
+	seen := map[string]bool{}
+	last := periodKey(to.Format("2006-01-02"), granularity)
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.Local); ; month = month.AddDate(0, 1, 0) {
+		key := periodKey(month.Format("2006-01-02"), granularity)
+		if !seen[key] {
+			seen[key] = true
+			periods = append(periods, key)
+		}
+		if key == last {
+			return periods
+		}
+	}
+}
+
+// previousRange 返回紧邻 from 之前、与 [from, to] 等长的区间；区间恰为整月时按月数平移，否则按天数。
+func previousRange(from, to time.Time) (time.Time, time.Time) {
+	previousTo := from.AddDate(0, 0, -1)
+	if from.Day() == 1 && to.AddDate(0, 0, 1).Day() == 1 {
+		months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+		return from.AddDate(0, -months, 0), previousTo
+	}
+	days := int(to.Sub(from).Hours()/24+0.5) + 1
+	return from.AddDate(0, 0, -days), previousTo
+}
+
+// sortStatementCategories 按金额从大到小递归排序，金额相同时按 id。
+func sortStatementCategories(nodes []*incomeStatementCategory) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if !nodes[i].Total.Equal(nodes[j].Total) {
+			return nodes[i].Total.GreaterThan(nodes[j].Total)
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	for _, node := range nodes {
+		sortStatementCategories(node.Children)
+	}
+}
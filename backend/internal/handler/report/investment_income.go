@@ -0,0 +1,313 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// incomeAmounts 为一组分红/利息收益：net = gross - withholding_tax。
+type incomeAmounts struct {
+	Gross          decimal.Decimal `json:"gross"`
+	WithholdingTax decimal.Decimal `json:"withholding_tax"`
+	Net            decimal.Decimal `json:"net"`
+}
+
+func (a incomeAmounts) add(b incomeAmounts) incomeAmounts {
+	return incomeAmounts{
+		Gross:          a.Gross.Add(b.Gross),
+		WithholdingTax: a.WithholdingTax.Add(b.WithholdingTax),
+		Net:            a.Net.Add(b.Net),
+	}
+}
+
+// convert 按汇率将各金额折算为目标币种。
+func (a incomeAmounts) convert(rate fx.Rate) incomeAmounts {
+	return incomeAmounts{
+		Gross:          rate.Apply(a.Gross),
+		WithholdingTax: rate.Apply(a.WithholdingTax),
+		Net:            rate.Apply(a.Net),
+	}
+}
+
+// incomeItem 为单笔收益，金额以现金账户币种计；exchange_rate 为发放日折算本位币的汇率，
+// 缺少汇率时为 null 且不计入汇总。
+type incomeItem struct {
+	DividendID    uint             `json:"dividend_id"`
+	TransactionID uint             `json:"transaction_id"`
+	PayDate       string           `json:"pay_date"`
+	ExDate        *string          `json:"ex_date"`
+	Kind          string           `json:"kind"`
+	SecurityID    uint             `json:"security_id"`
+	Ticker        string           `json:"ticker"`
+	Name          string           `json:"name"`
+	Currency      string           `json:"currency"`
+	Reinvested    bool             `json:"reinvested"`
+	ExchangeRate  *decimal.Decimal `json:"exchange_rate"`
+	RateMissing   bool             `json:"rate_missing"`
+	incomeAmounts
+}
+
+type incomeSecurity struct {
+	SecurityID uint   `json:"security_id"`
+	Ticker     string `json:"ticker"`
+	Name       string `json:"name"`
+	incomeAmounts
+}
+
+type incomeGroup struct {
+	Key string `json:"key"`
+	incomeAmounts
+}
+
+type investmentIncomeResponse struct {
+	LedgerID     int              `json:"ledger_id"`
+	DateFrom     *string          `json:"date_from"`
+	DateTo       *string          `json:"date_to"`
+	GroupBy      string           `json:"group_by"`
+	BaseCurrency string           `json:"base_currency"`
+	Totals       incomeAmounts    `json:"totals"`
+	MissingRates []string         `json:"missing_rates"`
+	Items        []incomeItem     `json:"items"`
+	Securities   []incomeSecurity `json:"securities"`
+	Kinds        []incomeGroup    `json:"kinds"`
+	Periods      []incomeGroup    `json:"periods"`
+}
+
+type dividendRow struct {
+	DividendID     uint            `gorm:"column:dividend_id"`
+	TransactionID  uint            `gorm:"column:transaction_id"`
+	PayDate        time.Time       `gorm:"column:pay_date"`
+	ExDate         *time.Time      `gorm:"column:ex_date"`
+	Kind           string          `gorm:"column:kind"`
+	SecurityID     uint            `gorm:"column:security_id"`
+	Ticker         string          `gorm:"column:ticker"`
+	SecurityName   string          `gorm:"column:security_name"`
+	Currency       string          `gorm:"column:currency"`
+	GrossAmount    decimal.Decimal `gorm:"column:gross_amount"`
+	WithholdingTax decimal.Decimal `gorm:"column:withholding_tax"`
+	LotID          *uint           `gorm:"column:lot_id"`
+}
+
+// investmentIncome 汇总分红、利息与分配收益，按证券、类型与期间分组；汇总以本位币计，按发放日汇率折算。
+func (h Handler) investmentIncome(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var dateFrom, dateTo *time.Time
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		dateFrom = &parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		dateTo = &parsed
+	}
+
+	var securityID uint
+	if value := strings.TrimSpace(c.Query("security_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security_id"})
+			return
+		}
+		securityID = uint(parsed)
+	}
+
+	groupBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("group_by", "month")))
+	if groupBy != "month" && groupBy != "quarter" && groupBy != "year" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be month, quarter or year"})
+		return
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+
+	rows, err := dividendRows(h.db, ledgerID, dateFrom, dateTo, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query dividends"})
+		return
+	}
+
+	converters := map[string]*fx.Converter{}
+	missingRates := map[string]struct{}{}
+	items := make([]incomeItem, 0, len(rows))
+	var totals incomeAmounts
+	var securities []incomeSecurity
+	securityIndex := map[uint]int{}
+	var kinds, periods []incomeGroup
+	kindIndex := map[string]int{}
+	periodIndex := map[string]int{}
+
+	for _, row := range rows {
+		item := incomeItem{
+			DividendID:    row.DividendID,
+			TransactionID: row.TransactionID,
+			PayDate:       row.PayDate.Format("2006-01-02"),
+			Kind:          row.Kind,
+			SecurityID:    row.SecurityID,
+			Ticker:        row.Ticker,
+			Name:          row.SecurityName,
+			Currency:      strings.ToUpper(row.Currency),
+			Reinvested:    row.LotID != nil,
+			incomeAmounts: incomeAmounts{
+				Gross:          row.GrossAmount,
+				WithholdingTax: row.WithholdingTax,
+				Net:            row.GrossAmount.Sub(row.WithholdingTax),
+			},
+		}
+		if row.ExDate != nil {
+			value := row.ExDate.Format("2006-01-02")
+			item.ExDate = &value
+		}
+
+		converter, ok := converters[item.PayDate]
+		if !ok {
+			converter = fx.NewConverter(h.db, ledgerID, row.PayDate)
+			converters[item.PayDate] = converter
+		}
+		rate, found, err := converter.Rate(item.Currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found {
+			item.RateMissing = true
+			missingRates[item.Currency] = struct{}{}
+			items = append(items, item)
+			continue
+		}
+		item.ExchangeRate = &rate.Value
+		items = append(items, item)
+
+		base := item.incomeAmounts.convert(rate)
+		totals = totals.add(base)
+
+		idx, ok := securityIndex[item.SecurityID]
+		if !ok {
+			idx = len(securities)
+			securityIndex[item.SecurityID] = idx
+			securities = append(securities, incomeSecurity{SecurityID: item.SecurityID, Ticker: item.Ticker, Name: item.Name})
+		}
+		securities[idx].incomeAmounts = securities[idx].incomeAmounts.add(base)
+
+		idx, ok = kindIndex[item.Kind]
+		if !ok {
+			idx = len(kinds)
+			kindIndex[item.Kind] = idx
+			kinds = append(kinds, incomeGroup{Key: item.Kind})
+		}
+		kinds[idx].incomeAmounts = kinds[idx].incomeAmounts.add(base)
+
+		key := periodKey(item.PayDate, groupBy)
+		idx, ok = periodIndex[key]
+		if !ok {
+			idx = len(periods)
+			periodIndex[key] = idx
+			periods = append(periods, incomeGroup{Key: key})
+		}
+		periods[idx].incomeAmounts = periods[idx].incomeAmounts.add(base)
+	}
+
+	sort.Slice(securities, func(i, j int) bool { return securities[i].Ticker < securities[j].Ticker })
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Key < kinds[j].Key })
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Key < periods[j].Key })
+	if securities == nil {
+		securities = []incomeSecurity{}
+	}
+	if kinds == nil {
+		kinds = []incomeGroup{}
+	}
+	if periods == nil {
+		periods = []incomeGroup{}
+	}
+
+	resp := investmentIncomeResponse{
+		LedgerID:     ledgerID,
+		GroupBy:      groupBy,
+		BaseCurrency: baseCurrency,
+		Totals:       totals,
+		MissingRates: sortedKeys(missingRates),
+		Items:        items,
+		Securities:   securities,
+		Kinds:        kinds,
+		Periods:      periods,
+	}
+	if dateFrom != nil {
+		value := dateFrom.Format("2006-01-02")
+		resp.DateFrom = &value
+	}
+	if dateTo != nil {
+		value := dateTo.Format("2006-01-02")
+		resp.DateTo = &value
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// dividendRows 返回发放日在区间内的分红记录，按发放日与 id 排序。
+func dividendRows(db *gorm.DB, ledgerID int, dateFrom, dateTo *time.Time, securityID uint) ([]dividendRow, error) {
+	query := `
+SELECT
+  d.id AS dividend_id,
+  t.id AS transaction_id,
+  t.occurred_on AS pay_date,
+  d.ex_date,
+  d.kind,
+  d.security_id,
+  s.ticker,
+  s.name AS security_name,
+  acc.currency,
+  d.gross_amount,
+  d.withholding_tax,
+  d.lot_id
+FROM fin_investment_dividends d
+JOIN fin_transaction_lines tl ON tl.id = d.transaction_line_id AND tl.deleted_at IS NULL
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+JOIN fin_accounts acc ON acc.id = tl.account_id
+JOIN fin_securities s ON s.id = d.security_id
+WHERE d.deleted_at IS NULL AND d.ledger_id = ?`
+
+	args := []interface{}{ledgerID}
+	if dateFrom != nil {
+		query += " AND t.occurred_on >= ?"
+		args = append(args, *dateFrom)
+	}
+	if dateTo != nil {
+		query += " AND t.occurred_on <= ?"
+		args = append(args, *dateTo)
+	}
+	if securityID != 0 {
+		query += " AND d.security_id = ?"
+		args = append(args, securityID)
+	}
+	query += " ORDER BY t.occurred_on, d.id"
+
+	var rows []dividendRow
+	err := db.Raw(query, args...).Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,334 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// maxNetWorthPoints 限制单次请求的时点数，避免按日查询过长区间。
+const maxNetWorthPoints = 1000
+
+// netWorthPoint 为一个时点的本位币合计；有账户缺少汇率时 rate_missing 为 true，该账户不计入合计。
+type netWorthPoint struct {
+	Date        string          `json:"date"`
+	Assets      decimal.Decimal `json:"assets"`
+	Liabilities decimal.Decimal `json:"liabilities"`
+	NetWorth    decimal.Decimal `json:"net_worth"`
+	RateMissing bool            `json:"rate_missing"`
+}
+
+// netWorthAccount 的 balances 为各时点的原币余额，base_balances 为本位币金额（缺少汇率时为 null），均与 points 对应。
+type netWorthAccount struct {
+	ID           uint               `json:"id"`
+	Name         string             `json:"name"`
+	Type         string             `json:"type"`
+	Group        string             `json:"group"`
+	Currency     string             `json:"currency"`
+	Balances     []decimal.Decimal  `json:"balances"`
+	BaseBalances []*decimal.Decimal `json:"base_balances"`
+}
+
+// netWorthType 为同一账户类型在各时点的本位币合计。
+type netWorthType struct {
+	Type         string            `json:"type"`
+	Group        string            `json:"group"`
+	BaseBalances []decimal.Decimal `json:"base_balances"`
+}
+
+type netWorthResponse struct {
+	LedgerID     int               `json:"ledger_id"`
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	Interval     string            `json:"interval"`
+	Breakdown    string            `json:"breakdown"`
+	BaseCurrency string            `json:"base_currency"`
+	Points       []netWorthPoint   `json:"points"`
+	Accounts     []netWorthAccount `json:"accounts,omitempty"`
+	Types        []netWorthType    `json:"types,omitempty"`
+	MissingRates []string          `json:"missing_rates"`
+}
+
+type balanceSnapshotRow struct {
+	AccountID uint            `gorm:"column:account_id"`
+	AsOf      time.Time       `gorm:"column:as_of"`
+	Amount    decimal.Decimal `gorm:"column:amount"`
+}
+
+type balanceDeltaRow struct {
+	AccountID  uint            `gorm:"column:account_id"`
+	OccurredOn time.Time       `gorm:"column:occurred_on"`
+	Amount     decimal.Decimal `gorm:"column:amount"`
+}
+
+// netWorth 返回 from 至 to 之间按 interval 取点的资产、负债与净资产（口径同资产负债表），
+// breakdown=account|type 时同时给出按账户或账户类型的明细。每个时点为该日、周（周日）或月的最后一天，
+// 最后一个时点为 to。金额以本位币计，按各时点汇率折算。
+func (h Handler) netWorth(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := strings.TrimSpace(c.Query("to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(-1, 0, 0)
+	if value := strings.TrimSpace(c.Query("from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	interval := strings.ToLower(strings.TrimSpace(c.DefaultQuery("interval", "month")))
+	if interval != "day" && interval != "week" && interval != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be day, week or month"})
+		return
+	}
+
+	breakdown := strings.ToLower(strings.TrimSpace(c.Query("breakdown")))
+	if breakdown != "" && breakdown != "account" && breakdown != "type" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "breakdown must be account or type"})
+		return
+	}
+
+	dates := seriesDates(from, to, interval)
+	if len(dates) > maxNetWorthPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many points, use a shorter range or a longer interval"})
+		return
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+
+	var accounts []model.Account
+	if err := h.db.Where("ledger_id = ?", ledgerID).Order("id").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query accounts"})
+		return
+	}
+
+	balances, err := accountBalances(h.db, ledgerID, accounts, dates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query balances"})
+		return
+	}
+
+	history := fx.NewHistory(h.db, ledgerID, to)
+	missingRates := map[string]struct{}{}
+	points := make([]netWorthPoint, len(dates))
+	for i, date := range dates {
+		points[i].Date = date.Format("2006-01-02")
+	}
+	var accountRows []netWorthAccount
+	var typeRows []netWorthType
+	typeIndex := map[string]int{}
+
+	for _, account := range accounts {
+		currency := strings.ToUpper(account.Currency)
+		group := classifyAccountType(account.Type)
+		row := netWorthAccount{
+			ID:           account.ID,
+			Name:         account.Name,
+			Type:         account.Type,
+			Group:        group,
+			Currency:     currency,
+			Balances:     balances[account.ID],
+			BaseBalances: make([]*decimal.Decimal, len(dates)),
+		}
+		typeKey := strings.ToLower(strings.TrimSpace(account.Type))
+		idx, ok := typeIndex[typeKey]
+		if !ok && breakdown == "type" {
+			idx = len(typeRows)
+			typeIndex[typeKey] = idx
+			typeRows = append(typeRows, netWorthType{Type: typeKey, Group: group, BaseBalances: make([]decimal.Decimal, len(dates))})
+		}
+
+		for i, date := range dates {
+			rate, found, err := history.Rate(currency, baseCurrency, date)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+				return
+			}
+			if !found {
+				if !row.Balances[i].IsZero() {
+					points[i].RateMissing = true
+					missingRates[currency] = struct{}{}
+				}
+				continue
+			}
+			converted := rate.Apply(row.Balances[i])
+			row.BaseBalances[i] = &converted
+			switch group {
+			case "asset":
+				points[i].Assets = points[i].Assets.Add(converted)
+			case "liability":
+				points[i].Liabilities = points[i].Liabilities.Add(converted)
+			}
+			if breakdown == "type" {
+				typeRows[idx].BaseBalances[i] = typeRows[idx].BaseBalances[i].Add(converted)
+			}
+		}
+		if breakdown == "account" {
+			accountRows = append(accountRows, row)
+		}
+	}
+	for i := range points {
+		points[i].NetWorth = points[i].Assets.Sub(points[i].Liabilities)
+	}
+	sort.Slice(typeRows, func(i, j int) bool { return typeRows[i].Type < typeRows[j].Type })
+
+	c.JSON(http.StatusOK, netWorthResponse{
+		LedgerID:     ledgerID,
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Interval:     interval,
+		Breakdown:    breakdown,
+		BaseCurrency: baseCurrency,
+		Points:       points,
+		Accounts:     accountRows,
+		Types:        typeRows,
+		MissingRates: sortedKeys(missingRates),
+	})
+}
+
+// seriesDates 返回 from 至 to 之间每日、每周（周日）或每月最后一天的日期，最后一个时点为 to。
+func seriesDates(from, to time.Time, interval string) []time.Time {
+	var dates []time.Time
+	for date := from; !date.After(to); {
+		var end time.Time
+		switch interval {
+		case "week":
+			end = date.AddDate(0, 0, (7-int(date.Weekday()))%7)
+		case "month":
+			end = time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location())
+		default:
+			end = date
+		}
+		if end.After(to) {
+			end = to
+		}
+		dates = append(dates, end)
+		date = end.AddDate(0, 0, 1)
+	}
+	return dates
+}
+
+// accountBalances 以两次集合查询计算各账户在 dates（升序）各日的余额，结果与 dates 一一对应：
+// 取当日或之前最近一次快照，加上快照日之后至当日的分录合计；没有快照时为当日及之前的全部分录合计。
+// 负债账户余额为未偿还金额，口径同 model.AccountBalance。
+func accountBalances(db *gorm.DB, ledgerID int, accounts []model.Account, dates []time.Time) (map[uint][]decimal.Decimal, error) {
+	balances := make(map[uint][]decimal.Decimal, len(accounts))
+	if len(dates) == 0 {
+		return balances, nil
+	}
+	last := dates[len(dates)-1]
+
+	var snapshots []balanceSnapshotRow
+	if err := db.Raw(`
+SELECT account_id, as_of, SUM(amount) AS amount
+FROM fin_account_snapshots
+WHERE ledger_id = ? AND deleted_at IS NULL AND as_of <= ?
+GROUP BY account_id, as_of
+ORDER BY account_id, as_of`, ledgerID, last).Scan(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	var deltas []balanceDeltaRow
+	if err := db.Raw(`
+SELECT tl.account_id, t.occurred_on, SUM(tl.amount) AS amount
+FROM fin_transaction_lines tl
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+WHERE tl.ledger_id = ? AND tl.deleted_at IS NULL AND t.occurred_on <= ?
+GROUP BY tl.account_id, t.occurred_on
+ORDER BY tl.account_id, t.occurred_on`, ledgerID, last).Scan(&deltas).Error; err != nil {
+		return nil, err
+	}
+
+	snapshotsByAccount := map[uint][]balanceSnapshotRow{}
+	for _, row := range snapshots {
+		snapshotsByAccount[row.AccountID] = append(snapshotsByAccount[row.AccountID], row)
+	}
+	// 按账户累计每日分录，cumulative[i] 为截至 days[i]（含）的分录合计。
+	type accountDeltas struct {
+		days       []string
+		cumulative []decimal.Decimal
+	}
+	deltasByAccount := map[uint]*accountDeltas{}
+	for _, row := range deltas {
+		entry, ok := deltasByAccount[row.AccountID]
+		if !ok {
+			entry = &accountDeltas{}
+			deltasByAccount[row.AccountID] = entry
+		}
+		total := row.Amount
+		if n := len(entry.cumulative); n > 0 {
+			total = total.Add(entry.cumulative[n-1])
+		}
+		entry.days = append(entry.days, row.OccurredOn.Format("2006-01-02"))
+		entry.cumulative = append(entry.cumulative, total)
+	}
+	through := func(entry *accountDeltas, day string) decimal.Decimal {
+		if entry == nil {
+			return decimal.Zero
+		}
+		n := sort.SearchStrings(entry.days, day)
+		if n < len(entry.days) && entry.days[n] == day {
+			n++
+		}
+		if n == 0 {
+			return decimal.Zero
+		}
+		return entry.cumulative[n-1]
+	}
+
+	for _, account := range accounts {
+		accountSnapshots := snapshotsByAccount[account.ID]
+		entry := deltasByAccount[account.ID]
+		values := make([]decimal.Decimal, len(dates))
+		next := 0
+		var snapshot *balanceSnapshotRow
+		for i, date := range dates {
+			day := date.Format("2006-01-02")
+			for next < len(accountSnapshots) && accountSnapshots[next].AsOf.Format("2006-01-02") <= day {
+				snapshot = &accountSnapshots[next]
+				next++
+			}
+			sum := through(entry, day)
+			snapshotAmount := decimal.Zero
+			if snapshot != nil {
+				sum = sum.Sub(through(entry, snapshot.AsOf.Format("2006-01-02")))
+				snapshotAmount = snapshot.Amount
+			}
+			values[i] = model.AccountBalance(account.Type, snapshotAmount, sum)
+		}
+		balances[account.ID] = values
+	}
+	return balances, nil
+}
@@ -0,0 +1,794 @@
+package report
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// maxPerformanceDays 限制按日估值的区间长度；未指定 from 时默认区间截断到该长度。
+const maxPerformanceDays = 3660
+
+// performanceFlow 为一笔外部现金流，amount 为投入组合的净额（本位币）：买入为正（含手续费税费），
+// 卖出（扣除手续费税费）与分红/利息（扣除代扣税）为负。
+type performanceFlow struct {
+	Date       string          `json:"date"`
+	Kind       string          `json:"kind"`
+	SecurityID uint            `json:"security_id"`
+	Ticker     string          `json:"ticker"`
+	Amount     decimal.Decimal `json:"amount"`
+}
+
+// performancePoint 为每日估值：twr 与 benchmark 为截至当日的累计收益率（百分比）。
+// 当日有持仓缺少汇率时 rate_missing 为 true，该日及之后的 twr 为 null。
+type performancePoint struct {
+	Date         string           `json:"date"`
+	MarketValue  decimal.Decimal  `json:"market_value"`
+	Contribution decimal.Decimal  `json:"contribution"`
+	TWR          *decimal.Decimal `json:"twr"`
+	RateMissing  bool             `json:"rate_missing"`
+	Benchmark    *decimal.Decimal `json:"benchmark,omitempty"`
+}
+
+type performanceBenchmark struct {
+	SecurityID       uint             `json:"security_id"`
+	Ticker           string           `json:"ticker"`
+	Name             string           `json:"name"`
+	StartDate        *string          `json:"start_date"`
+	StartPrice       *decimal.Decimal `json:"start_price"`
+	EndDate          *string          `json:"end_date"`
+	EndPrice         *decimal.Decimal `json:"end_price"`
+	Return           *decimal.Decimal `json:"return"`
+	AnnualizedReturn *decimal.Decimal `json:"annualized_return"`
+	ExcessReturn     *decimal.Decimal `json:"excess_return"`
+}
+
+// performanceResponse 中收益率均为百分比（2 位小数）：xirr 为年化内部收益率，twr 为区间时间加权收益率，
+// mwr 为区间资金加权收益率（Modified Dietz）；无法计算或有估值、现金流缺少汇率时为 null。
+// 区间不足一年时不给出年化 TWR。
+type performanceResponse struct {
+	LedgerID         int                   `json:"ledger_id"`
+	From             string                `json:"from"`
+	To               string                `json:"to"`
+	Days             int                   `json:"days"`
+	AccountID        *uint                 `json:"account_id"`
+	SecurityID       *uint                 `json:"security_id"`
+	BaseCurrency     string                `json:"base_currency"`
+	StartValue       decimal.Decimal       `json:"start_value"`
+	EndValue         decimal.Decimal       `json:"end_value"`
+	NetContributions decimal.Decimal       `json:"net_contributions"`
+	Gain             decimal.Decimal       `json:"gain"`
+	XIRR             *decimal.Decimal      `json:"xirr"`
+	TWR              *decimal.Decimal      `json:"twr"`
+	TWRAnnualized    *decimal.Decimal      `json:"twr_annualized"`
+	MWR              *decimal.Decimal      `json:"mwr"`
+	Benchmark        *performanceBenchmark `json:"benchmark"`
+	MissingPrices    []string              `json:"missing_prices"`
+	MissingRates     []string              `json:"missing_rates"`
+	CashFlows        []performanceFlow     `json:"cash_flows"`
+	Series           []performancePoint    `json:"series"`
+}
+
+type performanceLot struct {
+	ID                uint            `gorm:"column:id"`
+	SecurityID        uint            `gorm:"column:security_id"`
+	Ticker            string          `gorm:"column:ticker"`
+	SecurityCurrency  string          `gorm:"column:security_currency"`
+	AccountID         uint            `gorm:"column:account_id"`
+	AccountCurrency   string          `gorm:"column:account_currency"`
+	Quantity          decimal.Decimal `gorm:"column:quantity"`
+	Price             decimal.Decimal `gorm:"column:price"`
+	OccurredOn        time.Time       `gorm:"column:occurred_on"`
+	OpenedOn          *time.Time      `gorm:"column:opened_on"`
+	ClosedOn          *time.Time      `gorm:"column:closed_on"`
+	CorporateActionID *uint           `gorm:"column:corporate_action_id"`
+	TransferID        *uint           `gorm:"column:transfer_id"`
+	// TransferFrom 为批次转移的转出账户；转入批次的 AccountID 为转入账户，剩余批次仍为转出账户。
+	TransferFrom *uint `gorm:"column:transfer_from_account_id"`
+}
+
+// openedOn 返回批次开始持有的日期：公司行动生成的批次为生效日，其余为买入日。
+func (l performanceLot) openedOn() time.Time {
+	if l.OpenedOn != nil {
+		return *l.OpenedOn
+	}
+	return l.OccurredOn
+}
+
+// lotDraw 为卖出从批次中扣减的数量。
+type lotDraw struct {
+	date     time.Time
+	quantity decimal.Decimal
+}
+
+// remainingOn 返回批次在 date 当日收盘时的剩余数量，批次未开立或已被公司行动关闭时为 0。
+func (l performanceLot) remainingOn(date time.Time, draws []lotDraw) decimal.Decimal {
+	if l.openedOn().After(date) || (l.ClosedOn != nil && !l.ClosedOn.After(date)) {
+		return decimal.Zero
+	}
+	remaining := l.Quantity
+	for _, draw := range draws {
+		if !draw.date.After(date) {
+			remaining = remaining.Sub(draw.quantity)
+		}
+	}
+	return remaining
+}
+
+// datedFlow 为内部收益率计算使用的现金流，amount 以投资者视角计（投入为负，取回为正）。
+type datedFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// investmentPerformance 计算区间 [from, to] 的投资业绩：按外部现金流（买入、卖出、分红/利息，
+// 均含手续费税费）求 XIRR 与 Modified Dietz 资金加权收益率，按每日市值求时间加权收益率，
+// 并可与基准证券的价格走势比较。估值与现金流以本位币计，按当日汇率折算；尚无收盘价的持仓按成本估值。
+func (h Handler) investmentPerformance(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := strings.TrimSpace(c.Query("to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+	var from *time.Time
+	if value := strings.TrimSpace(c.Query("from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = &parsed
+	}
+
+	var accountID, securityID, benchmarkID uint
+	for _, param := range []struct {
+		name   string
+		target *uint
+	}{{"account_id", &accountID}, {"security_id", &securityID}, {"benchmark_security_id", &benchmarkID}} {
+		if value := strings.TrimSpace(c.Query(param.name)); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || parsed == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+				return
+			}
+			*param.target = uint(parsed)
+		}
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+
+	var benchmark *model.Security
+	if benchmarkID != 0 {
+		var record model.Security
+		if err := h.db.Where("id = ? AND ledger_id = ?", benchmarkID, ledgerID).First(&record).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "benchmark security not found"})
+			return
+		}
+		benchmark = &record
+	}
+
+	lots, err := performanceLots(h.db, ledgerID, to, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query investment lots"})
+		return
+	}
+	sales, err := saleAllocations(h.db, ledgerID, nil, &to, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query investment sales"})
+		return
+	}
+
+	lotIndex := make(map[uint]int, len(lots))
+	for i, lot := range lots {
+		lotIndex[lot.ID] = i
+	}
+	draws := make(map[uint][]lotDraw)
+	for _, row := range sales {
+		draws[row.LotID] = append(draws[row.LotID], lotDraw{date: row.OccurredOn, quantity: row.Quantity})
+	}
+
+	// 只保留所选账户的批次；分红按全部账户的持仓比例分摊，因此先保留完整列表。
+	selected := lots
+	if accountID != 0 {
+		selected = nil
+		for _, lot := range lots {
+			if lot.AccountID == accountID {
+				selected = append(selected, lot)
+			}
+		}
+	}
+
+	if from == nil {
+		start := to
+		for _, lot := range selected {
+			if lot.openedOn().Before(start) {
+				start = lot.openedOn()
+			}
+		}
+		if earliest := to.AddDate(0, 0, 1-maxPerformanceDays); start.Before(earliest) {
+			start = earliest
+		}
+		from = &start
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if from.Before(to.AddDate(0, 0, 1-maxPerformanceDays)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range too long, at most " + strconv.Itoa(maxPerformanceDays) + " days"})
+		return
+	}
+	startDate := from.AddDate(0, 0, -1)
+
+	missingPrices := map[string]struct{}{}
+	missingRates := map[string]struct{}{}
+	history := fx.NewHistory(h.db, ledgerID, to)
+	convert := func(amount decimal.Decimal, currency string, date time.Time) (decimal.Decimal, bool, error) {
+		currency = strings.ToUpper(currency)
+		rate, found, err := history.Rate(currency, baseCurrency, date)
+		if err != nil || !found {
+			if err == nil {
+				missingRates[currency] = struct{}{}
+			}
+			return decimal.Zero, false, err
+		}
+		return rate.Apply(amount), true, nil
+	}
+
+	// 外部现金流：买入（不含公司行动与批次转移生成的批次）、卖出净额与分红净额；
+	// 按账户筛选时，批次转移按成本记为转出账户的流出与转入账户的流入。
+	var flows []performanceFlow
+	contributions := map[string]decimal.Decimal{}
+	// rateGap 记录是否有现金流或估值因缺少汇率未能计入，此时收益率不可靠。
+	rateGap := false
+	addFlow := func(date time.Time, kind string, securityID uint, ticker string, amount decimal.Decimal, currency string) error {
+		if date.Before(*from) || date.After(to) {
+			return nil
+		}
+		base, ok, err := convert(amount, currency, date)
+		if err != nil || !ok {
+			rateGap = rateGap || !ok
+			return err
+		}
+		key := date.Format("2006-01-02")
+		flows = append(flows, performanceFlow{Date: key, Kind: kind, SecurityID: securityID, Ticker: ticker, Amount: base})
+		contributions[key] = contributions[key].Add(base)
+		return nil
+	}
+
+	for _, lot := range selected {
+		if lot.CorporateActionID != nil || lot.TransferID != nil {
+			continue
+		}
+		cost := money.Round(lot.Quantity.Mul(lot.Price), lot.AccountCurrency)
+		if err := addFlow(lot.openedOn(), "buy", lot.SecurityID, lot.Ticker, cost, lot.AccountCurrency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+	}
+
+	if accountID != 0 {
+		for _, lot := range lots {
+			// 只有转入批次代表移动的持仓，转出账户保留的剩余批次不产生现金流。
+			if lot.TransferID == nil || lot.TransferFrom == nil || lot.AccountID == *lot.TransferFrom {
+				continue
+			}
+			cost := money.Round(lot.Quantity.Mul(lot.Price), lot.AccountCurrency)
+			kind := ""
+			switch accountID {
+			case lot.AccountID:
+				kind = "transfer_in"
+			case *lot.TransferFrom:
+				kind, cost = "transfer_out", cost.Neg()
+			default:
+				continue
+			}
+			if err := addFlow(lot.openedOn(), kind, lot.SecurityID, lot.Ticker, cost, lot.AccountCurrency); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+				return
+			}
+		}
+	}
+
+	seenSales := map[uint]bool{}
+	for _, row := range sales {
+		if seenSales[row.SaleID] {
+			continue
+		}
+		idx, ok := lotIndex[row.LotID]
+		if !ok || (accountID != 0 && lots[idx].AccountID != accountID) {
+			continue
+		}
+		seenSales[row.SaleID] = true
+		gross := money.Round(row.SaleQuantity.Mul(row.SalePrice), row.Currency)
+		net := gross.Sub(row.Fee).Sub(row.Tax)
+		if err := addFlow(row.OccurredOn, "sale", row.SecurityID, row.Ticker, net.Neg(), row.Currency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+	}
+
+	dividends, err := dividendRows(h.db, ledgerID, from, &to, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query dividends"})
+		return
+	}
+	for _, row := range dividends {
+		net := row.GrossAmount.Sub(row.WithholdingTax)
+		if accountID != 0 {
+			// 按除息日前一日（未填写时为发放日）的持仓比例分摊到所选账户。
+			on := row.PayDate
+			if row.ExDate != nil {
+				on = row.ExDate.AddDate(0, 0, -1)
+			}
+			total, held := decimal.Zero, decimal.Zero
+			for _, lot := range lots {
+				if lot.SecurityID != row.SecurityID {
+					continue
+				}
+				quantity := lot.remainingOn(on, draws[lot.ID])
+				total = total.Add(quantity)
+				if lot.AccountID == accountID {
+					held = held.Add(quantity)
+				}
+			}
+			if !held.IsPositive() {
+				continue
+			}
+			net = money.Round(net.Mul(held).Div(total), row.Currency)
+		}
+		if err := addFlow(row.PayDate, "dividend", row.SecurityID, row.Ticker, net.Neg(), row.Currency); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+	}
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].Date < flows[j].Date })
+
+	securityIDs := make([]uint, 0)
+	seenSecurities := map[uint]bool{}
+	for _, lot := range selected {
+		if !seenSecurities[lot.SecurityID] {
+			seenSecurities[lot.SecurityID] = true
+			securityIDs = append(securityIDs, lot.SecurityID)
+		}
+	}
+	if benchmark != nil && !seenSecurities[benchmark.ID] {
+		securityIDs = append(securityIDs, benchmark.ID)
+	}
+	prices, err := priceSeries(h.db, ledgerID, securityIDs, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query prices"})
+		return
+	}
+
+	priceCursor := map[uint]int{}
+	priceOn := func(securityID uint, date time.Time) *priceRow {
+		series := prices[securityID]
+		i := priceCursor[securityID]
+		for i < len(series) && !series[i].PriceAt.After(date) {
+			i++
+		}
+		priceCursor[securityID] = i
+		if i == 0 {
+			return nil
+		}
+		return &series[i-1]
+	}
+	// 区间开始前已关闭的批次不参与每日估值。
+	var valued []performanceLot
+	for _, lot := range selected {
+		if lot.ClosedOn == nil || lot.ClosedOn.After(startDate) {
+			valued = append(valued, lot)
+		}
+	}
+	// valueOn 返回 date 收盘时所选批次的本位币市值，须按日期升序调用；
+	// 有持仓缺少汇率时 complete 为 false，该持仓不计入市值。
+	valueOn := func(date time.Time) (total decimal.Decimal, complete bool, err error) {
+		type holding struct {
+			ticker   string
+			currency string
+			quantity decimal.Decimal
+			cost     map[string]decimal.Decimal
+		}
+		holdings := map[uint]*holding{}
+		for _, lot := range valued {
+			quantity := lot.remainingOn(date, draws[lot.ID])
+			if !quantity.IsPositive() {
+				continue
+			}
+			item, ok := holdings[lot.SecurityID]
+			if !ok {
+				item = &holding{ticker: lot.Ticker, currency: lot.SecurityCurrency, cost: map[string]decimal.Decimal{}}
+				holdings[lot.SecurityID] = item
+			}
+			item.quantity = item.quantity.Add(quantity)
+			item.cost[lot.AccountCurrency] = item.cost[lot.AccountCurrency].Add(quantity.Mul(lot.Price))
+		}
+
+		complete = true
+		for _, id := range securityIDs {
+			item, ok := holdings[id]
+			if !ok {
+				continue
+			}
+			if price := priceOn(id, date); price != nil {
+				base, found, err := convert(item.quantity.Mul(price.ClosePrice), item.currency, date)
+				if err != nil {
+					return decimal.Zero, false, err
+				}
+				total = total.Add(base)
+				complete = complete && found
+				continue
+			}
+			missingPrices[item.ticker] = struct{}{}
+			for currency, cost := range item.cost {
+				base, found, err := convert(cost, currency, date)
+				if err != nil {
+					return decimal.Zero, false, err
+				}
+				total = total.Add(base)
+				complete = complete && found
+			}
+		}
+		return total, complete, nil
+	}
+
+	startValue, startComplete, err := valueOn(startDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+		return
+	}
+
+	var benchmarkStart, benchmarkEnd *priceRow
+	if benchmark != nil {
+		benchmarkStart = latestBefore(prices[benchmark.ID], startDate)
+	}
+
+	// 时间加权收益按日连乘：当日投入视为开盘前发生，取回（卖出、分红）视为收盘后发生。
+	// 缺少汇率的持仓按 0 计入会造成虚假的涨跌，因此该日及之后不再给出累计 TWR。
+	index := 1.0
+	previous := startValue
+	valuationGap := !startComplete
+	series := make([]performancePoint, 0)
+	for date := *from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		marketValue, complete, err := valueOn(date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		key := date.Format("2006-01-02")
+		contribution := contributions[key]
+		inflow, outflow := contribution, decimal.Zero
+		if contribution.IsNegative() {
+			inflow, outflow = decimal.Zero, contribution
+		}
+		if denominator := previous.Add(inflow); denominator.IsPositive() {
+			growth, _ := marketValue.Sub(outflow).Div(denominator).Float64()
+			index *= growth
+		}
+		previous = marketValue
+
+		valuationGap = valuationGap || !complete
+		point := performancePoint{
+			Date:         key,
+			MarketValue:  marketValue,
+			Contribution: contribution,
+			RateMissing:  !complete,
+		}
+		if !valuationGap {
+			value := percentRate(index - 1)
+			point.TWR = &value
+		}
+		if benchmark != nil {
+			if price := latestBefore(prices[benchmark.ID], date); price != nil {
+				if benchmarkStart == nil {
+					benchmarkStart = price
+				}
+				benchmarkEnd = price
+				if rate, ok := priceReturn(benchmarkStart, price); ok {
+					value := percentRate(rate)
+					point.Benchmark = &value
+				}
+			}
+		}
+		series = append(series, point)
+	}
+	endValue := previous
+
+	netContributions := decimal.Zero
+	for _, flow := range flows {
+		netContributions = netContributions.Add(flow.Amount)
+	}
+	days := int(to.Sub(startDate).Hours()/24 + 0.5)
+
+	resp := performanceResponse{
+		LedgerID:         ledgerID,
+		From:             from.Format("2006-01-02"),
+		To:               to.Format("2006-01-02"),
+		Days:             days,
+		BaseCurrency:     baseCurrency,
+		StartValue:       startValue,
+		EndValue:         endValue,
+		NetContributions: netContributions,
+		Gain:             endValue.Sub(startValue).Sub(netContributions),
+		MissingPrices:    sortedKeys(missingPrices),
+		MissingRates:     sortedKeys(missingRates),
+		CashFlows:        flows,
+		Series:           series,
+	}
+	if resp.CashFlows == nil {
+		resp.CashFlows = []performanceFlow{}
+	}
+	if accountID != 0 {
+		resp.AccountID = &accountID
+	}
+	if securityID != 0 {
+		resp.SecurityID = &securityID
+	}
+
+	reliable := !valuationGap && !rateGap
+	twr := index - 1
+	if reliable && (startValue.IsPositive() || len(flows) > 0) {
+		value := percentRate(twr)
+		resp.TWR = &value
+		if days >= 365 {
+			value := percentRate(annualize(twr, days))
+			resp.TWRAnnualized = &value
+		}
+	}
+
+	if reliable {
+		irrFlows := []datedFlow{{date: startDate, amount: -startValue.InexactFloat64()}}
+		for _, flow := range flows {
+			date, _ := time.ParseInLocation("2006-01-02", flow.Date, time.Local)
+			irrFlows = append(irrFlows, datedFlow{date: date, amount: -flow.Amount.InexactFloat64()})
+		}
+		irrFlows = append(irrFlows, datedFlow{date: to, amount: endValue.InexactFloat64()})
+		if rate, ok := xirr(irrFlows); ok {
+			value := percentRate(rate)
+			resp.XIRR = &value
+		}
+		resp.MWR = modifiedDietz(startValue, endValue, flows, *from, to, days)
+	}
+
+	if benchmark != nil {
+		resp.Benchmark = &performanceBenchmark{SecurityID: benchmark.ID, Ticker: benchmark.Ticker, Name: benchmark.Name}
+		if benchmarkStart != nil && benchmarkEnd != nil {
+			startDate := benchmarkStart.PriceAt.Format("2006-01-02")
+			endDate := benchmarkEnd.PriceAt.Format("2006-01-02")
+			resp.Benchmark.StartDate = &startDate
+			resp.Benchmark.StartPrice = &benchmarkStart.ClosePrice
+			resp.Benchmark.EndDate = &endDate
+			resp.Benchmark.EndPrice = &benchmarkEnd.ClosePrice
+			if rate, ok := priceReturn(benchmarkStart, benchmarkEnd); ok {
+				value := percentRate(rate)
+				resp.Benchmark.Return = &value
+				if days >= 365 {
+					annualized := percentRate(annualize(rate, days))
+					resp.Benchmark.AnnualizedReturn = &annualized
+				}
+				if resp.TWR != nil {
+					excess := resp.TWR.Sub(value)
+					resp.Benchmark.ExcessReturn = &excess
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// performanceLots 返回买入日不晚于 to 的全部批次（含公司行动与批次转移生成的批次）及其投资账户。
+func performanceLots(db *gorm.DB, ledgerID int, to time.Time, securityID uint) ([]performanceLot, error) {
+	query := `
+SELECT
+  l.id,
+  l.security_id,
+  s.ticker,
+  s.currency AS security_currency,
+  l.account_id,
+  acc.currency AS account_currency,
+  l.quantity,
+  l.price,
+  t.occurred_on,
+  l.opened_on,
+  l.closed_on,
+  l.corporate_action_id,
+  l.transfer_id,
+  xt.from_account_id AS transfer_from_account_id
+FROM fin_investment_lots l
+JOIN fin_transaction_lines tl ON tl.id = l.transaction_line_id AND tl.deleted_at IS NULL
+JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL
+JOIN fin_accounts acc ON acc.id = l.account_id
+JOIN fin_securities s ON s.id = l.security_id
+LEFT JOIN fin_investment_transfers xt ON xt.id = l.transfer_id
+WHERE l.deleted_at IS NULL AND l.ledger_id = ? AND t.occurred_on <= ?`
+
+	args := []interface{}{ledgerID, to}
+	if securityID != 0 {
+		query += " AND l.security_id = ?"
+		args = append(args, securityID)
+	}
+	query += " ORDER BY l.id"
+
+	var rows []performanceLot
+	err := db.Raw(query, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// priceSeries 返回各证券 to 当日及之前的收盘价，按日期升序。
+func priceSeries(db *gorm.DB, ledgerID int, securityIDs []uint, to time.Time) (map[uint][]priceRow, error) {
+	result := make(map[uint][]priceRow, len(securityIDs))
+	if len(securityIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []priceRow
+	if err := db.Model(&model.SecurityPrice{}).
+		Select("security_id, price_at, close_price").
+		Where("ledger_id = ? AND security_id IN ? AND price_at <= ?", ledgerID, securityIDs, to).
+		Order("security_id, price_at").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.SecurityID] = append(result[row.SecurityID], row)
+	}
+	return result, nil
+}
+
+// latestBefore 返回升序价格序列中 date 当日或之前最近的一条。
+func latestBefore(series []priceRow, date time.Time) *priceRow {
+	i := sort.Search(len(series), func(i int) bool { return series[i].PriceAt.After(date) })
+	if i == 0 {
+		return nil
+	}
+	return &series[i-1]
+}
+
+func priceReturn(start, end *priceRow) (float64, bool) {
+	if start == nil || end == nil || !start.ClosePrice.IsPositive() {
+		return 0, false
+	}
+	rate, _ := end.ClosePrice.Div(start.ClosePrice).Float64()
+	return rate - 1, true
+}
+
+// modifiedDietz 返回区间资金加权收益率（百分比）：(期末 - 期初 - 净投入) / (期初 + 按剩余天数加权的净投入)。
+// 投入按当日开盘计权重，取回按当日收盘计；分母不为正时返回 nil。
+func modifiedDietz(startValue, endValue decimal.Decimal, flows []performanceFlow, from, to time.Time, days int) *decimal.Decimal {
+	if days <= 0 {
+		return nil
+	}
+	total := decimal.NewFromInt(int64(days))
+	net := decimal.Zero
+	weighted := startValue
+	for _, flow := range flows {
+		date, _ := time.ParseInLocation("2006-01-02", flow.Date, time.Local)
+		remaining := int64(to.Sub(date).Hours()/24 + 0.5)
+		if flow.Amount.IsPositive() {
+			remaining++
+		}
+		net = net.Add(flow.Amount)
+		weighted = weighted.Add(flow.Amount.Mul(decimal.NewFromInt(remaining)).Div(total))
+	}
+	if !weighted.IsPositive() {
+		return nil
+	}
+	value := endValue.Sub(startValue).Sub(net).Mul(decimal.NewFromInt(100)).DivRound(weighted, 2)
+	return &value
+}
+
+// xirr 求使 Σ amount / (1 + r)^(天数/365) = 0 的年化收益率 r；现金流须同时有正有负。
+// 先用牛顿法，不收敛时在 (-1, 上限] 区间二分。
+func xirr(flows []datedFlow) (float64, bool) {
+	var hasPositive, hasNegative bool
+	for _, flow := range flows {
+		if flow.amount > 0 {
+			hasPositive = true
+		} else if flow.amount < 0 {
+			hasNegative = true
+		}
+	}
+	if !hasPositive || !hasNegative {
+		return 0, false
+	}
+
+	start := flows[0].date
+	years := make([]float64, len(flows))
+	for i, flow := range flows {
+		years[i] = flow.date.Sub(start).Hours() / 24 / 365
+	}
+	npv := func(rate float64) (float64, float64) {
+		var value, derivative float64
+		for i, flow := range flows {
+			discount := math.Pow(1+rate, years[i])
+			value += flow.amount / discount
+			derivative -= years[i] * flow.amount / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	rate := 0.1
+	for i := 0; i < 100; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < 1e-7 {
+			return rate, true
+		}
+		if derivative == 0 || math.IsNaN(derivative) {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-10 {
+			return next, true
+		}
+		rate = next
+	}
+
+	low, high := -0.999999, 1.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	for lowValue*highValue > 0 && high < 1e6 {
+		high *= 2
+		highValue, _ = npv(high)
+	}
+	if lowValue*highValue > 0 {
+		return 0, false
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		value, _ := npv(mid)
+		if math.Abs(value) < 1e-7 || high-low < 1e-12 {
+			return mid, true
+		}
+		if lowValue*value < 0 {
+			high = mid
+		} else {
+			low, lowValue = mid, value
+		}
+	}
+	return (low + high) / 2, true
+}
+
+// annualize 将 days 天的区间收益率换算为年化收益率。
+func annualize(rate float64, days int) float64 {
+	return math.Pow(1+rate, 365/float64(days)) - 1
+}
+
+// percentRate 将小数收益率转换为保留 2 位小数的百分比。
+func percentRate(rate float64) decimal.Decimal {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return decimal.Zero
+	}
+	return decimal.NewFromFloat(rate * 100).Round(2)
+}
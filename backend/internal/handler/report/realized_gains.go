@@ -0,0 +1,381 @@
+package report
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// gainAmounts 为一组卖出的已实现损益：gain = proceeds - cost，net_gain 再扣除手续费与税费。
+type gainAmounts struct {
+	Proceeds decimal.Decimal `json:"proceeds"`
+	Cost     decimal.Decimal `json:"cost"`
+	Gain     decimal.Decimal `json:"gain"`
+	Fee      decimal.Decimal `json:"fee"`
+	Tax      decimal.Decimal `json:"tax"`
+	NetGain  decimal.Decimal `json:"net_gain"`
+}
+
+func (a gainAmounts) add(b gainAmounts) gainAmounts {
+	return gainAmounts{
+		Proceeds: a.Proceeds.Add(b.Proceeds),
+		Cost:     a.Cost.Add(b.Cost),
+		Gain:     a.Gain.Add(b.Gain),
+		Fee:      a.Fee.Add(b.Fee),
+		Tax:      a.Tax.Add(b.Tax),
+		NetGain:  a.NetGain.Add(b.NetGain),
+	}
+}
+
+// convert 按汇率将各金额折算为目标币种。
+func (a gainAmounts) convert(rate fx.Rate) gainAmounts {
+	return gainAmounts{
+		Proceeds: rate.Apply(a.Proceeds),
+		Cost:     rate.Apply(a.Cost),
+		Gain:     rate.Apply(a.Gain),
+		Fee:      rate.Apply(a.Fee),
+		Tax:      rate.Apply(a.Tax),
+		NetGain:  rate.Apply(a.NetGain),
+	}
+}
+
+type gainAllocation struct {
+	AllocationID uint            `json:"allocation_id"`
+	LotID        uint            `json:"lot_id"`
+	AcquiredOn   string          `json:"acquired_on"`
+	HoldingDays  int             `json:"holding_days"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	CostPrice    decimal.Decimal `json:"cost_price"`
+	Proceeds     decimal.Decimal `json:"proceeds"`
+	Cost         decimal.Decimal `json:"cost"`
+	Gain         decimal.Decimal `json:"gain"`
+}
+
+// gainSale 为单笔卖出，金额以现金账户币种计；exchange_rate 为卖出日折算本位币的汇率，
+// 缺少汇率时为 null 且不计入汇总。
+type gainSale struct {
+	SaleID        uint             `json:"sale_id"`
+	TransactionID uint             `json:"transaction_id"`
+	OccurredOn    string           `json:"occurred_on"`
+	SecurityID    uint             `json:"security_id"`
+	Ticker        string           `json:"ticker"`
+	Name          string           `json:"name"`
+	Currency      string           `json:"currency"`
+	Quantity      decimal.Decimal  `json:"quantity"`
+	Price         decimal.Decimal  `json:"price"`
+	ExchangeRate  *decimal.Decimal `json:"exchange_rate"`
+	RateMissing   bool             `json:"rate_missing"`
+	gainAmounts
+	Allocations []gainAllocation `json:"allocations"`
+}
+
+type gainSecurity struct {
+	SecurityID uint            `json:"security_id"`
+	Ticker     string          `json:"ticker"`
+	Name       string          `json:"name"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	gainAmounts
+}
+
+type gainPeriod struct {
+	Period string `json:"period"`
+	gainAmounts
+}
+
+type realizedGainsResponse struct {
+	LedgerID     int            `json:"ledger_id"`
+	DateFrom     *string        `json:"date_from"`
+	DateTo       *string        `json:"date_to"`
+	GroupBy      string         `json:"group_by"`
+	BaseCurrency string         `json:"base_currency"`
+	Totals       gainAmounts    `json:"totals"`
+	MissingRates []string       `json:"missing_rates"`
+	Sales        []gainSale     `json:"sales"`
+	Securities   []gainSecurity `json:"securities"`
+	Periods      []gainPeriod   `json:"periods"`
+}
+
+type gainRow struct {
+	SaleID        uint            `gorm:"column:sale_id"`
+	TransactionID uint            `gorm:"column:transaction_id"`
+	OccurredOn    time.Time       `gorm:"column:occurred_on"`
+	SecurityID    uint            `gorm:"column:security_id"`
+	Ticker        string          `gorm:"column:ticker"`
+	SecurityName  string          `gorm:"column:security_name"`
+	Currency      string          `gorm:"column:currency"`
+	SaleQuantity  decimal.Decimal `gorm:"column:sale_quantity"`
+	SalePrice     decimal.Decimal `gorm:"column:sale_price"`
+	Fee           decimal.Decimal `gorm:"column:fee"`
+	Tax           decimal.Decimal `gorm:"column:tax"`
+	AllocationID  uint            `gorm:"column:allocation_id"`
+	LotID         uint            `gorm:"column:lot_id"`
+	AcquiredOn    time.Time       `gorm:"column:acquired_on"`
+	Quantity      decimal.Decimal `gorm:"column:quantity"`
+	CostPrice     decimal.Decimal `gorm:"column:cost_price"`
+}
+
+// realizedGains 按卖出、批次匹配、证券与期间汇总已实现损益，手续费与税费单独列示。
+// 证券、期间汇总与合计以本位币计，按卖出日汇率折算。
+func (h Handler) realizedGains(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	var dateFrom, dateTo *time.Time
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		dateFrom = &parsed
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		dateTo = &parsed
+	}
+
+	var securityID uint
+	if value := strings.TrimSpace(c.Query("security_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid security_id"})
+			return
+		}
+		securityID = uint(parsed)
+	}
+
+	groupBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("group_by", "month")))
+	if groupBy != "month" && groupBy != "quarter" && groupBy != "year" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be month, quarter or year"})
+		return
+	}
+
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+
+	rows, err := saleAllocations(h.db, ledgerID, dateFrom, dateTo, securityID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query sales"})
+		return
+	}
+
+	sales := []gainSale{}
+	saleIndex := map[uint]int{}
+	for _, row := range rows {
+		idx, ok := saleIndex[row.SaleID]
+		if !ok {
+			idx = len(sales)
+			saleIndex[row.SaleID] = idx
+			currency := strings.ToUpper(row.Currency)
+			sales = append(sales, gainSale{
+				SaleID:        row.SaleID,
+				TransactionID: row.TransactionID,
+				OccurredOn:    row.OccurredOn.Format("2006-01-02"),
+				SecurityID:    row.SecurityID,
+				Ticker:        row.Ticker,
+				Name:          row.SecurityName,
+				Currency:      currency,
+				Quantity:      row.SaleQuantity,
+				Price:         row.SalePrice,
+				gainAmounts: gainAmounts{
+					Proceeds: money.Round(row.SaleQuantity.Mul(row.SalePrice), currency),
+					Fee:      row.Fee,
+					Tax:      row.Tax,
+				},
+				Allocations: []gainAllocation{},
+			})
+		}
+		sale := &sales[idx]
+
+		proceeds := money.Round(row.Quantity.Mul(row.SalePrice), sale.Currency)
+		cost := money.Round(row.Quantity.Mul(row.CostPrice), sale.Currency)
+		sale.Allocations = append(sale.Allocations, gainAllocation{
+			AllocationID: row.AllocationID,
+			LotID:        row.LotID,
+			AcquiredOn:   row.AcquiredOn.Format("2006-01-02"),
+			HoldingDays:  holdingDays(row.AcquiredOn, row.OccurredOn),
+			Quantity:     row.Quantity,
+			CostPrice:    row.CostPrice,
+			Proceeds:     proceeds,
+			Cost:         cost,
+			Gain:         proceeds.Sub(cost),
+		})
+		// 卖出成本与卖出时一致：先按批次成本价累加，再整体舍入。
+		sale.Cost = sale.Cost.Add(row.Quantity.Mul(row.CostPrice))
+	}
+
+	converters := map[string]*fx.Converter{}
+	missingRates := map[string]struct{}{}
+	var totals gainAmounts
+	var securities []gainSecurity
+	securityIndex := map[uint]int{}
+	var periods []gainPeriod
+	periodIndex := map[string]int{}
+
+	for i := range sales {
+		sale := &sales[i]
+		sale.Cost = money.Round(sale.Cost, sale.Currency)
+		sale.Gain = sale.Proceeds.Sub(sale.Cost)
+		sale.NetGain = sale.Gain.Sub(sale.Fee).Sub(sale.Tax)
+
+		converter, ok := converters[sale.OccurredOn]
+		if !ok {
+			occurredOn, _ := time.ParseInLocation("2006-01-02", sale.OccurredOn, time.Local)
+			converter = fx.NewConverter(h.db, ledgerID, occurredOn)
+			converters[sale.OccurredOn] = converter
+		}
+		rate, found, err := converter.Rate(sale.Currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found {
+			sale.RateMissing = true
+			missingRates[sale.Currency] = struct{}{}
+			continue
+		}
+		sale.ExchangeRate = &rate.Value
+		base := sale.gainAmounts.convert(rate)
+		totals = totals.add(base)
+
+		idx, ok := securityIndex[sale.SecurityID]
+		if !ok {
+			idx = len(securities)
+			securityIndex[sale.SecurityID] = idx
+			securities = append(securities, gainSecurity{SecurityID: sale.SecurityID, Ticker: sale.Ticker, Name: sale.Name})
+		}
+		securities[idx].Quantity = securities[idx].Quantity.Add(sale.Quantity)
+		securities[idx].gainAmounts = securities[idx].gainAmounts.add(base)
+
+		key := periodKey(sale.OccurredOn, groupBy)
+		idx, ok = periodIndex[key]
+		if !ok {
+			idx = len(periods)
+			periodIndex[key] = idx
+			periods = append(periods, gainPeriod{Period: key})
+		}
+		periods[idx].gainAmounts = periods[idx].gainAmounts.add(base)
+	}
+
+	sort.Slice(securities, func(i, j int) bool { return securities[i].Ticker < securities[j].Ticker })
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Period < periods[j].Period })
+	if securities == nil {
+		securities = []gainSecurity{}
+	}
+	if periods == nil {
+		periods = []gainPeriod{}
+	}
+
+	resp := realizedGainsResponse{
+		LedgerID:     ledgerID,
+		GroupBy:      groupBy,
+		BaseCurrency: baseCurrency,
+		Totals:       totals,
+		MissingRates: sortedKeys(missingRates),
+		Sales:        sales,
+		Securities:   securities,
+		Periods:      periods,
+	}
+	if dateFrom != nil {
+		value := dateFrom.Format("2006-01-02")
+		resp.DateFrom = &value
+	}
+	if dateTo != nil {
+		value := dateTo.Format("2006-01-02")
+		resp.DateTo = &value
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// saleAllocations 返回区间内每笔卖出的批次匹配明细，按卖出日期与 id 排序。
+func saleAllocations(db *gorm.DB, ledgerID int, dateFrom, dateTo *time.Time, securityID uint) ([]gainRow, error) {
+	query := `
+SELECT
+  sale.id AS sale_id,
+  st.id AS transaction_id,
+  st.occurred_on,
+  sale.security_id,
+  s.ticker,
+  s.name AS security_name,
+  acc.currency,
+  sale.quantity AS sale_quantity,
+  sale.price AS sale_price,
+  sale.fee,
+  sale.tax,
+  a.id AS allocation_id,
+  l.id AS lot_id,
+  lt.occurred_on AS acquired_on,
+  a.quantity,
+  l.price AS cost_price
+FROM fin_investment_sales sale
+JOIN fin_transaction_lines sl ON sl.id = sale.transaction_line_id AND sl.deleted_at IS NULL
+JOIN fin_transactions st ON st.id = sl.transaction_id AND st.deleted_at IS NULL
+JOIN fin_accounts acc ON acc.id = sl.account_id
+JOIN fin_securities s ON s.id = sale.security_id
+JOIN fin_investment_lot_allocations a ON a.sale_id = sale.id AND a.deleted_at IS NULL
+JOIN fin_investment_lots l ON l.id = a.buy_lot_id
+JOIN fin_transaction_lines ll ON ll.id = l.transaction_line_id
+JOIN fin_transactions lt ON lt.id = ll.transaction_id
+WHERE sale.deleted_at IS NULL AND sale.ledger_id = ?`
+
+	args := []interface{}{ledgerID}
+	if dateFrom != nil {
+		query += " AND st.occurred_on >= ?"
+		args = append(args, *dateFrom)
+	}
+	if dateTo != nil {
+		query += " AND st.occurred_on <= ?"
+		args = append(args, *dateTo)
+	}
+	if securityID != 0 {
+		query += " AND sale.security_id = ?"
+		args = append(args, securityID)
+	}
+	query += " ORDER BY st.occurred_on, sale.id, a.id"
+
+	var rows []gainRow
+	err := db.Raw(query, args...).Scan(&rows).Error
+	return rows, err
+}
+
+// periodKey 将 YYYY-MM-DD 日期归入期间：month 为 YYYY-MM，quarter 为 YYYY-Qn，year 为 YYYY。
+func periodKey(date, groupBy string) string {
+	switch groupBy {
+	case "year":
+		return date[:4]
+	case "quarter":
+		month, _ := strconv.Atoi(date[5:7])
+		return date[:4] + "-Q" + strconv.Itoa((month-1)/3+1)
+	default:
+		return date[:7]
+	}
+}
+
+// holdingDays 返回买入日至卖出日的自然日天数。
+func holdingDays(acquiredOn, soldOn time.Time) int {
+	from := time.Date(acquiredOn.Year(), acquiredOn.Month(), acquiredOn.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(soldOn.Year(), soldOn.Month(), soldOn.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
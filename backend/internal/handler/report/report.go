@@ -2,13 +2,16 @@ package report
 
 import (
 	"net/http"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -19,46 +22,52 @@ type Handler struct {
 func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	h := Handler{db: db}
 	rg.GET("/balance-sheet", h.balanceSheet)
+	rg.GET("/holdings", h.holdings)
+	rg.GET("/realized-gains", h.realizedGains)
+	rg.GET("/investment-income", h.investmentIncome)
+	rg.GET("/investment-performance", h.investmentPerformance)
+	rg.GET("/capital-gains", h.capitalGains)
+	rg.GET("/income-statement", h.incomeStatement)
+	rg.GET("/net-worth", h.netWorth)
 }
 
+// balanceSheetAccount 中 balance 为账户原币余额（负债账户为未偿还金额，还款使其减少），
+// base_balance 为按 as_of 汇率折算的本位币金额；缺少汇率时 rate_missing 为 true，base_balance 为 null 且不计入合计。
 type balanceSheetAccount struct {
-	ID       uint    `json:"id"`
-	Name     string  `json:"name"`
-	Type     string  `json:"type"`
-	Currency string  `json:"currency"`
-	IsActive bool    `json:"is_active"`
-	Balance  float64 `json:"balance"`
+	ID           uint             `json:"id"`
+	Name         string           `json:"name"`
+	Type         string           `json:"type"`
+	Currency     string           `json:"currency"`
+	IsActive     bool             `json:"is_active"`
+	Balance      decimal.Decimal  `json:"balance"`
+	BaseBalance  *decimal.Decimal `json:"base_balance"`
+	ExchangeRate *decimal.Decimal `json:"exchange_rate"`
+	RateMissing  bool             `json:"rate_missing"`
 }
 
+// balanceSheetGroup 的 total 为本位币合计，subtotals 为按币种的原币小计。
 type balanceSheetGroup struct {
-	Key      string                `json:"key"`
-	Label    string                `json:"label"`
-	Total    float64               `json:"total"`
-	Accounts []balanceSheetAccount `json:"accounts"`
+	Key         string                     `json:"key"`
+	Label       string                     `json:"label"`
+	Total       decimal.Decimal            `json:"total"`
+	Subtotals   map[string]decimal.Decimal `json:"subtotals"`
+	RateMissing bool                       `json:"rate_missing"`
+	Accounts    []balanceSheetAccount      `json:"accounts"`
 }
 
 type balanceSheetResponse struct {
-	LedgerID int                 `json:"ledger_id"`
-	AsOf     string              `json:"as_of"`
-	Totals   map[string]float64  `json:"totals"`
-	Groups   []balanceSheetGroup `json:"groups"`
-}
-
-type snapshotRow struct {
-	AccountID uint
-	AsOf      time.Time
-	Amount    float64
+	LedgerID     int                        `json:"ledger_id"`
+	AsOf         string                     `json:"as_of"`
+	BaseCurrency string                     `json:"base_currency"`
+	Totals       map[string]decimal.Decimal `json:"totals"`
+	MissingRates []string                   `json:"missing_rates"`
+	Groups       []balanceSheetGroup        `json:"groups"`
 }
 
 func (h Handler) balanceSheet(c *gin.Context) {
-	ledgerID := 1
-	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
-		parsed, err := strconv.Atoi(value)
-		if err != nil || parsed <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
-			return
-		}
-		ledgerID = parsed
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
 	}
 
 	asOf := time.Now()
@@ -71,66 +80,39 @@ func (h Handler) balanceSheet(c *gin.Context) {
 		asOf = parsed
 	}
 
+	var ledgerRecord model.Ledger
+	if err := h.db.First(&ledgerRecord, ledgerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	baseCurrency := strings.ToUpper(ledgerRecord.BaseCurrency)
+	converter := fx.NewConverter(h.db, ledgerID, asOf)
+
 	var accounts []model.Account
 	if err := h.db.Where("ledger_id = ?", ledgerID).Order("id").Find(&accounts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query accounts"})
 		return
 	}
 
-	var snapshots []snapshotRow
-	query := `
-SELECT s.account_id, s.as_of, SUM(s.amount) AS amount
-FROM fin_account_snapshots s
-JOIN (
-  SELECT account_id, MAX(as_of) AS max_asof
-  FROM fin_account_snapshots
-  WHERE ledger_id = ? AND as_of <= ?
-  GROUP BY account_id
-) latest
-ON s.account_id = latest.account_id AND s.as_of = latest.max_asof
-WHERE s.ledger_id = ?
-GROUP BY s.account_id, s.as_of`
-
-	if err := h.db.Raw(query, ledgerID, asOf, ledgerID).Scan(&snapshots).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query snapshots"})
+	balances, err := accountBalances(h.db, ledgerID, accounts, []time.Time{asOf})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query balances"})
 		return
 	}
 
-	snapshotMap := make(map[uint]snapshotRow, len(snapshots))
-	for _, row := range snapshots {
-		snapshotMap[row.AccountID] = row
-	}
-
 	groups := map[string]*balanceSheetGroup{
-		"asset":     {Key: "asset", Label: "资产"},
-		"liability": {Key: "liability", Label: "负债"},
-		"other":     {Key: "other", Label: "其他"},
+		"asset":     {Key: "asset", Label: "资产", Subtotals: map[string]decimal.Decimal{}},
+		"liability": {Key: "liability", Label: "负债", Subtotals: map[string]decimal.Decimal{}},
+		"other":     {Key: "other", Label: "其他", Subtotals: map[string]decimal.Decimal{}},
 	}
+	missingRates := map[string]struct{}{}
 
-	totalAssets := 0.0
-	totalLiabilities := 0.0
+	totalAssets := decimal.Zero
+	totalLiabilities := decimal.Zero
 
 	for _, account := range accounts {
-		snapshot := snapshotMap[account.ID]
-		sum := 0.0
-
-		tx := h.db.Table("fin_transaction_lines").
-			Joins("JOIN fin_transactions t ON t.id = fin_transaction_lines.transaction_id AND t.deleted_at IS NULL").
-			Where("fin_transaction_lines.account_id = ? AND fin_transaction_lines.ledger_id = ? AND fin_transaction_lines.deleted_at IS NULL", account.ID, ledgerID).
-			Select("COALESCE(SUM(fin_transaction_lines.amount), 0)")
-
-		if !snapshot.AsOf.IsZero() {
-			tx = tx.Where("t.occurred_on > ? AND t.occurred_on <= ?", snapshot.AsOf, asOf)
-		} else {
-			tx = tx.Where("t.occurred_on <= ?", asOf)
-		}
-
-		if err := tx.Scan(&sum).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transactions"})
-			return
-		}
-
-		balance := snapshot.Amount + sum
+		balance := balances[account.ID][0]
+		currency := strings.ToUpper(account.Currency)
 
 		entry := balanceSheetAccount{
 			ID:       account.ID,
@@ -143,23 +125,49 @@ GROUP BY s.account_id, s.as_of`
 
 		groupKey := classifyAccountType(account.Type)
 		group := groups[groupKey]
+		group.Subtotals[currency] = group.Subtotals[currency].Add(balance)
+
+		rate, found, err := converter.Rate(currency, baseCurrency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query exchange rates"})
+			return
+		}
+		if !found {
+			entry.RateMissing = true
+			group.RateMissing = true
+			missingRates[currency] = struct{}{}
+			group.Accounts = append(group.Accounts, entry)
+			continue
+		}
+
+		converted := rate.Apply(balance)
+		entry.BaseBalance = &converted
+		entry.ExchangeRate = &rate.Value
 		group.Accounts = append(group.Accounts, entry)
-		group.Total += balance
+		group.Total = group.Total.Add(converted)
 
 		if groupKey == "asset" {
-			totalAssets += balance
+			totalAssets = totalAssets.Add(converted)
 		} else if groupKey == "liability" {
-			totalLiabilities += balance
+			totalLiabilities = totalLiabilities.Add(converted)
 		}
 	}
 
+	missing := make([]string, 0, len(missingRates))
+	for currency := range missingRates {
+		missing = append(missing, currency)
+	}
+	sort.Strings(missing)
+
 	resp := balanceSheetResponse{
-		LedgerID: ledgerID,
-		AsOf:     asOf.Format("2006-01-02"),
-		Totals: map[string]float64{
+		LedgerID:     ledgerID,
+		AsOf:         asOf.Format("2006-01-02"),
+		BaseCurrency: baseCurrency,
+		MissingRates: missing,
+		Totals: map[string]decimal.Decimal{
 			"assets":      totalAssets,
 			"liabilities": totalLiabilities,
-			"net_worth":   totalAssets - totalLiabilities,
+			"net_worth":   totalAssets.Sub(totalLiabilities),
 		},
 		Groups: []balanceSheetGroup{
 			*groups["asset"],
@@ -0,0 +1,355 @@
+package security
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"finance-backend/internal/model"
+	"finance-backend/internal/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// maxBulkPrices 限制单次批量上传的行数。
+const maxBulkPrices = 5000
+
+type priceRequest struct {
+	PriceAt    string          `json:"price_at" binding:"required"`
+	ClosePrice decimal.Decimal `json:"close_price"`
+}
+
+type bulkPriceRequest struct {
+	Prices []priceRequest `json:"prices" binding:"required,min=1,dive"`
+}
+
+type priceResponse struct {
+	PriceAt    string          `json:"price_at"`
+	ClosePrice decimal.Decimal `json:"close_price"`
+}
+
+// priceSeriesResponse 中 previous 为 date_from 之前最近一次收盘价，便于补齐序列起点。
+type priceSeriesResponse struct {
+	SecurityID uint            `json:"security_id"`
+	Ticker     string          `json:"ticker"`
+	Currency   string          `json:"currency"`
+	Previous   *priceResponse  `json:"previous"`
+	Prices     []priceResponse `json:"prices"`
+}
+
+type bulkResponse struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// upsertPrice 写入单日收盘价，已存在时覆盖；新建返回 201，覆盖返回 200。
+func (h Handler) upsertPrice(c *gin.Context) {
+	var req priceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	security, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	price, err := parsePrice(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := savePrice(h.db, security, price)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save price"})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, newPriceResponse(price))
+}
+
+// bulkUpsertPrices 批量写入收盘价，同一日期已存在时覆盖。
+// 支持 JSON（{"prices": [...]}）或 text/csv 请求体，CSV 列为 price_at,close_price，首行可为表头。
+func (h Handler) bulkUpsertPrices(c *gin.Context) {
+	var requests []priceRequest
+	if c.ContentType() == "text/csv" {
+		parsed, err := readPriceCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		requests = parsed
+	} else {
+		var req bulkPriceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		requests = req.Prices
+	}
+
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no prices to upload"})
+		return
+	}
+	if len(requests) > maxBulkPrices {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many prices, limit is " + strconv.Itoa(maxBulkPrices)})
+		return
+	}
+
+	security, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	prices := make([]model.SecurityPrice, 0, len(requests))
+	for i, item := range requests {
+		price, err := parsePrice(item)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "row " + strconv.Itoa(i+1) + ": " + err.Error()})
+			return
+		}
+		prices = append(prices, price)
+	}
+
+	var resp bulkResponse
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for _, price := range prices {
+			created, err := savePrice(tx, security, price)
+			if err != nil {
+				return err
+			}
+			if created {
+				resp.Created++
+			} else {
+				resp.Updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload prices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// listPrices 返回 date_from ~ date_to（均可选）区间内按日期升序的收盘价序列。
+func (h Handler) listPrices(c *gin.Context) {
+	security, ok := h.load(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	query := h.db.Where("ledger_id = ? AND security_id = ?", security.LedgerID, security.ID)
+
+	var dateFrom time.Time
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		dateFrom = parsed
+		query = query.Where("price_at >= ?", parsed)
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("price_at <= ?", parsed)
+	}
+
+	var prices []model.SecurityPrice
+	if err := query.Order("price_at").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query prices"})
+		return
+	}
+
+	resp := priceSeriesResponse{
+		SecurityID: security.ID,
+		Ticker:     security.Ticker,
+		Currency:   security.Currency,
+		Prices:     make([]priceResponse, 0, len(prices)),
+	}
+	for _, price := range prices {
+		resp.Prices = append(resp.Prices, newPriceResponse(price))
+	}
+
+	if !dateFrom.IsZero() {
+		previous, found, err := lastPrice(h.db, security, dateFrom.AddDate(0, 0, -1))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query prices"})
+			return
+		}
+		if found {
+			item := newPriceResponse(previous)
+			resp.Previous = &item
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// latestPrice 返回 as_of（默认今天）当日或之前最近一次收盘价。
+func (h Handler) latestPrice(c *gin.Context) {
+	security, ok := h.load(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	asOf := time.Now()
+	if value := strings.TrimSpace(c.Query("as_of")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be YYYY-MM-DD"})
+			return
+		}
+		asOf = parsed
+	}
+
+	price, found, err := lastPrice(h.db, security, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query prices"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no price on or before as_of"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newPriceResponse(price))
+}
+
+func (h Handler) deletePrice(c *gin.Context) {
+	priceAt, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(c.Param("date")), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	security, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	tx := h.db.Where("ledger_id = ? AND security_id = ? AND price_at = ?", security.LedgerID, security.ID, priceAt).
+		Delete(&model.SecurityPrice{})
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete price"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "price not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// savePrice 按 (账本, 证券, 日期) 写入收盘价。主键包含日期，已软删除的同日记录会被恢复并覆盖；
+// created 表示此前没有有效记录。
+func savePrice(tx *gorm.DB, security model.Security, price model.SecurityPrice) (bool, error) {
+	price.LedgerID = security.LedgerID
+	price.SecurityID = security.ID
+
+	var existing model.SecurityPrice
+	err := tx.Unscoped().
+		Where("ledger_id = ? AND security_id = ? AND price_at = ?", price.LedgerID, price.SecurityID, price.PriceAt).
+		First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, tx.Create(&price).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Unscoped().Model(&model.SecurityPrice{}).
+		Where("ledger_id = ? AND security_id = ? AND price_at = ?", price.LedgerID, price.SecurityID, price.PriceAt).
+		Updates(map[string]interface{}{"close_price": price.ClosePrice, "deleted_at": nil}).Error; err != nil {
+		return false, err
+	}
+	return existing.DeletedAt.Valid, nil
+}
+
+// lastPrice 返回 asOf 当日或之前最近一次收盘价。
+func lastPrice(db *gorm.DB, security model.Security, asOf time.Time) (model.SecurityPrice, bool, error) {
+	var prices []model.SecurityPrice
+	if err := db.Where("ledger_id = ? AND security_id = ? AND price_at <= ?", security.LedgerID, security.ID, asOf).
+		Order("price_at desc").
+		Limit(1).
+		Find(&prices).Error; err != nil {
+		return model.SecurityPrice{}, false, err
+	}
+	if len(prices) == 0 {
+		return model.SecurityPrice{}, false, nil
+	}
+	return prices[0], true, nil
+}
+
+func parsePrice(req priceRequest) (model.SecurityPrice, error) {
+	priceAt, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.PriceAt), time.Local)
+	if err != nil {
+		return model.SecurityPrice{}, errors.New("price_at must be YYYY-MM-DD")
+	}
+	closePrice := money.Price(req.ClosePrice)
+	if !closePrice.IsPositive() {
+		return model.SecurityPrice{}, errors.New("close_price must be greater than 0")
+	}
+	return model.SecurityPrice{PriceAt: priceAt, ClosePrice: closePrice}, nil
+}
+
+func newPriceResponse(price model.SecurityPrice) priceResponse {
+	return priceResponse{
+		PriceAt:    price.PriceAt.Format("2006-01-02"),
+		ClosePrice: price.ClosePrice,
+	}
+}
+
+// readPriceCSV 解析 price_at,close_price 两列，首行为表头（以 price_at 开头）时跳过。
+func readPriceCSV(body io.Reader) ([]priceRequest, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var requests []priceRequest
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("invalid csv: " + err.Error())
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "price_at") {
+			continue
+		}
+		closePrice, err := decimal.NewFromString(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + ": invalid close_price")
+		}
+		requests = append(requests, priceRequest{
+			PriceAt:    record[0],
+			ClosePrice: closePrice,
+		})
+		if len(requests) > maxBulkPrices {
+			break
+		}
+	}
+	return requests, nil
+}
@@ -0,0 +1,374 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"finance-backend/internal/handler/ledger"
+	"finance-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	db *gorm.DB
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	h := Handler{db: db}
+
+	rg.POST("", h.create)
+	rg.GET("", h.list)
+	rg.GET("/:id", h.get)
+	rg.PATCH("/:id", h.update)
+	rg.DELETE("/:id", h.delete)
+
+	rg.POST("/:id/prices", h.upsertPrice)
+	rg.POST("/:id/prices/bulk", h.bulkUpsertPrices)
+	rg.GET("/:id/prices", h.listPrices)
+	rg.GET("/:id/prices/latest", h.latestPrice)
+	rg.DELETE("/:id/prices/:date", h.deletePrice)
+}
+
+// assetClasses 允许的资产类别。
+var assetClasses = map[string]struct{}{
+	"stock":        {},
+	"etf":          {},
+	"fund":         {},
+	"bond":         {},
+	"money_market": {},
+	"crypto":       {},
+	"commodity":    {},
+	"other":        {},
+}
+
+type createSecurityRequest struct {
+	LedgerID   *int   `json:"ledger_id"`
+	Ticker     string `json:"ticker" binding:"required"`
+	Name       string `json:"name" binding:"required"`
+	Currency   string `json:"currency"`
+	AssetClass string `json:"asset_class"`
+	Exchange   string `json:"exchange"`
+}
+
+type updateSecurityRequest struct {
+	Ticker     *string `json:"ticker"`
+	Name       *string `json:"name"`
+	Currency   *string `json:"currency"`
+	AssetClass *string `json:"asset_class"`
+	Exchange   *string `json:"exchange"`
+}
+
+func (h Handler) create(c *gin.Context) {
+	var req createSecurityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	ticker := normalizeTicker(req.Ticker)
+	name := strings.TrimSpace(req.Name)
+	if ticker == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ticker and name are required"})
+		return
+	}
+
+	currency := "CNY"
+	if strings.TrimSpace(req.Currency) != "" {
+		currency, ok = normalizeCurrency(req.Currency)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+			return
+		}
+	}
+
+	assetClass := "other"
+	if strings.TrimSpace(req.AssetClass) != "" {
+		assetClass, ok = normalizeAssetClass(req.AssetClass)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "asset_class must be one of: " + assetClassList()})
+			return
+		}
+	}
+
+	taken, err := h.tickerTaken(ledgerID, ticker, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query securities"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "ticker already exists"})
+		return
+	}
+
+	security := model.Security{
+		LedgerID:   ledgerID,
+		Ticker:     ticker,
+		Name:       name,
+		Currency:   currency,
+		AssetClass: assetClass,
+		Exchange:   normalizeExchange(req.Exchange),
+	}
+	if err := h.db.Create(&security).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create security"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, security)
+}
+
+func (h Handler) list(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	query := h.db.Where("ledger_id = ?", ledgerID)
+	if value := strings.TrimSpace(c.Query("asset_class")); value != "" {
+		assetClass, ok := normalizeAssetClass(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset_class"})
+			return
+		}
+		query = query.Where("asset_class = ?", assetClass)
+	}
+	if value := strings.TrimSpace(c.Query("q")); value != "" {
+		pattern := "%" + strings.ToLower(value) + "%"
+		query = query.Where("(LOWER(ticker) LIKE ? OR LOWER(name) LIKE ?)", pattern, pattern)
+	}
+
+	var securities []model.Security
+	if err := query.Order("ticker").Find(&securities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query securities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, securities)
+}
+
+func (h Handler) get(c *gin.Context) {
+	security, ok := h.load(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, security)
+}
+
+func (h Handler) update(c *gin.Context) {
+	var req updateSecurityRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var raw map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(raw) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	security, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	if _, ok := raw["ticker"]; ok {
+		if req.Ticker == nil || normalizeTicker(*req.Ticker) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ticker cannot be empty"})
+			return
+		}
+		ticker := normalizeTicker(*req.Ticker)
+		taken, err := h.tickerTaken(security.LedgerID, ticker, security.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query securities"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "ticker already exists"})
+			return
+		}
+		security.Ticker = ticker
+	}
+
+	if _, ok := raw["name"]; ok {
+		if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		security.Name = strings.TrimSpace(*req.Name)
+	}
+
+	if _, ok := raw["currency"]; ok {
+		if req.Currency == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency cannot be null"})
+			return
+		}
+		currency, ok := normalizeCurrency(*req.Currency)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter currency code"})
+			return
+		}
+		security.Currency = currency
+	}
+
+	if _, ok := raw["asset_class"]; ok {
+		if req.AssetClass == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "asset_class cannot be null"})
+			return
+		}
+		assetClass, ok := normalizeAssetClass(*req.AssetClass)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "asset_class must be one of: " + assetClassList()})
+			return
+		}
+		security.AssetClass = assetClass
+	}
+
+	if _, ok := raw["exchange"]; ok {
+		if req.Exchange == nil {
+			security.Exchange = ""
+		} else {
+			security.Exchange = normalizeExchange(*req.Exchange)
+		}
+	}
+
+	if err := h.db.Save(&security).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update security"})
+		return
+	}
+
+	c.JSON(http.StatusOK, security)
+}
+
+// delete 删除证券及其价格；已有买入批次、卖出、分红或公司行动记录的证券不可删除。
+func (h Handler) delete(c *gin.Context) {
+	security, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	var lots, sales, dividends, actions int64
+	if err := h.db.Model(&model.InvestmentLot{}).Where("security_id = ?", security.ID).Count(&lots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query investment lots"})
+		return
+	}
+	if err := h.db.Model(&model.InvestmentSale{}).Where("security_id = ?", security.ID).Count(&sales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query investment sales"})
+		return
+	}
+	if err := h.db.Model(&model.InvestmentDividend{}).Where("security_id = ?", security.ID).Count(&dividends).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query investment dividends"})
+		return
+	}
+	if err := h.db.Model(&model.CorporateAction{}).Where("security_id = ? OR target_security_id = ?", security.ID, security.ID).Count(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query corporate actions"})
+		return
+	}
+	if lots > 0 || sales > 0 || dividends > 0 || actions > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "security has investment records"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ledger_id = ? AND security_id = ?", security.LedgerID, security.ID).Delete(&model.SecurityPrice{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Security{}, security.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete security"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// load 按路径 id 读取证券并校验账本权限，失败时已写入响应。
+func (h Handler) load(c *gin.Context, role model.LedgerRole) (model.Security, bool) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return model.Security{}, false
+	}
+
+	var security model.Security
+	err := h.db.First(&security, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "security not found"})
+		return model.Security{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load security"})
+		return model.Security{}, false
+	}
+
+	if !ledger.Check(c, h.db, security.LedgerID, role) {
+		return model.Security{}, false
+	}
+	return security, true
+}
+
+// tickerTaken 检查代码是否已被同一账本的其他证券占用。(ledger_id, ticker) 唯一，
+// 已删除的证券仍占用代码，因此包含软删除记录。
+func (h Handler) tickerTaken(ledgerID int, ticker string, excludeID uint) (bool, error) {
+	var count int64
+	err := h.db.Unscoped().Model(&model.Security{}).
+		Where("ledger_id = ? AND ticker = ? AND id <> ?", ledgerID, ticker, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func parseID(raw string) (uint, bool) {
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
+
+func normalizeTicker(input string) string {
+	return strings.ToUpper(strings.TrimSpace(input))
+}
+
+func normalizeExchange(input string) string {
+	return strings.ToUpper(strings.TrimSpace(input))
+}
+
+func normalizeAssetClass(input string) (string, bool) {
+	value := strings.ToLower(strings.TrimSpace(input))
+	_, ok := assetClasses[value]
+	return value, ok
+}
+
+func assetClassList() string {
+	return "stock, etf, fund, bond, money_market, crypto, commodity, other"
+}
+
+// normalizeCurrency 将币种代码去空格、转大写，并检查是否为 3 位字母。
+func normalizeCurrency(input string) (string, bool) {
+	value := strings.ToUpper(strings.TrimSpace(input))
+	if len(value) != 3 {
+		return "", false
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return value, true
+}
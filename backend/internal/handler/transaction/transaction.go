@@ -7,9 +7,12 @@ import (
 	"strings"
 	"time"
 
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
+	"finance-backend/internal/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -27,58 +30,96 @@ func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	rg.DELETE("/:id", h.delete)
 }
 
+const (
+	viewSplit       = "split"
+	viewTransaction = "transaction"
+)
+
+// createTransactionRequest 二选一：category_id + amount 记一笔单分类收支，
+// 或 splits 把同一账户的一笔交易拆到多个分类。
 type createTransactionRequest struct {
-	LedgerID    *int    `json:"ledger_id"`
-	OccurredOn  string  `json:"occurred_on" binding:"required"`
-	AccountID   uint    `json:"account_id" binding:"required,gt=0"`
-	CategoryID  int     `json:"category_id" binding:"required,gt=0"`
-	Amount      float64 `json:"amount" binding:"required"`
-	Description string  `json:"description"`
-	Note        string  `json:"note"`
+	LedgerID    *int            `json:"ledger_id"`
+	OccurredOn  string          `json:"occurred_on" binding:"required"`
+	AccountID   uint            `json:"account_id" binding:"required,gt=0"`
+	CategoryID  int             `json:"category_id" binding:"omitempty,gt=0"`
+	Amount      decimal.Decimal `json:"amount"`
+	Splits      []splitRequest  `json:"splits" binding:"omitempty,dive"`
+	Description string          `json:"description"`
+	Note        string          `json:"note"`
 }
 
 type updateTransactionRequest struct {
-	OccurredOn  *string  `json:"occurred_on"`
-	AccountID   *uint    `json:"account_id"`
-	CategoryID  *int     `json:"category_id"`
-	Amount      *float64 `json:"amount"`
-	Description *string  `json:"description"`
-	Note        *string  `json:"note"`
+	OccurredOn  *string          `json:"occurred_on"`
+	AccountID   *uint            `json:"account_id"`
+	CategoryID  *int             `json:"category_id"`
+	Amount      *decimal.Decimal `json:"amount"`
+	Splits      *[]splitRequest  `json:"splits" binding:"omitempty,dive"`
+	Description *string          `json:"description"`
+	Note        *string          `json:"note"`
+}
+
+type splitRequest struct {
+	CategoryID int             `json:"category_id" binding:"required,gt=0"`
+	Amount     decimal.Decimal `json:"amount"`
+	Note       string          `json:"note"`
+}
+
+type split struct {
+	category model.Category
+	amount   decimal.Decimal
+	note     string
 }
 
 type transactionRow struct {
-	TransactionID uint      `json:"transaction_id"`
-	LineID        uint      `json:"line_id"`
-	OccurredOn    time.Time `json:"occurred_on"`
-	AccountID     uint      `json:"account_id"`
-	AccountName   string    `json:"account_name"`
-	CategoryID    int       `json:"category_id"`
-	CategoryName  string    `json:"category_name"`
-	CategoryKind  string    `json:"category_kind"`
-	Amount        float64   `json:"amount"`
-	Description   string    `json:"description"`
-	Note          string    `json:"note"`
-	CreatedAt     time.Time `json:"created_at"`
+	LedgerID      int             `json:"ledger_id"`
+	TransactionID uint            `json:"transaction_id"`
+	LineID        uint            `json:"line_id"`
+	OccurredOn    time.Time       `json:"occurred_on"`
+	AccountID     uint            `json:"account_id"`
+	AccountName   string          `json:"account_name"`
+	CategoryID    int             `json:"category_id"`
+	CategoryName  string          `json:"category_name"`
+	CategoryKind  string          `json:"category_kind"`
+	Amount        decimal.Decimal `json:"amount"`
+	LineNote      string          `json:"line_note"`
+	SplitCount    int             `json:"split_count"`
+	Description   string          `json:"description"`
+	Note          string          `json:"note"`
+	CreatedAt     time.Time       `json:"created_at"`
 }
 
 type listResponse struct {
-	Data  []transactionRowResponse `json:"data"`
-	Total int64                    `json:"total"`
+	Data        []transactionRowResponse `json:"data"`
+	Total       int64                    `json:"total"`
+	TotalAmount decimal.Decimal          `json:"total_amount"`
+}
+
+type splitResponse struct {
+	LineID       uint            `json:"line_id"`
+	CategoryID   int             `json:"category_id"`
+	CategoryName string          `json:"category_name"`
+	CategoryKind string          `json:"category_kind"`
+	Amount       decimal.Decimal `json:"amount"`
+	Note         string          `json:"note"`
 }
 
 type transactionRowResponse struct {
-	TransactionID uint    `json:"transaction_id"`
-	LineID        uint    `json:"line_id"`
-	OccurredOn    string  `json:"occurred_on"`
-	AccountID     uint    `json:"account_id"`
-	AccountName   string  `json:"account_name"`
-	CategoryID    int     `json:"category_id"`
-	CategoryName  string  `json:"category_name"`
-	CategoryKind  string  `json:"category_kind"`
-	Amount        float64 `json:"amount"`
-	Description   string  `json:"description"`
-	Note          string  `json:"note"`
-	CreatedAt     string  `json:"created_at"`
+	TransactionID uint            `json:"transaction_id"`
+	LineID        uint            `json:"line_id"`
+	OccurredOn    string          `json:"occurred_on"`
+	AccountID     uint            `json:"account_id"`
+	AccountName   string          `json:"account_name"`
+	CategoryID    int             `json:"category_id"`
+	CategoryName  string          `json:"category_name"`
+	CategoryKind  string          `json:"category_kind"`
+	Amount        decimal.Decimal `json:"amount"`
+	Description   string          `json:"description"`
+	Note          string          `json:"note"`
+	CreatedAt     string          `json:"created_at"`
+	// 交易视图与详情中，拆分交易的分类字段为空，金额为各拆分之和。
+	LineNote   string          `json:"line_note,omitempty"`
+	SplitCount int             `json:"split_count,omitempty"`
+	Splits     []splitResponse `json:"splits,omitempty"`
 }
 
 func (h Handler) create(c *gin.Context) {
@@ -88,8 +129,8 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	ledgerID := normalizeLedgerID(req.LedgerID, c)
-	if ledgerID == 0 {
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
 		return
 	}
 
@@ -107,16 +148,12 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	category, ok := validateCategory(h.db, ledgerID, req.CategoryID, c)
+	splits, ok := resolveSplits(h.db, ledgerID, account.Currency, req.CategoryID, req.Amount, req.Splits, c)
 	if !ok {
 		return
 	}
 
-	if !validateAmount(category.Kind, req.Amount, c) {
-		return
-	}
-
-	var response transactionRowResponse
+	var txID uint
 
 	err := h.db.Transaction(func(tx *gorm.DB) error {
 		txRecord := model.Transaction{
@@ -129,31 +166,11 @@ func (h Handler) create(c *gin.Context) {
 			return err
 		}
 
-		line := model.TransactionLine{
-			LedgerID:      ledgerID,
-			TransactionID: txRecord.ID,
-			AccountID:     req.AccountID,
-			CategoryID:    &req.CategoryID,
-			Amount:        req.Amount,
-		}
-		if err := tx.Create(&line).Error; err != nil {
+		if err := createSplitLines(tx, txRecord, account.ID, splits); err != nil {
 			return err
 		}
 
-		response = transactionRowResponse{
-			TransactionID: txRecord.ID,
-			LineID:        line.ID,
-			OccurredOn:    txRecord.OccurredOn.Format("2006-01-02"),
-			AccountID:     account.ID,
-			AccountName:   account.Name,
-			CategoryID:    category.ID,
-			CategoryName:  category.Name,
-			CategoryKind:  string(category.Kind),
-			Amount:        line.Amount,
-			Description:   txRecord.Description,
-			Note:          txRecord.Note,
-			CreatedAt:     txRecord.CreatedAt.Format(time.RFC3339),
-		}
+		txID = txRecord.ID
 		return nil
 	})
 
@@ -162,18 +179,19 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
+	response, err := loadTransaction(h.db, txID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transaction"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, response)
 }
 
 func (h Handler) list(c *gin.Context) {
-	ledgerID := 1
-	if value := strings.TrimSpace(c.Query("ledger_id")); value != "" {
-		parsed, err := strconv.Atoi(value)
-		if err != nil || parsed <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ledger_id"})
-			return
-		}
-		ledgerID = parsed
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
 	}
 
 	var (
@@ -199,6 +217,15 @@ func (h Handler) list(c *gin.Context) {
 		categoryID = parsed
 	}
 
+	view := strings.TrimSpace(strings.ToLower(c.Query("view")))
+	if view == "" {
+		view = viewSplit
+	}
+	if view != viewSplit && view != viewTransaction {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view must be transaction or split"})
+		return
+	}
+
 	kind := strings.TrimSpace(strings.ToLower(c.Query("kind")))
 	if kind != "" && kind != "income" && kind != "expense" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be income or expense"})
@@ -241,6 +268,20 @@ func (h Handler) list(c *gin.Context) {
 		base = base.Where("t.occurred_on <= ?", dateTo)
 	}
 
+	base = base.Session(&gorm.Session{})
+
+	// total_amount 按过滤后的拆分行求和，两种视图下一致。
+	var totalAmount decimal.Decimal
+	if err := base.Select("COALESCE(SUM(tl.amount), 0)").Scan(&totalAmount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sum transactions"})
+		return
+	}
+
+	if view == viewTransaction {
+		h.listByTransaction(c, base, page, pageSize, totalAmount)
+		return
+	}
+
 	var total int64
 	if err := base.Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count transactions"})
@@ -258,11 +299,12 @@ func (h Handler) list(c *gin.Context) {
     c.name AS category_name,
     c.kind AS category_kind,
     tl.amount,
+    tl.note AS line_note,
     t.description,
     t.note,
     t.created_at
   `).
-		Order("t.occurred_on desc, t.id desc").
+		Order("t.occurred_on desc, t.id desc, tl.id").
 		Limit(pageSize).
 		Offset(offset).
 		Scan(&rows).Error; err != nil {
@@ -285,10 +327,76 @@ func (h Handler) list(c *gin.Context) {
 			Description:   row.Description,
 			Note:          row.Note,
 			CreatedAt:     row.CreatedAt.Format(time.RFC3339),
+			LineNote:      row.LineNote,
 		})
 	}
 
-	c.JSON(http.StatusOK, listResponse{Data: resp, Total: total})
+	c.JSON(http.StatusOK, listResponse{Data: resp, Total: total, TotalAmount: totalAmount})
+}
+
+// listByTransaction 按交易（及账户）聚合拆分行；带分类过滤时金额只包含命中的拆分。
+func (h Handler) listByTransaction(c *gin.Context, base *gorm.DB, page, pageSize int, totalAmount decimal.Decimal) {
+	offset := (page - 1) * pageSize
+	grouped := base.Group("t.id, tl.account_id, a.name, t.occurred_on, t.description, t.note, t.created_at").
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := h.db.Table("(?) AS g", grouped.Select("t.id, tl.account_id")).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count transactions"})
+		return
+	}
+
+	var rows []transactionRow
+	if err := grouped.Select(`
+    t.id AS transaction_id,
+    t.occurred_on,
+    tl.account_id,
+    a.name AS account_name,
+    MIN(c.id) AS category_id,
+    MIN(c.name) AS category_name,
+    MIN(c.kind) AS category_kind,
+    MIN(tl.id) AS line_id,
+    COUNT(*) AS split_count,
+    SUM(tl.amount) AS amount,
+    t.description,
+    t.note,
+    t.created_at
+  `).
+		Order("t.occurred_on desc, t.id desc, tl.account_id").
+		Limit(pageSize).
+		Offset(offset).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transactions"})
+		return
+	}
+
+	resp := make([]transactionRowResponse, 0, len(rows))
+	for _, row := range rows {
+		item := transactionRowResponse{
+			TransactionID: row.TransactionID,
+			LineID:        row.LineID,
+			OccurredOn:    row.OccurredOn.Format("2006-01-02"),
+			AccountID:     row.AccountID,
+			AccountName:   row.AccountName,
+			CategoryID:    row.CategoryID,
+			CategoryName:  row.CategoryName,
+			CategoryKind:  row.CategoryKind,
+			Amount:        row.Amount,
+			Description:   row.Description,
+			Note:          row.Note,
+			CreatedAt:     row.CreatedAt.Format(time.RFC3339),
+			SplitCount:    row.SplitCount,
+		}
+		if row.SplitCount > 1 {
+			item.LineID = 0
+			item.CategoryID = 0
+			item.CategoryName = ""
+			item.CategoryKind = ""
+		}
+		resp = append(resp, item)
+	}
+
+	c.JSON(http.StatusOK, listResponse{Data: resp, Total: total, TotalAmount: totalAmount})
 }
 
 func (h Handler) get(c *gin.Context) {
@@ -298,27 +406,21 @@ func (h Handler) get(c *gin.Context) {
 		return
 	}
 
-	var row transactionRow
-	err := h.db.Table("fin_transaction_lines tl").
-		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
-		Joins("JOIN fin_accounts a ON a.id = tl.account_id AND a.deleted_at IS NULL").
-		Joins("JOIN fin_categories c ON c.id = tl.category_id AND c.deleted_at IS NULL").
-		Where("t.id = ? AND tl.deleted_at IS NULL", id).
-		Select(`
-      t.id AS transaction_id,
-      tl.id AS line_id,
-      t.occurred_on,
-      a.id AS account_id,
-      a.name AS account_name,
-      c.id AS category_id,
-      c.name AS category_name,
-      c.kind AS category_kind,
-      tl.amount,
-      t.description,
-      t.note,
-      t.created_at
-    `).
-		First(&row).Error
+	var txRecord model.Transaction
+	if err := h.db.First(&txRecord, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transaction"})
+		return
+	}
+
+	if !ledger.Check(c, h.db, txRecord.LedgerID, model.LedgerRoleViewer) {
+		return
+	}
+
+	resp, err := loadTransaction(h.db, txRecord.ID)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
 		return
@@ -328,20 +430,6 @@ func (h Handler) get(c *gin.Context) {
 		return
 	}
 
-	resp := transactionRowResponse{
-		TransactionID: row.TransactionID,
-		LineID:        row.LineID,
-		OccurredOn:    row.OccurredOn.Format("2006-01-02"),
-		AccountID:     row.AccountID,
-		AccountName:   row.AccountName,
-		CategoryID:    row.CategoryID,
-		CategoryName:  row.CategoryName,
-		CategoryKind:  row.CategoryKind,
-		Amount:        row.Amount,
-		Description:   row.Description,
-		Note:          row.Note,
-		CreatedAt:     row.CreatedAt.Format(time.RFC3339),
-	}
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -368,9 +456,14 @@ func (h Handler) update(c *gin.Context) {
 		return
 	}
 
-	var line model.TransactionLine
+	if !ledger.Check(c, h.db, txRecord.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
+	var lines []model.TransactionLine
 	if err := h.db.Where("transaction_id = ? AND ledger_id = ?", txRecord.ID, txRecord.LedgerID).
-		First(&line).Error; err != nil {
+		Order("id").
+		Find(&lines).Error; err != nil || len(lines) == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transaction line"})
 		return
 	}
@@ -391,18 +484,86 @@ func (h Handler) update(c *gin.Context) {
 		txRecord.Note = strings.TrimSpace(*req.Note)
 	}
 
+	// 转账、投资与跨账户的交易须通过各自的接口修改；在写入事务内检查，避免检查与写入之间被并发修改。
+	ensureEditable := func(tx *gorm.DB) error {
+		if err := ensureStandalone(tx, txRecord.ID, "edited"); err != nil {
+			return err
+		}
+		for _, line := range lines[1:] {
+			if line.AccountID != lines[0].AccountID {
+				return newRequestError("transaction spans multiple accounts, use /api/journal-entries")
+			}
+		}
+		return nil
+	}
+
+	accountID := lines[0].AccountID
 	if req.AccountID != nil {
-		account, ok := validateAccount(h.db, ledgerID, *req.AccountID, c)
+		accountID = *req.AccountID
+	}
+	account, ok := validateAccount(h.db, ledgerID, accountID, c)
+	if !ok {
+		return
+	}
+	if req.AccountID != nil && !account.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account is inactive"})
+		return
+	}
+	// 原金额按原账户币种记录，换到其他币种的账户时须同时提供新币种的金额或拆分。
+	if accountID != lines[0].AccountID && req.Splits == nil && req.Amount == nil {
+		current, ok := validateAccount(h.db, ledgerID, lines[0].AccountID, c)
 		if !ok {
 			return
 		}
-		if !account.IsActive {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "account is inactive"})
+		if !strings.EqualFold(current.Currency, account.Currency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "account currency differs, provide amount or splits in the new currency"})
 			return
 		}
-		line.AccountID = *req.AccountID
 	}
 
+	// 传入 splits 时整体替换全部拆分行。
+	if req.Splits != nil {
+		if req.CategoryID != nil || req.Amount != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either splits or category_id/amount"})
+			return
+		}
+		splits, ok := resolveSplits(h.db, ledgerID, account.Currency, 0, decimal.Zero, *req.Splits, c)
+		if !ok {
+			return
+		}
+
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := ensureEditable(tx); err != nil {
+				return err
+			}
+			if err := tx.Save(&txRecord).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.TransactionLine{}, "transaction_id = ?", txRecord.ID).Error; err != nil {
+				return err
+			}
+			return createSplitLines(tx, txRecord, accountID, splits)
+		})
+		if err != nil {
+			var reqErr requestError
+			if errors.As(err, &reqErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+
+	if len(lines) > 1 && (req.CategoryID != nil || req.Amount != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transaction has multiple splits, update splits instead"})
+		return
+	}
+
+	line := &lines[0]
 	var category model.Category
 	if req.CategoryID != nil {
 		var ok bool
@@ -421,10 +582,11 @@ func (h Handler) update(c *gin.Context) {
 	}
 
 	if req.Amount != nil {
-		if !validateAmount(category.Kind, *req.Amount, c) {
+		amount := money.Round(*req.Amount, account.Currency)
+		if !validateAmount(category.Kind, amount, c) {
 			return
 		}
-		line.Amount = *req.Amount
+		line.Amount = amount
 	} else if req.CategoryID != nil {
 		if !validateAmount(category.Kind, line.Amount, c) {
 			return
@@ -432,15 +594,26 @@ func (h Handler) update(c *gin.Context) {
 	}
 
 	err := h.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(&txRecord).Error; err != nil {
+		if err := ensureEditable(tx); err != nil {
 			return err
 		}
-		if err := tx.Save(&line).Error; err != nil {
+		if err := tx.Save(&txRecord).Error; err != nil {
 			return err
 		}
+		for i := range lines {
+			lines[i].AccountID = accountID
+			if err := tx.Save(&lines[i]).Error; err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transaction"})
 		return
 	}
@@ -455,7 +628,24 @@ func (h Handler) delete(c *gin.Context) {
 		return
 	}
 
+	var txRecord model.Transaction
+	if err := h.db.First(&txRecord, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transaction"})
+		return
+	}
+
+	if !ledger.Check(c, h.db, txRecord.LedgerID, model.LedgerRoleEditor) {
+		return
+	}
+
 	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureStandalone(tx, txRecord.ID, "deleted"); err != nil {
+			return err
+		}
 		var lines []model.TransactionLine
 		if err := tx.Where("transaction_id = ?", id).Find(&lines).Error; err != nil {
 			return err
@@ -477,6 +667,11 @@ func (h Handler) delete(c *gin.Context) {
 		return
 	}
 	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete transaction"})
 		return
 	}
@@ -484,16 +679,121 @@ func (h Handler) delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func normalizeLedgerID(value *int, c *gin.Context) int {
-	ledgerID := 1
-	if value != nil {
-		if *value <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return 0
+// resolveSplits 把单分类写法（categoryID + amount）或 splits 统一校验为拆分列表，
+// 金额按账户币种的最小单位舍入。
+func resolveSplits(db *gorm.DB, ledgerID int, currency string, categoryID int, amount decimal.Decimal, reqSplits []splitRequest, c *gin.Context) ([]split, bool) {
+	if len(reqSplits) == 0 {
+		if categoryID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category_id or splits is required"})
+			return nil, false
+		}
+		reqSplits = []splitRequest{{CategoryID: categoryID, Amount: amount}}
+	} else if categoryID != 0 || !amount.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use either splits or category_id/amount"})
+		return nil, false
+	}
+
+	splits := make([]split, 0, len(reqSplits))
+	for _, item := range reqSplits {
+		category, ok := validateCategory(db, ledgerID, item.CategoryID, c)
+		if !ok {
+			return nil, false
+		}
+		itemAmount := money.Round(item.Amount, currency)
+		if !validateAmount(category.Kind, itemAmount, c) {
+			return nil, false
+		}
+		splits = append(splits, split{category: category, amount: itemAmount, note: strings.TrimSpace(item.Note)})
+	}
+	return splits, true
+}
+
+func createSplitLines(tx *gorm.DB, txRecord model.Transaction, accountID uint, splits []split) error {
+	for _, item := range splits {
+		categoryID := item.category.ID
+		line := model.TransactionLine{
+			LedgerID:      txRecord.LedgerID,
+			TransactionID: txRecord.ID,
+			AccountID:     accountID,
+			CategoryID:    &categoryID,
+			Amount:        item.amount,
+			Note:          item.note,
+		}
+		if err := tx.Create(&line).Error; err != nil {
+			return err
 		}
-		ledgerID = *value
 	}
-	return ledgerID
+	return nil
+}
+
+// loadTransaction 读取交易的全部收支拆分行；单一拆分时分类字段与旧接口一致。
+func loadTransaction(db *gorm.DB, id uint) (transactionRowResponse, error) {
+	var rows []transactionRow
+	err := db.Table("fin_transaction_lines tl").
+		Joins("JOIN fin_transactions t ON t.id = tl.transaction_id AND t.deleted_at IS NULL").
+		Joins("JOIN fin_accounts a ON a.id = tl.account_id AND a.deleted_at IS NULL").
+		Joins("JOIN fin_categories c ON c.id = tl.category_id AND c.deleted_at IS NULL").
+		Where("t.id = ? AND tl.deleted_at IS NULL", id).
+		Select(`
+      t.ledger_id,
+      t.id AS transaction_id,
+      tl.id AS line_id,
+      t.occurred_on,
+      a.id AS account_id,
+      a.name AS account_name,
+      c.id AS category_id,
+      c.name AS category_name,
+      c.kind AS category_kind,
+      tl.amount,
+      tl.note AS line_note,
+      t.description,
+      t.note,
+      t.created_at
+    `).
+		Order("tl.id").
+		Scan(&rows).Error
+	if err != nil {
+		return transactionRowResponse{}, err
+	}
+	if len(rows) == 0 {
+		return transactionRowResponse{}, gorm.ErrRecordNotFound
+	}
+
+	first := rows[0]
+	resp := transactionRowResponse{
+		TransactionID: first.TransactionID,
+		LineID:        first.LineID,
+		OccurredOn:    first.OccurredOn.Format("2006-01-02"),
+		AccountID:     first.AccountID,
+		AccountName:   first.AccountName,
+		CategoryID:    first.CategoryID,
+		CategoryName:  first.CategoryName,
+		CategoryKind:  first.CategoryKind,
+		Description:   first.Description,
+		Note:          first.Note,
+		CreatedAt:     first.CreatedAt.Format(time.RFC3339),
+		SplitCount:    len(rows),
+		Splits:        make([]splitResponse, 0, len(rows)),
+	}
+	for _, row := range rows {
+		resp.Amount = resp.Amount.Add(row.Amount)
+		resp.Splits = append(resp.Splits, splitResponse{
+			LineID:       row.LineID,
+			CategoryID:   row.CategoryID,
+			CategoryName: row.CategoryName,
+			CategoryKind: row.CategoryKind,
+			Amount:       row.Amount,
+			Note:         row.LineNote,
+		})
+	}
+	if len(rows) > 1 {
+		resp.LineID = 0
+		resp.CategoryID = 0
+		resp.CategoryName = ""
+		resp.CategoryKind = ""
+	}
+
+	return resp, nil
 }
 
 func parseDate(value string, c *gin.Context) (time.Time, bool) {
@@ -517,6 +817,37 @@ func parseDateQuery(value string, c *gin.Context) (time.Time, bool) {
 	return parsed, true
 }
 
+// ensureStandalone 拒绝由转账或投资记录生成的交易，action 为错误信息中的动作（edited/deleted）。
+func ensureStandalone(tx *gorm.DB, transactionID uint, action string) error {
+	transfer, err := model.IsTransferTransaction(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	if transfer {
+		return newRequestError("transfer transactions must be " + action + " through /api/transfers")
+	}
+	investment, err := model.IsInvestmentTransaction(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	if investment {
+		return newRequestError("investment transactions must be " + action + " through /api/investments")
+	}
+	return nil
+}
+
+type requestError struct {
+	message string
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func newRequestError(message string) error {
+	return requestError{message: message}
+}
+
 func validateAccount(db *gorm.DB, ledgerID int, accountID uint, c *gin.Context) (model.Account, bool) {
 	var account model.Account
 	if err := db.Where("id = ? AND ledger_id = ?", accountID, ledgerID).First(&account).Error; err != nil {
@@ -547,16 +878,16 @@ func validateCategory(db *gorm.DB, ledgerID int, categoryID int, c *gin.Context)
 	return category, true
 }
 
-func validateAmount(kind model.CategoryKind, amount float64, c *gin.Context) bool {
-	if amount == 0 {
+func validateAmount(kind model.CategoryKind, amount decimal.Decimal, c *gin.Context) bool {
+	if amount.IsZero() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "amount cannot be 0"})
 		return false
 	}
-	if kind == model.CategoryKindIncome && amount < 0 {
+	if kind == model.CategoryKindIncome && amount.IsNegative() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "income amount must be positive"})
 		return false
 	}
-	if kind == model.CategoryKindExpense && amount > 0 {
+	if kind == model.CategoryKindExpense && amount.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "expense amount must be negative"})
 		return false
 	}
@@ -1,39 +1,122 @@
 package transfer
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"finance-backend/internal/config"
+	"finance-backend/internal/fx"
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/model"
+	"finance-backend/internal/money"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg config.TransferConfig
 }
 
-func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
-	h := Handler{db: db}
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB, cfg config.TransferConfig) {
+	h := Handler{db: db, cfg: cfg}
 
 	rg.POST("", h.create)
+	rg.GET("", h.list)
+	rg.GET("/:id", h.get)
+	rg.PATCH("/:id", h.update)
+	rg.DELETE("/:id", h.delete)
 }
 
 type createTransferRequest struct {
-	LedgerID      *int    `json:"ledger_id"`
-	OccurredOn    string  `json:"occurred_on" binding:"required"`
-	FromAccountID uint    `json:"from_account_id" binding:"required,gt=0"`
-	ToAccountID   uint    `json:"to_account_id" binding:"required,gt=0"`
-	Amount        float64 `json:"amount" binding:"required,gt=0"`
-	Description   string  `json:"description"`
-	Note          string  `json:"note"`
+	LedgerID      *int             `json:"ledger_id"`
+	OccurredOn    string           `json:"occurred_on" binding:"required"`
+	FromAccountID uint             `json:"from_account_id" binding:"required,gt=0"`
+	ToAccountID   uint             `json:"to_account_id" binding:"required,gt=0"`
+	Amount        decimal.Decimal  `json:"amount"`          // 转出金额，转出账户币种
+	ToAmount      *decimal.Decimal `json:"to_amount"`       // 转入金额，转入账户币种；跨币种时与 exchange_rate 二选一
+	ExchangeRate  *decimal.Decimal `json:"exchange_rate"`   // 1 单位转出币种折合的转入币种
+	Fee           decimal.Decimal  `json:"fee"`             // 手续费，转出账户币种，从转出账户另行扣除
+	FeeCategoryID *int             `json:"fee_category_id"` // 手续费的支出分类，fee > 0 时必填
+	Description   string           `json:"description"`
+	Note          string           `json:"note"`
 }
 
-type createTransferResponse struct {
-	TransactionID uint `json:"transaction_id"`
+// updateTransferRequest 只修改传入的字段；金额或账户变化时重写全部分录。
+type updateTransferRequest struct {
+	OccurredOn    *string          `json:"occurred_on"`
+	FromAccountID *uint            `json:"from_account_id"`
+	ToAccountID   *uint            `json:"to_account_id"`
+	Amount        *decimal.Decimal `json:"amount"`
+	ToAmount      *decimal.Decimal `json:"to_amount"`
+	ExchangeRate  *decimal.Decimal `json:"exchange_rate"`
+	Fee           *decimal.Decimal `json:"fee"`
+	FeeCategoryID *int             `json:"fee_category_id"`
+	Description   *string          `json:"description"`
+	Note          *string          `json:"note"`
+}
+
+type transferRow struct {
+	ID            uint
+	TransactionID uint
+	LedgerID      int
+	OccurredOn    time.Time
+	FromAccountID uint
+	FromAccount   string
+	FromCurrency  string
+	ToAccountID   uint
+	ToAccount     string
+	ToCurrency    string
+	Amount        decimal.Decimal
+	ToAmount      decimal.Decimal
+	FxRate        decimal.Decimal
+	Fee           decimal.Decimal
+	FeeCategoryID *int
+	Description   string
+	Note          string
+	CreatedAt     time.Time
+}
+
+type listResponse struct {
+	Data  []transferResponse `json:"data"`
+	Total int64              `json:"total"`
+}
+
+type transferResponse struct {
+	ID            uint            `json:"id"`
+	TransactionID uint            `json:"transaction_id"`
+	LedgerID      int             `json:"ledger_id"`
+	OccurredOn    string          `json:"occurred_on"`
+	FromAccountID uint            `json:"from_account_id"`
+	FromAccount   string          `json:"from_account_name"`
+	FromCurrency  string          `json:"from_currency"`
+	ToAccountID   uint            `json:"to_account_id"`
+	ToAccount     string          `json:"to_account_name"`
+	ToCurrency    string          `json:"to_currency"`
+	Amount        decimal.Decimal `json:"amount"`
+	ToAmount      decimal.Decimal `json:"to_amount"`
+	ExchangeRate  decimal.Decimal `json:"exchange_rate"`
+	Fee           decimal.Decimal `json:"fee"`
+	FeeCategoryID *int            `json:"fee_category_id"`
+	Description   string          `json:"description"`
+	Note          string          `json:"note"`
+	CreatedAt     string          `json:"created_at"`
+}
+
+// transferAmounts 是请求中与金额相关的字段，由 buildTransfer 统一校验和换算。
+type transferAmounts struct {
+	amount        decimal.Decimal
+	toAmount      *decimal.Decimal
+	exchangeRate  *decimal.Decimal
+	fee           decimal.Decimal
+	feeCategoryID *int
 }
 
 func (h Handler) create(c *gin.Context) {
@@ -43,13 +126,9 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	ledgerID := 1
-	if req.LedgerID != nil {
-		if *req.LedgerID <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ledger_id must be positive"})
-			return
-		}
-		ledgerID = *req.LedgerID
+	ledgerID, ok := ledger.Resolve(c, h.db, req.LedgerID, model.LedgerRoleEditor)
+	if !ok {
+		return
 	}
 
 	if req.FromAccountID == req.ToAccountID {
@@ -64,41 +143,36 @@ func (h Handler) create(c *gin.Context) {
 		return
 	}
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be greater than 0"})
 		return
 	}
 
-	var response createTransferResponse
+	var response transferResponse
 
 	err = h.db.Transaction(func(tx *gorm.DB) error {
-		var fromAccount model.Account
-		if err := tx.Where("id = ? AND ledger_id = ?", req.FromAccountID, ledgerID).First(&fromAccount).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return newRequestError("from account not found")
-			}
+		fromAccount, err := loadAccount(tx, ledgerID, req.FromAccountID, "from")
+		if err != nil {
 			return err
 		}
-		if !fromAccount.IsActive {
-			return newRequestError("from account is inactive")
+		toAccount, err := loadAccount(tx, ledgerID, req.ToAccountID, "to")
+		if err != nil {
+			return err
 		}
-		if strings.ToLower(fromAccount.Type) != "cash" {
-			return newRequestError("from account must be cash type")
+		if err := h.checkPair(fromAccount, toAccount); err != nil {
+			return err
 		}
 
-		var toAccount model.Account
-		if err := tx.Where("id = ? AND ledger_id = ?", req.ToAccountID, ledgerID).First(&toAccount).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return newRequestError("to account not found")
-			}
+		transfer, err := buildTransfer(tx, ledgerID, occurredOn, fromAccount, toAccount, transferAmounts{
+			amount:        req.Amount,
+			toAmount:      req.ToAmount,
+			exchangeRate:  req.ExchangeRate,
+			fee:           req.Fee,
+			feeCategoryID: req.FeeCategoryID,
+		})
+		if err != nil {
 			return err
 		}
-		if !toAccount.IsActive {
-			return newRequestError("to account is inactive")
-		}
-		if strings.ToLower(toAccount.Type) != "cash" {
-			return newRequestError("to account must be cash type")
-		}
 
 		txRecord := model.Transaction{
 			LedgerID:    ledgerID,
@@ -110,28 +184,17 @@ func (h Handler) create(c *gin.Context) {
 			return err
 		}
 
-		fromLine := model.TransactionLine{
-			LedgerID:      ledgerID,
-			TransactionID: txRecord.ID,
-			AccountID:     req.FromAccountID,
-			Amount:        -req.Amount,
-		}
-		if err := tx.Create(&fromLine).Error; err != nil {
+		if err := writeLines(tx, txRecord, transfer, fromAccount, toAccount); err != nil {
 			return err
 		}
 
-		toLine := model.TransactionLine{
-			LedgerID:      ledgerID,
-			TransactionID: txRecord.ID,
-			AccountID:     req.ToAccountID,
-			Amount:        req.Amount,
-		}
-		if err := tx.Create(&toLine).Error; err != nil {
+		transfer.TransactionID = txRecord.ID
+		if err := tx.Create(&transfer).Error; err != nil {
 			return err
 		}
 
-		response = createTransferResponse{TransactionID: txRecord.ID}
-		return nil
+		response, err = loadTransfer(tx, transfer.ID)
+		return err
 	})
 
 	if err != nil {
@@ -147,6 +210,523 @@ func (h Handler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+func (h Handler) list(c *gin.Context) {
+	ledgerID, ok := ledger.ResolveQuery(c, h.db, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	query := transferQuery(h.db).Where("tr.ledger_id = ?", ledgerID)
+
+	if value := strings.TrimSpace(c.Query("account_id")); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		query = query.Where("(tr.from_account_id = ? OR tr.to_account_id = ?)", parsed, parsed)
+	}
+	if value := strings.TrimSpace(c.Query("date_from")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("t.occurred_on >= ?", parsed)
+	}
+	if value := strings.TrimSpace(c.Query("date_to")); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_to must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("t.occurred_on <= ?", parsed)
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count transfers"})
+		return
+	}
+
+	page := parsePage(c.Query("page"))
+	pageSize := parsePageSize(c.Query("page_size"))
+
+	var rows []transferRow
+	if err := query.Select(transferColumns).
+		Order("t.occurred_on desc, tr.id desc").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query transfers"})
+		return
+	}
+
+	resp := make([]transferResponse, 0, len(rows))
+	for _, row := range rows {
+		resp = append(resp, row.response())
+	}
+
+	c.JSON(http.StatusOK, listResponse{Data: resp, Total: total})
+}
+
+func (h Handler) get(c *gin.Context) {
+	transfer, ok := h.load(c, model.LedgerRoleViewer)
+	if !ok {
+		return
+	}
+
+	resp, err := loadTransfer(h.db, transfer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// update 修改转账：未传的字段沿用原值。跨币种且未传 to_amount/exchange_rate 时，
+// 币种对不变则沿用原汇率（金额未变时沿用原转入金额），币种对变化则按汇率表重新取值。
+// 分录整体删除后按新值重写。
+func (h Handler) update(c *gin.Context) {
+	var req updateTransferRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var raw map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(raw) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+
+	transfer, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	var response transferResponse
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var txRecord model.Transaction
+		if err := tx.First(&txRecord, transfer.TransactionID).Error; err != nil {
+			return err
+		}
+
+		if req.OccurredOn != nil {
+			occurredOn, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(*req.OccurredOn), time.Local)
+			if err != nil {
+				return newRequestError("occurred_on must be YYYY-MM-DD")
+			}
+			txRecord.OccurredOn = occurredOn
+		}
+		if req.Description != nil {
+			txRecord.Description = strings.TrimSpace(*req.Description)
+		}
+		if req.Note != nil {
+			txRecord.Note = strings.TrimSpace(*req.Note)
+		}
+
+		fromAccountID := transfer.FromAccountID
+		if req.FromAccountID != nil {
+			fromAccountID = *req.FromAccountID
+		}
+		toAccountID := transfer.ToAccountID
+		if req.ToAccountID != nil {
+			toAccountID = *req.ToAccountID
+		}
+		if fromAccountID == toAccountID {
+			return newRequestError("from_account_id and to_account_id must be different")
+		}
+
+		fromAccount, err := loadAccount(tx, transfer.LedgerID, fromAccountID, "from")
+		if err != nil {
+			return err
+		}
+		toAccount, err := loadAccount(tx, transfer.LedgerID, toAccountID, "to")
+		if err != nil {
+			return err
+		}
+		if err := h.checkPair(fromAccount, toAccount); err != nil {
+			return err
+		}
+
+		input := transferAmounts{
+			amount:        transfer.Amount,
+			toAmount:      req.ToAmount,
+			exchangeRate:  req.ExchangeRate,
+			fee:           transfer.Fee,
+			feeCategoryID: transfer.FeeCategoryID,
+		}
+		if req.Amount != nil {
+			input.amount = *req.Amount
+		}
+		if req.Fee != nil {
+			input.fee = *req.Fee
+		}
+		if _, ok := raw["fee_category_id"]; ok {
+			input.feeCategoryID = req.FeeCategoryID
+		}
+		if input.toAmount == nil && input.exchangeRate == nil {
+			var oldFrom, oldTo model.Account
+			if err := tx.Unscoped().First(&oldFrom, transfer.FromAccountID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().First(&oldTo, transfer.ToAccountID).Error; err != nil {
+				return err
+			}
+			samePair := strings.EqualFold(oldFrom.Currency, fromAccount.Currency) && strings.EqualFold(oldTo.Currency, toAccount.Currency)
+			if samePair && !strings.EqualFold(fromAccount.Currency, toAccount.Currency) {
+				if money.Round(input.amount, fromAccount.Currency).Equal(transfer.Amount) {
+					input.toAmount = &transfer.ToAmount
+				} else {
+					input.exchangeRate = &transfer.FxRate
+				}
+			}
+		}
+
+		updated, err := buildTransfer(tx, transfer.LedgerID, txRecord.OccurredOn, fromAccount, toAccount, input)
+		if err != nil {
+			return err
+		}
+		updated.ID = transfer.ID
+		updated.TransactionID = transfer.TransactionID
+		updated.CreatedAt = transfer.CreatedAt
+
+		if err := tx.Save(&txRecord).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ?", txRecord.ID).Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+		if err := writeLines(tx, txRecord, updated, fromAccount, toAccount); err != nil {
+			return err
+		}
+		if err := tx.Save(&updated).Error; err != nil {
+			return err
+		}
+
+		response, err = loadTransfer(tx, transfer.ID)
+		return err
+	})
+
+	if err != nil {
+		var reqErr requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reqErr.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h Handler) delete(c *gin.Context) {
+	transfer, ok := h.load(c, model.LedgerRoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transaction_id = ?", transfer.TransactionID).Delete(&model.TransactionLine{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.Transaction{}, transfer.TransactionID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Transfer{}, transfer.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete transfer"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// load 按路径 id 读取转账并校验账本权限，失败时已写入响应。
+func (h Handler) load(c *gin.Context, role model.LedgerRole) (model.Transfer, bool) {
+	id, ok := parseID(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return model.Transfer{}, false
+	}
+
+	var transfer model.Transfer
+	err := h.db.First(&transfer, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return model.Transfer{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load transfer"})
+		return model.Transfer{}, false
+	}
+
+	if !ledger.Check(c, h.db, transfer.LedgerID, role) {
+		return model.Transfer{}, false
+	}
+	return transfer, true
+}
+
+const transferColumns = `
+    tr.id,
+    tr.transaction_id,
+    tr.ledger_id,
+    t.occurred_on,
+    tr.from_account_id,
+    fa.name AS from_account,
+    fa.currency AS from_currency,
+    tr.to_account_id,
+    ta.name AS to_account,
+    ta.currency AS to_currency,
+    tr.amount,
+    tr.to_amount,
+    tr.fx_rate,
+    tr.fee,
+    tr.fee_category_id,
+    t.description,
+    t.note,
+    tr.created_at
+  `
+
+// transferQuery 关联交易与两端账户；账户可能已被软删除，因此不过滤账户的 deleted_at。
+func transferQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("fin_transfers tr").
+		Joins("JOIN fin_transactions t ON t.id = tr.transaction_id AND t.deleted_at IS NULL").
+		Joins("JOIN fin_accounts fa ON fa.id = tr.from_account_id").
+		Joins("JOIN fin_accounts ta ON ta.id = tr.to_account_id").
+		Where("tr.deleted_at IS NULL")
+}
+
+func loadTransfer(db *gorm.DB, id uint) (transferResponse, error) {
+	var row transferRow
+	result := transferQuery(db).Where("tr.id = ?", id).Select(transferColumns).Scan(&row)
+	if result.Error != nil {
+		return transferResponse{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return transferResponse{}, gorm.ErrRecordNotFound
+	}
+	return row.response(), nil
+}
+
+func (row transferRow) response() transferResponse {
+	return transferResponse{
+		ID:            row.ID,
+		TransactionID: row.TransactionID,
+		LedgerID:      row.LedgerID,
+		OccurredOn:    row.OccurredOn.Format("2006-01-02"),
+		FromAccountID: row.FromAccountID,
+		FromAccount:   row.FromAccount,
+		FromCurrency:  row.FromCurrency,
+		ToAccountID:   row.ToAccountID,
+		ToAccount:     row.ToAccount,
+		ToCurrency:    row.ToCurrency,
+		Amount:        row.Amount,
+		ToAmount:      row.ToAmount,
+		ExchangeRate:  row.FxRate,
+		Fee:           row.Fee,
+		FeeCategoryID: row.FeeCategoryID,
+		Description:   row.Description,
+		Note:          row.Note,
+		CreatedAt:     row.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// loadAccount 读取转账一端的账户，side 为 from 或 to，用于错误信息。
+func loadAccount(tx *gorm.DB, ledgerID int, accountID uint, side string) (model.Account, error) {
+	var account model.Account
+	if err := tx.Where("id = ? AND ledger_id = ?", accountID, ledgerID).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Account{}, newRequestError(side + " account not found")
+		}
+		return model.Account{}, err
+	}
+	if !account.IsActive {
+		return model.Account{}, newRequestError(side + " account is inactive")
+	}
+	if strings.ToLower(account.Type) == model.AccountTypeFXClearing {
+		return model.Account{}, newRequestError(side + " account cannot be an fx clearing account")
+	}
+	return account, nil
+}
+
+// checkPair 按配置的账户类型矩阵校验转出、转入账户类型组合是否允许。
+func (h Handler) checkPair(from, to model.Account) error {
+	fromType := strings.ToLower(from.Type)
+	toType := strings.ToLower(to.Type)
+	if !h.cfg.AllowedPairs[fromType][toType] {
+		return newRequestError("transfers from " + fromType + " to " + toType + " accounts are not allowed")
+	}
+	return nil
+}
+
+// buildTransfer 按两端账户币种舍入金额并确定转入金额与汇率：
+// 同币种时转入金额等于转出金额；跨币种时取 to_amount 或 exchange_rate，
+// 都未提供则按发生日查询汇率表。
+func buildTransfer(tx *gorm.DB, ledgerID int, occurredOn time.Time, from, to model.Account, input transferAmounts) (model.Transfer, error) {
+	amount := money.Round(input.amount, from.Currency)
+	if !amount.IsPositive() {
+		return model.Transfer{}, newRequestError("amount must be greater than 0")
+	}
+	if input.toAmount != nil && input.exchangeRate != nil {
+		return model.Transfer{}, newRequestError("use either to_amount or exchange_rate")
+	}
+
+	one := decimal.NewFromInt(1)
+	var toAmount decimal.Decimal
+	switch {
+	case strings.EqualFold(from.Currency, to.Currency):
+		if input.toAmount != nil && !money.Round(*input.toAmount, to.Currency).Equal(amount) {
+			return model.Transfer{}, newRequestError("to_amount must equal amount for same-currency transfers")
+		}
+		if input.exchangeRate != nil && !input.exchangeRate.Equal(one) {
+			return model.Transfer{}, newRequestError("exchange_rate must be 1 for same-currency transfers")
+		}
+		toAmount = amount
+	case input.toAmount != nil:
+		toAmount = money.Round(*input.toAmount, to.Currency)
+	case input.exchangeRate != nil:
+		rate := money.Price(*input.exchangeRate)
+		if !rate.IsPositive() {
+			return model.Transfer{}, newRequestError("exchange_rate must be greater than 0")
+		}
+		toAmount = money.Round(amount.Mul(rate), to.Currency)
+	default:
+		converted, ok, err := fx.NewConverter(tx, ledgerID, occurredOn).Convert(amount, from.Currency, to.Currency)
+		if err != nil {
+			return model.Transfer{}, err
+		}
+		if !ok {
+			return model.Transfer{}, newRequestError("no " + strings.ToUpper(from.Currency) + "/" + strings.ToUpper(to.Currency) +
+				" exchange rate on or before " + occurredOn.Format("2006-01-02") + ", provide to_amount or exchange_rate")
+		}
+		toAmount = converted
+	}
+	if !toAmount.IsPositive() {
+		return model.Transfer{}, newRequestError("to_amount must be greater than 0")
+	}
+
+	fee := money.Round(input.fee, from.Currency)
+	if fee.IsNegative() {
+		return model.Transfer{}, newRequestError("fee cannot be negative")
+	}
+	var feeCategoryID *int
+	if fee.IsPositive() {
+		if input.feeCategoryID == nil {
+			return model.Transfer{}, newRequestError("fee_category_id is required when fee is greater than 0")
+		}
+		if err := validateExpenseCategory(tx, ledgerID, *input.feeCategoryID); err != nil {
+			return model.Transfer{}, err
+		}
+		feeCategoryID = input.feeCategoryID
+	}
+
+	return model.Transfer{
+		LedgerID:      ledgerID,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+		ToAmount:      toAmount,
+		FxRate:        toAmount.DivRound(amount, money.PriceScale),
+		Fee:           fee,
+		FeeCategoryID: feeCategoryID,
+	}, nil
+}
+
+// writeLines 写入转账分录：转出 -amount、转入 +to_amount；跨币种时经两个币种的
+// 外汇清算账户过渡（+amount / -to_amount），使每个币种各自平衡；
+// 手续费作为带支出分类的独立行从转出账户扣除。
+func writeLines(tx *gorm.DB, txRecord model.Transaction, transfer model.Transfer, from, to model.Account) error {
+	lines := []model.TransactionLine{
+		{AccountID: from.ID, Amount: transfer.Amount.Neg()},
+		{AccountID: to.ID, Amount: transfer.ToAmount},
+	}
+
+	if !strings.EqualFold(from.Currency, to.Currency) {
+		fromClearing, err := clearingAccount(tx, txRecord.LedgerID, from.Currency)
+		if err != nil {
+			return err
+		}
+		toClearing, err := clearingAccount(tx, txRecord.LedgerID, to.Currency)
+		if err != nil {
+			return err
+		}
+		note := "FX " + strings.ToUpper(from.Currency) + "/" + strings.ToUpper(to.Currency) + " @ " + transfer.FxRate.String()
+		lines = append(lines,
+			model.TransactionLine{AccountID: fromClearing.ID, Amount: transfer.Amount, Note: note},
+			model.TransactionLine{AccountID: toClearing.ID, Amount: transfer.ToAmount.Neg(), Note: note},
+		)
+	}
+
+	if transfer.Fee.IsPositive() {
+		lines = append(lines, model.TransactionLine{
+			AccountID:  from.ID,
+			CategoryID: transfer.FeeCategoryID,
+			Amount:     transfer.Fee.Neg(),
+			Note:       "transfer fee",
+		})
+	}
+
+	for i := range lines {
+		lines[i].LedgerID = txRecord.LedgerID
+		lines[i].TransactionID = txRecord.ID
+	}
+	return tx.Create(&lines).Error
+}
+
+// clearingAccount 返回账本下指定币种的外汇清算账户，不存在时创建。
+func clearingAccount(tx *gorm.DB, ledgerID int, currency string) (model.Account, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+
+	var account model.Account
+	err := tx.Where("ledger_id = ? AND type = ? AND UPPER(currency) = ?", ledgerID, model.AccountTypeFXClearing, currency).
+		Order("id").
+		First(&account).Error
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.Account{}, err
+	}
+
+	account = model.Account{
+		LedgerID: ledgerID,
+		Name:     "FX Clearing " + currency,
+		Type:     model.AccountTypeFXClearing,
+		Currency: currency,
+		IsActive: true,
+	}
+	if err := tx.Create(&account).Error; err != nil {
+		return model.Account{}, err
+	}
+	return account, nil
+}
+
+func validateExpenseCategory(tx *gorm.DB, ledgerID int, categoryID int) error {
+	var category model.Category
+	if err := tx.Where("id = ? AND ledger_id = ?", categoryID, ledgerID).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return newRequestError("fee category not found")
+		}
+		return err
+	}
+	if category.Kind != model.CategoryKindExpense {
+		return newRequestError("fee category must be expense kind")
+	}
+	return nil
+}
+
 type requestError struct {
 	message string
 }
@@ -158,3 +738,30 @@ func (e requestError) Error() string {
 func newRequestError(message string) error {
 	return requestError{message: message}
 }
+
+func parseID(raw string) (uint, bool) {
+	value, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || value == 0 {
+		return 0, false
+	}
+	return uint(value), true
+}
+
+func parsePage(value string) int {
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || parsed <= 0 {
+		return 1
+	}
+	return parsed
+}
+
+func parsePageSize(value string) int {
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || parsed <= 0 {
+		return 20
+	}
+	if parsed > 200 {
+		return 200
+	}
+	return parsed
+}
@@ -1,14 +1,35 @@
 package model
 
 import (
+	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+// AccountTypeLiability accounts hold money the ledger owner owes (credit
+// cards, loans). Transaction lines use the same sign on every account type:
+// positive when money flows into the account, negative when it flows out. On
+// a liability a negative line (spending on the card) therefore increases the
+// amount owed and a positive line (a repayment) reduces it. Snapshots of a
+// liability record the outstanding amount as a positive number.
+const AccountTypeLiability = "liability"
+
+// AccountBalance returns the reported balance of an account from its latest
+// snapshot amount and the sum of its lines after that snapshot. For
+// liabilities it is the outstanding amount owed.
+func AccountBalance(accountType string, snapshot, lines decimal.Decimal) decimal.Decimal {
+	if strings.EqualFold(strings.TrimSpace(accountType), AccountTypeLiability) {
+		return snapshot.Sub(lines)
+	}
+	return snapshot.Add(lines)
+}
+
 type Account struct {
 	ID        uint           `gorm:"primaryKey"`
 	LedgerID  int            `gorm:"column:ledger_id;not null;default:1;index"`
+	Ledger    *Ledger        `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
 	Name      string         `gorm:"column:name;not null"`
 	Type      string         `gorm:"column:type;not null"`
 	Currency  string         `gorm:"column:currency;not null;default:CNY"`
@@ -22,7 +43,14 @@ func (Account) TableName() string {
 }
 
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(&Ledger{}); err != nil {
+		return err
+	}
+	if err := ensureDefaultLedger(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(
 		&Account{},
 		&AccountSnapshot{},
 		&Category{},
@@ -32,6 +60,29 @@ func AutoMigrate(db *gorm.DB) error {
 		&InvestmentLot{},
 		&InvestmentSale{},
 		&InvestmentLotAllocation{},
+		&InvestmentDividend{},
+		&CorporateAction{},
+		&InvestmentTransfer{},
 		&SecurityPrice{},
-	)
+		&User{},
+		&LedgerMember{},
+		&UserInvite{},
+		&AuthSession{},
+		&RefreshToken{},
+		&APIToken{},
+		&UserRecoveryCode{},
+		&LoginAttempt{},
+		&ExchangeRate{},
+		&Transfer{},
+	); err != nil {
+		return err
+	}
+
+	if err := dropGlobalTickerUnique(db); err != nil {
+		return err
+	}
+	if err := backfillLotAccounts(db); err != nil {
+		return err
+	}
+	return backfillTransfers(db)
 }
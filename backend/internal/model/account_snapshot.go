@@ -3,18 +3,20 @@ package model
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type AccountSnapshot struct {
-	ID        uint           `gorm:"primaryKey"`
-	LedgerID  int            `gorm:"column:ledger_id;not null;default:1;index"`
-	AccountID uint           `gorm:"column:account_id;not null;index"`
-	AsOf      time.Time      `gorm:"column:as_of;type:date;not null;index"`
-	Amount    float64        `gorm:"column:amount;not null"`
-	Note      string         `gorm:"column:note"`
-	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID        uint            `gorm:"primaryKey"`
+	LedgerID  int             `gorm:"column:ledger_id;not null;default:1;index"`
+	Ledger    *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	AccountID uint            `gorm:"column:account_id;not null;index"`
+	AsOf      time.Time       `gorm:"column:as_of;type:date;not null;index"`
+	Amount    decimal.Decimal `gorm:"column:amount;type:numeric(20,4);not null"`
+	Note      string          `gorm:"column:note"`
+	CreatedAt time.Time       `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (AccountSnapshot) TableName() string {
@@ -24,6 +24,7 @@ func (k CategoryKind) IsValid() bool {
 type Category struct {
 	ID        int            `gorm:"primaryKey;column:id"`
 	LedgerID  int            `gorm:"column:ledger_id;not null"`
+	Ledger    *Ledger        `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
 	Name      string         `gorm:"column:name;not null"`
 	Kind      CategoryKind   `gorm:"column:kind;not null;check:kind IN ('income','expense','transfer','investment')"`
 	ParentID  *int           `gorm:"column:parent_id"`
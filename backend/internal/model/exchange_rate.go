@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate records that one unit of FromCurrency was worth Rate units of
+// ToCurrency on RateOn. Reports use the latest rate on or before their date.
+type ExchangeRate struct {
+	ID           uint            `gorm:"primaryKey"`
+	LedgerID     int             `gorm:"column:ledger_id;not null;default:1;uniqueIndex:idx_exchange_rate_key"`
+	Ledger       *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	RateOn       time.Time       `gorm:"column:rate_on;type:date;not null;uniqueIndex:idx_exchange_rate_key"`
+	FromCurrency string          `gorm:"column:from_currency;size:3;not null;uniqueIndex:idx_exchange_rate_key"`
+	ToCurrency   string          `gorm:"column:to_currency;size:3;not null;uniqueIndex:idx_exchange_rate_key"`
+	Rate         decimal.Decimal `gorm:"column:rate;type:numeric(24,8);not null"`
+	CreatedAt    time.Time       `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time       `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (ExchangeRate) TableName() string {
+	return "fin_exchange_rates"
+}
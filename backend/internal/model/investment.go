@@ -3,47 +3,145 @@ package model
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Security struct {
-	ID        uint           `gorm:"primaryKey"`
-	LedgerID  int            `gorm:"column:ledger_id;not null;default:1;uniqueIndex:idx_security_ledger_ticker"`
-	Ticker    string         `gorm:"column:ticker;not null;unique;uniqueIndex:idx_security_ledger_ticker"`
-	Name      string         `gorm:"column:name;not null"`
-	Currency  string         `gorm:"column:currency;not null;default:CNY"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID         uint           `gorm:"primaryKey"`
+	LedgerID   int            `gorm:"column:ledger_id;not null;default:1;uniqueIndex:idx_security_ledger_ticker"`
+	Ledger     *Ledger        `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	Ticker     string         `gorm:"column:ticker;not null;uniqueIndex:idx_security_ledger_ticker"`
+	Name       string         `gorm:"column:name;not null"`
+	Currency   string         `gorm:"column:currency;not null;default:CNY"`
+	AssetClass string         `gorm:"column:asset_class;not null;default:other"`
+	Exchange   string         `gorm:"column:exchange;not null;default:''"`
+	DeletedAt  gorm.DeletedAt `gorm:"column:deleted_at;index"`
 }
 
 func (Security) TableName() string {
 	return "fin_securities"
 }
 
+// InvestmentLot is a buy lot held in AccountID. A corporate action closes the open
+// lots of a security on its effective date (ClosedOn) and replaces each with a child
+// lot (ParentLotID, CorporateActionID) opened on that date with the adjusted quantity
+// and cost price; an in-kind transfer does the same with TransferID, moving the child
+// to another investment account. Child lots share the parent's buy line, so the
+// acquisition date carries over; OpenedOn is nil for lots opened by their own buy
+// transaction.
 type InvestmentLot struct {
-	ID                uint           `gorm:"primaryKey"`
-	LedgerID          int            `gorm:"column:ledger_id;not null;default:1"`
-	TransactionLineID uint           `gorm:"column:transaction_line_id;not null"`
-	SecurityID        uint           `gorm:"column:security_id;not null"`
-	Quantity          float64        `gorm:"column:quantity;not null"`
-	Price             float64        `gorm:"column:price;not null"` // 成本价
-	TradePrice        float64        `gorm:"column:trade_price;not null;default:0"`
-	Fee               float64        `gorm:"column:fee;not null;default:0"`
-	Tax               float64        `gorm:"column:tax;not null;default:0"`
-	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID                uint            `gorm:"primaryKey"`
+	LedgerID          int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger            *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionLineID uint            `gorm:"column:transaction_line_id;not null"`
+	AccountID         uint            `gorm:"column:account_id;not null;default:0;index"`
+	SecurityID        uint            `gorm:"column:security_id;not null"`
+	Quantity          decimal.Decimal `gorm:"column:quantity;type:numeric(24,8);not null"`
+	Price             decimal.Decimal `gorm:"column:price;type:numeric(24,8);not null"` // 成本价
+	TradePrice        decimal.Decimal `gorm:"column:trade_price;type:numeric(24,8);not null;default:0"`
+	Fee               decimal.Decimal `gorm:"column:fee;type:numeric(20,4);not null;default:0"`
+	Tax               decimal.Decimal `gorm:"column:tax;type:numeric(20,4);not null;default:0"`
+	ParentLotID       *uint           `gorm:"column:parent_lot_id;index"`
+	CorporateActionID *uint           `gorm:"column:corporate_action_id;index"`
+	TransferID        *uint           `gorm:"column:transfer_id;index"`
+	OpenedOn          *time.Time      `gorm:"column:opened_on;type:date"`
+	ClosedOn          *time.Time      `gorm:"column:closed_on;type:date"`
+	DeletedAt         gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (InvestmentLot) TableName() string {
 	return "fin_investment_lots"
 }
 
+// dropGlobalTickerUnique removes the column-level unique constraint that made
+// tickers unique across all ledgers in databases created before tickers were
+// scoped per ledger; idx_security_ledger_ticker keeps them unique within a ledger.
+func dropGlobalTickerUnique(db *gorm.DB) error {
+	migrator := db.Migrator()
+	switch db.Dialector.Name() {
+	case "postgres":
+		for _, name := range []string{"fin_securities_ticker_key", "uni_fin_securities_ticker"} {
+			if migrator.HasConstraint(&Security{}, name) {
+				if err := migrator.DropConstraint(&Security{}, name); err != nil {
+					return err
+				}
+			}
+		}
+	case "mysql":
+		if migrator.HasIndex(&Security{}, "ticker") {
+			return migrator.DropIndex(&Security{}, "ticker")
+		}
+	}
+	return nil
+}
+
+// backfillLotAccounts sets the account of lots recorded before lots carried their
+// own account_id, using the account of the buy line.
+func backfillLotAccounts(db *gorm.DB) error {
+	return db.Exec(`
+UPDATE fin_investment_lots
+SET account_id = (SELECT tl.account_id FROM fin_transaction_lines tl WHERE tl.id = fin_investment_lots.transaction_line_id)
+WHERE account_id = 0`).Error
+}
+
+// IsInvestmentTransaction reports whether a buy, sale, dividend or lot transfer
+// owns the transaction. Such transactions must be changed through the
+// investment endpoints so lots and allocations stay in step with their lines.
+func IsInvestmentTransaction(db *gorm.DB, transactionID uint) (bool, error) {
+	lineIDs := db.Model(&TransactionLine{}).Select("id").Where("transaction_id = ?", transactionID)
+	for _, owner := range []interface{}{&InvestmentLot{}, &InvestmentSale{}, &InvestmentDividend{}} {
+		var count int64
+		if err := db.Model(owner).Where("transaction_line_id IN (?)", lineIDs).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	var count int64
+	if err := db.Model(&InvestmentTransfer{}).Where("transaction_id = ?", transactionID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// InvestmentTransfer moves lots of a security from one investment account to another
+// in kind. The moved lots are closed on the transfer date and reopened in ToAccountID
+// with their cost price and buy line; the unmoved part of a partially moved lot is
+// reopened in FromAccountID. TransactionID holds the two lines that move the cost
+// basis between the accounts.
+type InvestmentTransfer struct {
+	ID            uint            `gorm:"primaryKey"`
+	LedgerID      int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger        *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionID uint            `gorm:"column:transaction_id;not null;index"`
+	SecurityID    uint            `gorm:"column:security_id;not null;index"`
+	FromAccountID uint            `gorm:"column:from_account_id;not null"`
+	ToAccountID   uint            `gorm:"column:to_account_id;not null"`
+	Quantity      decimal.Decimal `gorm:"column:quantity;type:numeric(24,8);not null"`
+	LotMethod     string          `gorm:"column:lot_method;not null;default:specific"`
+	CreatedAt     time.Time       `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt     gorm.DeletedAt  `gorm:"column:deleted_at;index"`
+}
+
+func (InvestmentTransfer) TableName() string {
+	return "fin_investment_transfers"
+}
+
 type InvestmentSale struct {
-	ID                uint           `gorm:"primaryKey"`
-	LedgerID          int            `gorm:"column:ledger_id;not null;default:1"`
-	TransactionLineID uint           `gorm:"column:transaction_line_id;not null"`
-	SecurityID        uint           `gorm:"column:security_id;not null"`
-	Quantity          float64        `gorm:"column:quantity;not null"`
-	Price             float64        `gorm:"column:price;not null"`
-	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID                uint            `gorm:"primaryKey"`
+	LedgerID          int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger            *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionLineID uint            `gorm:"column:transaction_line_id;not null"`
+	SecurityID        uint            `gorm:"column:security_id;not null"`
+	Quantity          decimal.Decimal `gorm:"column:quantity;type:numeric(24,8);not null"`
+	Price             decimal.Decimal `gorm:"column:price;type:numeric(24,8);not null"`
+	Fee               decimal.Decimal `gorm:"column:fee;type:numeric(20,4);not null;default:0"`
+	Tax               decimal.Decimal `gorm:"column:tax;type:numeric(20,4);not null;default:0"`
+	LotMethod         string          `gorm:"column:lot_method;not null;default:specific"`
+	CorporateActionID *uint           `gorm:"column:corporate_action_id;index"` // set for cash-in-lieu sales
+	DeletedAt         gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (InvestmentSale) TableName() string {
@@ -51,25 +149,91 @@ func (InvestmentSale) TableName() string {
 }
 
 type InvestmentLotAllocation struct {
-	ID        uint           `gorm:"primaryKey"`
-	LedgerID  int            `gorm:"column:ledger_id;not null;default:1"`
-	BuyLotID  uint           `gorm:"column:buy_lot_id;not null"`
-	SaleID    uint           `gorm:"column:sale_id;not null"`
-	Quantity  float64        `gorm:"column:quantity;not null"`
-	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID        uint            `gorm:"primaryKey"`
+	LedgerID  int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger    *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	BuyLotID  uint            `gorm:"column:buy_lot_id;not null"`
+	SaleID    uint            `gorm:"column:sale_id;not null"`
+	Quantity  decimal.Decimal `gorm:"column:quantity;type:numeric(24,8);not null"`
+	CreatedAt time.Time       `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (InvestmentLotAllocation) TableName() string {
 	return "fin_investment_lot_allocations"
 }
 
+// Investment income kinds recorded against a security.
+const (
+	DividendKindDividend     = "dividend"
+	DividendKindInterest     = "interest"
+	DividendKindDistribution = "distribution"
+)
+
+// InvestmentDividend links a dividend, coupon or fund distribution to its security.
+// TransactionLineID is the income line on the cash account; the pay date is the
+// transaction's occurred_on. Amounts are in the cash account currency. When the
+// income is reinvested, LotID is the lot bought with the net amount.
+type InvestmentDividend struct {
+	ID                uint            `gorm:"primaryKey"`
+	LedgerID          int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger            *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionLineID uint            `gorm:"column:transaction_line_id;not null;index"`
+	SecurityID        uint            `gorm:"column:security_id;not null;index"`
+	Kind              string          `gorm:"column:kind;not null;default:dividend"`
+	ExDate            *time.Time      `gorm:"column:ex_date;type:date"`
+	GrossAmount       decimal.Decimal `gorm:"column:gross_amount;type:numeric(20,4);not null"`
+	WithholdingTax    decimal.Decimal `gorm:"column:withholding_tax;type:numeric(20,4);not null;default:0"`
+	LotID             *uint           `gorm:"column:lot_id"`
+	DeletedAt         gorm.DeletedAt  `gorm:"column:deleted_at;index"`
+}
+
+func (InvestmentDividend) TableName() string {
+	return "fin_investment_dividends"
+}
+
+// Corporate action types.
+const (
+	CorporateActionSplit        = "split"
+	CorporateActionReverseSplit = "reverse_split"
+	CorporateActionSymbolChange = "symbol_change"
+	CorporateActionMerger       = "merger"
+)
+
+// CorporateAction records a split, reverse split, symbol change or merger of a
+// security. Each old share becomes RatioTo / RatioFrom new shares (of
+// TargetSecurityID for mergers). CashInLieu is paid into CashAccountID for
+// fractional shares and is booked as sales linked to the action.
+type CorporateAction struct {
+	ID               uint            `gorm:"primaryKey"`
+	LedgerID         int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger           *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	SecurityID       uint            `gorm:"column:security_id;not null;index"`
+	Type             string          `gorm:"column:type;not null"`
+	EffectiveOn      time.Time       `gorm:"column:effective_on;type:date;not null"`
+	RatioFrom        decimal.Decimal `gorm:"column:ratio_from;type:numeric(24,8);not null;default:1"`
+	RatioTo          decimal.Decimal `gorm:"column:ratio_to;type:numeric(24,8);not null;default:1"`
+	TargetSecurityID *uint           `gorm:"column:target_security_id"`
+	OldTicker        string          `gorm:"column:old_ticker;not null;default:''"`
+	NewTicker        string          `gorm:"column:new_ticker;not null;default:''"`
+	CashInLieu       decimal.Decimal `gorm:"column:cash_in_lieu;type:numeric(20,4);not null;default:0"`
+	CashAccountID    *uint           `gorm:"column:cash_account_id"`
+	Note             string          `gorm:"column:note;not null;default:''"`
+	CreatedAt        time.Time       `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt        gorm.DeletedAt  `gorm:"column:deleted_at;index"`
+}
+
+func (CorporateAction) TableName() string {
+	return "fin_corporate_actions"
+}
+
 type SecurityPrice struct {
-	LedgerID   int            `gorm:"column:ledger_id;primaryKey"`
-	SecurityID uint           `gorm:"column:security_id;primaryKey"`
-	PriceAt    time.Time      `gorm:"column:price_at;type:date;primaryKey"`
-	ClosePrice float64        `gorm:"column:close_price;not null"`
-	DeletedAt  gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	LedgerID   int             `gorm:"column:ledger_id;primaryKey"`
+	Ledger     *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	SecurityID uint            `gorm:"column:security_id;primaryKey"`
+	PriceAt    time.Time       `gorm:"column:price_at;type:date;primaryKey"`
+	ClosePrice decimal.Decimal `gorm:"column:close_price;type:numeric(24,8);not null"`
+	DeletedAt  gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (SecurityPrice) TableName() string {
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultLedgerID 是未显式指定 ledger_id 时使用的账本。
+const DefaultLedgerID = 1
+
+// DefaultLongTermHoldingDays 是新账本的长期持有天数阈值。
+const DefaultLongTermHoldingDays = 365
+
+// Ledger groups accounts, categories and transactions shared by its members.
+// RealizedGainCategoryID is the income category investment sales post their
+// realized gain or loss to when the sale does not name one. LongTermHoldingDays
+// is the holding period a disposal must exceed to count as long-term in the
+// capital gains report.
+type Ledger struct {
+	ID                     int            `gorm:"primaryKey;column:id"`
+	Name                   string         `gorm:"column:name;not null"`
+	Description            string         `gorm:"column:description"`
+	BaseCurrency           string         `gorm:"column:base_currency;not null;default:CNY"`
+	Timezone               string         `gorm:"column:timezone;not null;default:Asia/Shanghai"`
+	IsArchived             bool           `gorm:"column:is_archived;not null;default:false"`
+	RealizedGainCategoryID *int           `gorm:"column:realized_gain_category_id"`
+	LongTermHoldingDays    int            `gorm:"column:long_term_holding_days;not null;default:365"`
+	CreatedAt              time.Time      `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt              gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+func (Ledger) TableName() string {
+	return "fin_ledgers"
+}
+
+// ensureDefaultLedger 在账本表为空时写入默认账本，保证历史数据的 ledger_id=1 有外键可指向。
+func ensureDefaultLedger(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&Ledger{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Create(&Ledger{Name: "default", LongTermHoldingDays: DefaultLongTermHoldingDays}).Error
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+type AuthSession struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     uint       `gorm:"column:user_id;not null;index"`
+	User       *User      `gorm:"foreignKey:UserID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	UserAgent  string     `gorm:"column:user_agent"`
+	IP         string     `gorm:"column:ip"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at;not null"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+}
+
+func (AuthSession) TableName() string {
+	return "fin_auth_sessions"
+}
+
+type RefreshToken struct {
+	ID        uint         `gorm:"primaryKey"`
+	SessionID uint         `gorm:"column:session_id;not null;index"`
+	Session   *AuthSession `gorm:"foreignKey:SessionID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	TokenHash string       `gorm:"column:token_hash;not null;uniqueIndex"`
+	ExpiresAt time.Time    `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time   `gorm:"column:used_at"`
+	CreatedAt time.Time    `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (RefreshToken) TableName() string {
+	return "fin_refresh_tokens"
+}
+
+type APITokenScope string
+
+const (
+	APITokenScopeRead  APITokenScope = "read"
+	APITokenScopeWrite APITokenScope = "write"
+)
+
+type APIToken struct {
+	ID         uint          `gorm:"primaryKey"`
+	UserID     uint          `gorm:"column:user_id;not null;index"`
+	User       *User         `gorm:"foreignKey:UserID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	Name       string        `gorm:"column:name;not null"`
+	Prefix     string        `gorm:"column:prefix;not null"`
+	TokenHash  string        `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	Scope      APITokenScope `gorm:"column:scope;not null;check:scope IN ('read','write')"`
+	LedgerID   *int          `gorm:"column:ledger_id"`
+	Ledger     *Ledger       `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	LastUsedAt *time.Time    `gorm:"column:last_used_at"`
+	ExpiresAt  *time.Time    `gorm:"column:expires_at"`
+	RevokedAt  *time.Time    `gorm:"column:revoked_at"`
+	CreatedAt  time.Time     `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (APIToken) TableName() string {
+	return "fin_api_tokens"
+}
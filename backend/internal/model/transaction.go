@@ -1,14 +1,19 @@
 package model
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Transaction struct {
 	ID          uint           `gorm:"primaryKey"`
 	LedgerID    int            `gorm:"column:ledger_id;not null;default:1"`
+	Ledger      *Ledger        `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
 	OccurredOn  time.Time      `gorm:"column:occurred_on;type:date;not null"`
 	Description string         `gorm:"column:description"`
 	Note        string         `gorm:"column:note"`
@@ -21,17 +26,106 @@ func (Transaction) TableName() string {
 }
 
 type TransactionLine struct {
-	ID            uint           `gorm:"primaryKey"`
-	LedgerID      int            `gorm:"column:ledger_id;not null;default:1"`
-	TransactionID uint           `gorm:"column:transaction_id;not null"`
-	AccountID     uint           `gorm:"column:account_id;not null"`
-	CategoryID    *int           `gorm:"column:category_id"`
-	Amount        float64        `gorm:"column:amount;not null"`
-	Tags          []string       `gorm:"column:tags;type:text[]"`
-	Note          string         `gorm:"column:note"`
-	DeletedAt     gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	ID            uint            `gorm:"primaryKey"`
+	LedgerID      int             `gorm:"column:ledger_id;not null;default:1"`
+	Ledger        *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionID uint            `gorm:"column:transaction_id;not null"`
+	AccountID     uint            `gorm:"column:account_id;not null"`
+	CategoryID    *int            `gorm:"column:category_id"`
+	Amount        decimal.Decimal `gorm:"column:amount;type:numeric(20,4);not null"`
+	Tags          StringArray     `gorm:"column:tags;type:text[]"`
+	Note          string          `gorm:"column:note"`
+	DeletedAt     gorm.DeletedAt  `gorm:"column:deleted_at;index"`
 }
 
 func (TransactionLine) TableName() string {
 	return "fin_transaction_lines"
 }
+
+// StringArray maps a PostgreSQL text[] column using its text representation,
+// e.g. {groceries,"with space"}.
+type StringArray []string
+
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, item := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range item {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func (a *StringArray) Scan(src interface{}) error {
+	var text string
+	switch value := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		text = value
+	case []byte:
+		text = string(value)
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", src)
+	}
+
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return fmt.Errorf("invalid array literal %q", text)
+	}
+	body := text[1 : len(text)-1]
+
+	result := StringArray{}
+	var (
+		current   strings.Builder
+		quoted    bool
+		escaped   bool
+		wasQuoted bool
+	)
+	flush := func() {
+		item := current.String()
+		if !wasQuoted && strings.EqualFold(item, "NULL") {
+			item = ""
+		}
+		result = append(result, item)
+		current.Reset()
+		wasQuoted = false
+	}
+	for _, r := range body {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case r == ',' && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if body != "" {
+		flush()
+	}
+
+	*a = result
+	return nil
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// AccountTypeFXClearing marks the per-currency system accounts that keep
+// cross-currency transfers balanced in each currency. They are created on
+// demand and cannot be created through the accounts API.
+const AccountTypeFXClearing = "fx_clearing"
+
+// Transfer describes the transaction written by a transfer between two
+// accounts. Amount is in the source account's currency, ToAmount in the
+// destination's, and FxRate = ToAmount / Amount.
+type Transfer struct {
+	ID            uint            `gorm:"primaryKey"`
+	LedgerID      int             `gorm:"column:ledger_id;not null;default:1;index"`
+	Ledger        *Ledger         `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT" json:"-"`
+	TransactionID uint            `gorm:"column:transaction_id;not null;uniqueIndex"`
+	FromAccountID uint            `gorm:"column:from_account_id;not null;index"`
+	ToAccountID   uint            `gorm:"column:to_account_id;not null;index"`
+	Amount        decimal.Decimal `gorm:"column:amount;type:numeric(20,4);not null"`
+	ToAmount      decimal.Decimal `gorm:"column:to_amount;type:numeric(20,4);not null"`
+	FxRate        decimal.Decimal `gorm:"column:fx_rate;type:numeric(24,8);not null;default:1"`
+	Fee           decimal.Decimal `gorm:"column:fee;type:numeric(20,4);not null;default:0"`
+	FeeCategoryID *int            `gorm:"column:fee_category_id"`
+	CreatedAt     time.Time       `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt     gorm.DeletedAt  `gorm:"column:deleted_at;index"`
+}
+
+func (Transfer) TableName() string {
+	return "fin_transfers"
+}
+
+// IsTransferTransaction reports whether a transfer owns the transaction; its
+// lines must then be changed through the transfer endpoints.
+func IsTransferTransaction(db *gorm.DB, transactionID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&Transfer{}).Where("transaction_id = ?", transactionID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// backfillTransfers records transfers made before fin_transfers existed: a
+// transaction with exactly two uncategorized lines on cash accounts of the same
+// currency that cancel out, and no investment lot or sale attached.
+func backfillTransfers(db *gorm.DB) error {
+	return db.Exec(`
+INSERT INTO fin_transfers (ledger_id, transaction_id, from_account_id, to_account_id, amount, to_amount, fx_rate, fee, created_at)
+SELECT t.ledger_id, t.id,
+  MIN(CASE WHEN tl.amount < 0 THEN tl.account_id END),
+  MIN(CASE WHEN tl.amount > 0 THEN tl.account_id END),
+  MAX(tl.amount), MAX(tl.amount), 1, 0, t.created_at
+FROM fin_transactions t
+JOIN fin_transaction_lines tl ON tl.transaction_id = t.id AND tl.deleted_at IS NULL
+JOIN fin_accounts a ON a.id = tl.account_id
+WHERE t.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM fin_transfers tr WHERE tr.transaction_id = t.id)
+  AND NOT EXISTS (
+    SELECT 1 FROM fin_investment_lots l
+    JOIN fin_transaction_lines x ON x.id = l.transaction_line_id
+    WHERE x.transaction_id = t.id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM fin_investment_sales s
+    JOIN fin_transaction_lines x ON x.id = s.transaction_line_id
+    WHERE x.transaction_id = t.id
+  )
+GROUP BY t.id, t.ledger_id, t.created_at
+HAVING COUNT(*) = 2
+  AND COUNT(tl.category_id) = 0
+  AND SUM(tl.amount) = 0
+  AND MIN(tl.amount) < 0
+  AND MIN(a.type) = 'cash' AND MAX(a.type) = 'cash'
+  AND MIN(a.currency) = MAX(a.currency)`).Error
+}
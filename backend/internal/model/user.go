@@ -0,0 +1,121 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LedgerRole string
+
+const (
+	LedgerRoleOwner  LedgerRole = "owner"
+	LedgerRoleEditor LedgerRole = "editor"
+	LedgerRoleViewer LedgerRole = "viewer"
+)
+
+// IsValid reports whether the role is one of owner/editor/viewer.
+func (r LedgerRole) IsValid() bool {
+	return r.rank() > 0
+}
+
+// Allows reports whether a member holding r satisfies the required role.
+func (r LedgerRole) Allows(required LedgerRole) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+func (r LedgerRole) rank() int {
+	switch r {
+	case LedgerRoleOwner:
+		return 3
+	case LedgerRoleEditor:
+		return 2
+	case LedgerRoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type User struct {
+	ID           uint           `gorm:"primaryKey"`
+	Username     string         `gorm:"column:username;not null;uniqueIndex"`
+	PasswordHash string         `gorm:"column:password_hash;not null" json:"-"`
+	IsAdmin      bool           `gorm:"column:is_admin;not null;default:false"`
+	IsActive     bool           `gorm:"column:is_active;not null;default:true"`
+	TOTPSecret   string         `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabled  bool           `gorm:"column:totp_enabled;not null;default:false"`
+	TOTPLastStep int64          `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+	CreatedAt    time.Time      `gorm:"column:created_at;autoCreateTime"`
+	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+func (User) TableName() string {
+	return "fin_users"
+}
+
+type LedgerMember struct {
+	ID        uint       `gorm:"primaryKey"`
+	LedgerID  int        `gorm:"column:ledger_id;not null;uniqueIndex:idx_ledger_member"`
+	Ledger    *Ledger    `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	UserID    uint       `gorm:"column:user_id;not null;uniqueIndex:idx_ledger_member;index"`
+	User      *User      `gorm:"foreignKey:UserID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	Role      LedgerRole `gorm:"column:role;not null;check:role IN ('owner','editor','viewer')"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (LedgerMember) TableName() string {
+	return "fin_ledger_members"
+}
+
+type UserInvite struct {
+	ID        uint        `gorm:"primaryKey"`
+	CodeHash  string      `gorm:"column:code_hash;not null;uniqueIndex" json:"-"`
+	LedgerID  *int        `gorm:"column:ledger_id"`
+	Ledger    *Ledger     `gorm:"foreignKey:LedgerID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	Role      *LedgerRole `gorm:"column:role"`
+	CreatedBy uint        `gorm:"column:created_by;not null"`
+	ExpiresAt time.Time   `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time  `gorm:"column:used_at"`
+	UsedBy    *uint       `gorm:"column:used_by"`
+	CreatedAt time.Time   `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (UserInvite) TableName() string {
+	return "fin_user_invites"
+}
+
+type UserRecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"column:user_id;not null;index"`
+	User      *User      `gorm:"foreignKey:UserID;constraint:OnUpdate:RESTRICT,OnDelete:CASCADE" json:"-"`
+	CodeHash  string     `gorm:"column:code_hash;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "fin_user_recovery_codes"
+}
+
+type LoginFailureReason string
+
+const (
+	LoginFailureInvalidCredentials LoginFailureReason = "invalid_credentials"
+	LoginFailureInvalidMFA         LoginFailureReason = "invalid_mfa"
+	LoginFailureThrottled          LoginFailureReason = "throttled"
+)
+
+// LoginAttempt is an audit record of a failed or throttled login.
+type LoginAttempt struct {
+	ID        uint               `gorm:"primaryKey"`
+	Username  string             `gorm:"column:username;index"`
+	IP        string             `gorm:"column:ip;index"`
+	UserAgent string             `gorm:"column:user_agent"`
+	Reason    LoginFailureReason `gorm:"column:reason;not null"`
+	CreatedAt time.Time          `gorm:"column:created_at;autoCreateTime;index"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "fin_login_attempts"
+}
@@ -0,0 +1,61 @@
+// Package money holds the rounding rules for monetary amounts.
+//
+// Amounts are stored as NUMERIC columns and handled as decimal.Decimal, which
+// encodes to JSON as a string ("12.34") and accepts either strings or numbers.
+// Every amount written to a transaction line is rounded to the minor unit of
+// the account's currency, so sums reconcile exactly.
+package money
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// QuantityScale is the precision kept for security quantities.
+	QuantityScale = 8
+	// PriceScale is the precision kept for unit prices and exchange rates.
+	PriceScale = 8
+
+	defaultScale = 2
+)
+
+// minorUnits lists ISO 4217 currencies whose minor unit is not two digits.
+var minorUnits = map[string]int32{
+	"BHD": 3,
+	"CLP": 0,
+	"IQD": 3,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"VND": 0,
+}
+
+// Scale returns the number of decimal places used by currency.
+func Scale(currency string) int32 {
+	if scale, ok := minorUnits[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return scale
+	}
+	return defaultScale
+}
+
+// Round rounds amount half away from zero to the minor unit of currency.
+func Round(amount decimal.Decimal, currency string) decimal.Decimal {
+	return amount.Round(Scale(currency))
+}
+
+// Quantity rounds a security quantity to QuantityScale.
+func Quantity(value decimal.Decimal) decimal.Decimal {
+	return value.Round(QuantityScale)
+}
+
+// Price rounds a unit price or rate to PriceScale.
+func Price(value decimal.Decimal) decimal.Decimal {
+	return value.Round(PriceScale)
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"log"
 	"strings"
 
 	"finance-backend/internal/config"
@@ -8,9 +9,13 @@ import (
 	"finance-backend/internal/handler/accountsnapshot"
 	"finance-backend/internal/handler/auth"
 	"finance-backend/internal/handler/categories"
+	"finance-backend/internal/handler/exchangerate"
 	"finance-backend/internal/handler/health"
 	"finance-backend/internal/handler/investment"
+	"finance-backend/internal/handler/journal"
+	"finance-backend/internal/handler/ledger"
 	"finance-backend/internal/handler/report"
+	"finance-backend/internal/handler/security"
 	"finance-backend/internal/handler/transaction"
 	"finance-backend/internal/handler/transfer"
 
@@ -22,19 +27,28 @@ func New(cfg config.Config, db *gorm.DB) *gin.Engine {
 	setGinMode(cfg.AppEnv)
 
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("router: invalid TRUSTED_PROXIES, trusting no proxies: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
 	r.Use(gin.Logger(), gin.Recovery())
 
 	api := r.Group("/api")
 	{
 		api.GET("/health", health.Ping)
-		auth.RegisterRoutes(api.Group("/auth"))
-		api.Use(auth.Middleware())
+		api.Use(auth.Middleware(db, cfg.Auth))
+		auth.RegisterRoutes(api.Group("/auth"), db, cfg.Auth)
+		ledger.RegisterRoutes(api.Group("/ledgers"), db)
 		account.RegisterRoutes(api.Group("/accounts"), db)
 		accountsnapshot.RegisterRoutes(api.Group("/account-snapshots"), db)
 		categories.RegisterRoutes(api.Group("/categories"), db)
+		security.RegisterRoutes(api.Group("/securities"), db)
+		investment.RegisterActionRoutes(api.Group("/securities"), db)
 		investment.RegisterRoutes(api.Group("/investments"), db)
-		transfer.RegisterRoutes(api.Group("/transfers"), db)
+		transfer.RegisterRoutes(api.Group("/transfers"), db, cfg.Transfer)
 		transaction.RegisterRoutes(api.Group("/transactions"), db)
+		journal.RegisterRoutes(api.Group("/journal-entries"), db)
+		exchangerate.RegisterRoutes(api.Group("/exchange-rates"), db)
 		report.RegisterRoutes(api.Group("/reports"), db)
 	}
 
@@ -0,0 +1,99 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890", base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 Appendix B publishes 8-digit codes; the 6-digit codes are their last six digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	for _, vector := range rfcVectors {
+		got, err := Code(rfcSecret, time.Unix(vector.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", vector.unix, err)
+		}
+		if got != vector.code {
+			t.Errorf("Code(%d) = %s, want %s", vector.unix, got, vector.code)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	got, err := Code(strings.ToLower(rfcSecret), time.Unix(59, 0))
+	if err != nil || got != "287082" {
+		t.Fatalf("Code with lowercase secret = %q, %v", got, err)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", time.Unix(59, 0)); err != ErrInvalidSecret {
+		t.Fatalf("err = %v, want ErrInvalidSecret", err)
+	}
+}
+
+func TestValidateWithinSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for _, offset := range []int64{-1, 0, 1} {
+		when := now.Add(time.Duration(offset*Period) * time.Second)
+		code, err := Code(rfcSecret, when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now, 1)
+		if !ok {
+			t.Fatalf("offset %d: code rejected", offset)
+		}
+		if step != Step(when) {
+			t.Errorf("offset %d: step = %d, want %d", offset, step, Step(when))
+		}
+	}
+}
+
+func TestValidateOutsideSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for _, offset := range []int64{-2, 2} {
+		code, err := Code(rfcSecret, now.Add(time.Duration(offset*Period)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("offset %d: code accepted with skew 1", offset)
+		}
+	}
+
+	code, err := Code(rfcSecret, now.Add(-Period*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(rfcSecret, code, now, 0); ok {
+		t.Error("previous step accepted with skew 0")
+	}
+}
+
+func TestValidateRejectsMalformedCode(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("Validate(%q) accepted", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, " 287082 ", now, 0); !ok {
+		t.Error("code with surrounding spaces rejected")
+	}
+}